@@ -0,0 +1,195 @@
+package address
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Address is a bitcoin address that can be converted to and from the scriptPubKey it pays to.
+type Address interface {
+    // String returns the encoded address
+    String() string
+    // ScriptPubKey returns the script that pays to this address
+    ScriptPubKey() []byte
+}
+
+var ErrUnsupportedScript = errors.New("script does not pay to a known address type")
+
+// opcodes used by the standard output scripts
+const (
+    op0 = 0x00
+    op1 = 0x51
+    opData20 = 0x14
+    opData32 = 0x20
+    opDup = 0x76
+    opHash160 = 0xa9
+    opEqual = 0x87
+    opEqualVerify = 0x88
+    opCheckSig = 0xac
+)
+
+type P2PKHAddress struct {
+    Hash [20]byte
+    Net *Params
+}
+
+func (a *P2PKHAddress) String() string {
+    return CheckEncode(a.Hash[:], a.Net.PubKeyHashAddrID)
+}
+
+// OP_DUP OP_HASH160 OP_DATA_20 <20-byte-hash> OP_EQUALVERIFY OP_CHECKSIG
+func (a *P2PKHAddress) ScriptPubKey() []byte {
+    script := []byte{opDup, opHash160, opData20}
+    script = append(script, a.Hash[:]...)
+    return append(script, opEqualVerify, opCheckSig)
+}
+
+type P2SHAddress struct {
+    Hash [20]byte
+    Net *Params
+}
+
+func (a *P2SHAddress) String() string {
+    return CheckEncode(a.Hash[:], a.Net.ScriptHashAddrID)
+}
+
+// OP_HASH160 OP_DATA_20 <20-byte-hash> OP_EQUAL
+func (a *P2SHAddress) ScriptPubKey() []byte {
+    script := []byte{opHash160, opData20}
+    script = append(script, a.Hash[:]...)
+    return append(script, opEqual)
+}
+
+type P2WPKHAddress struct {
+    Hash [20]byte
+    Net *Params
+}
+
+func (a *P2WPKHAddress) String() string {
+    s, _ := encodeSegwitAddress(a.Net.Bech32HRP, 0, a.Hash[:])
+    return s
+}
+
+// OP_0 OP_DATA_20 <20-byte-hash>
+func (a *P2WPKHAddress) ScriptPubKey() []byte {
+    return append([]byte{op0, opData20}, a.Hash[:]...)
+}
+
+type P2WSHAddress struct {
+    Hash [32]byte
+    Net *Params
+}
+
+func (a *P2WSHAddress) String() string {
+    s, _ := encodeSegwitAddress(a.Net.Bech32HRP, 0, a.Hash[:])
+    return s
+}
+
+// OP_0 OP_DATA_32 <32-byte-hash>
+func (a *P2WSHAddress) ScriptPubKey() []byte {
+    return append([]byte{op0, opData32}, a.Hash[:]...)
+}
+
+type P2TRAddress struct {
+    // x-only output key (BIP341)
+    OutputKey [32]byte
+    Net *Params
+}
+
+func (a *P2TRAddress) String() string {
+    s, _ := encodeSegwitAddress(a.Net.Bech32HRP, 1, a.OutputKey[:])
+    return s
+}
+
+// OP_1 OP_DATA_32 <32-byte-key>
+func (a *P2TRAddress) ScriptPubKey() []byte {
+    return append([]byte{op1, opData32}, a.OutputKey[:]...)
+}
+
+// P2AAddress is the pay to anchor address, the keyless anchor output of BIP431 packages
+type P2AAddress struct {
+    Net *Params
+}
+
+// witness program of the pay to anchor output
+var anchorProgram = []byte{0x4e, 0x73}
+
+func (a *P2AAddress) String() string {
+    s, _ := encodeSegwitAddress(a.Net.Bech32HRP, 1, anchorProgram)
+    return s
+}
+
+// OP_1 OP_DATA_2 0x4e73
+func (a *P2AAddress) ScriptPubKey() []byte {
+    return append([]byte{op1, 0x02}, anchorProgram...)
+}
+
+// Decode parses an encoded address for the given network. Base58check addresses are decoded into P2PKH or P2SH addresses and segwit addresses into P2WPKH, P2WSH, P2TR or P2A addresses.
+func Decode(addr string, net *Params) (Address, error) {
+    if strings.HasPrefix(strings.ToLower(addr), net.Bech32HRP+"1") {
+        version, program, err := decodeSegwitAddress(net.Bech32HRP, addr)
+        if err != nil {
+            return nil, err
+        }
+        switch {
+        case version == 0 && len(program) == 20:
+            return &P2WPKHAddress{Hash: [20]byte(program), Net: net}, nil
+        case version == 0 && len(program) == 32:
+            return &P2WSHAddress{Hash: [32]byte(program), Net: net}, nil
+        case version == 1 && len(program) == 32:
+            return &P2TRAddress{OutputKey: [32]byte(program), Net: net}, nil
+        case version == 1 && bytes.Equal(program, anchorProgram):
+            return &P2AAddress{Net: net}, nil
+        default:
+            return nil, fmt.Errorf("unsupported witness version %d with program length %d", version, len(program))
+        }
+    }
+
+    payload, version, err := CheckDecode(addr)
+    if err != nil {
+        return nil, err
+    }
+    if len(payload) != 20 {
+        return nil, fmt.Errorf("invalid base58 address payload length: %d", len(payload))
+    }
+    switch version {
+    case net.PubKeyHashAddrID:
+        return &P2PKHAddress{Hash: [20]byte(payload), Net: net}, nil
+    case net.ScriptHashAddrID:
+        return &P2SHAddress{Hash: [20]byte(payload), Net: net}, nil
+    default:
+        return nil, fmt.Errorf("unknown address version byte 0x%02x for %s", version, net.Name)
+    }
+}
+
+// FromScriptPubKey returns the address that the given scriptPubKey pays to.
+func FromScriptPubKey(script []byte, net *Params) (Address, error) {
+    switch {
+    case len(script) == 25 && script[0] == opDup && script[1] == opHash160 && script[2] == opData20 &&
+        script[23] == opEqualVerify && script[24] == opCheckSig:
+        return &P2PKHAddress{Hash: [20]byte(script[3:23]), Net: net}, nil
+    case len(script) == 23 && script[0] == opHash160 && script[1] == opData20 && script[22] == opEqual:
+        return &P2SHAddress{Hash: [20]byte(script[2:22]), Net: net}, nil
+    case len(script) == 22 && script[0] == op0 && script[1] == opData20:
+        return &P2WPKHAddress{Hash: [20]byte(script[2:]), Net: net}, nil
+    case len(script) == 34 && script[0] == op0 && script[1] == opData32:
+        return &P2WSHAddress{Hash: [32]byte(script[2:]), Net: net}, nil
+    case len(script) == 34 && script[0] == op1 && script[1] == opData32:
+        return &P2TRAddress{OutputKey: [32]byte(script[2:]), Net: net}, nil
+    case len(script) == 4 && script[0] == op1 && script[1] == 0x02 && bytes.Equal(script[2:], anchorProgram):
+        return &P2AAddress{Net: net}, nil
+    default:
+        return nil, ErrUnsupportedScript
+    }
+}
+
+// MatchesScriptPubKey reports whether the encoded address pays to the given scriptPubKey.
+func MatchesScriptPubKey(addr string, script []byte, net *Params) bool {
+    a, err := Decode(addr, net)
+    if err != nil {
+        return false
+    }
+    return bytes.Equal(a.ScriptPubKey(), script)
+}
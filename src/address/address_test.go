@@ -0,0 +1,80 @@
+package address
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestAddressScriptPubKey(t *testing.T) {
+    tests := []struct {
+        net *Params
+        addr string
+        script string
+    }{
+        {&MainNetParams, "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac"},
+        {&TestNetParams, "mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r", "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac"},
+        {&MainNetParams, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+        {&TestNetParams, "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+        {&MainNetParams, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+        {&MainNetParams, "bc1pfeessrawgf", "51024e73"},
+    }
+    for _, test := range tests {
+        a, err := Decode(test.addr, test.net)
+        if err != nil {
+            t.Errorf("Decode(%s): %v", test.addr, err)
+            continue
+        }
+        if got := hex.EncodeToString(a.ScriptPubKey()); got != test.script {
+            t.Errorf("Decode(%s).ScriptPubKey() = %s, want %s", test.addr, got, test.script)
+        }
+        script, _ := hex.DecodeString(test.script)
+        b, err := FromScriptPubKey(script, test.net)
+        if err != nil {
+            t.Errorf("FromScriptPubKey(%s): %v", test.script, err)
+            continue
+        }
+        if b.String() != test.addr {
+            t.Errorf("FromScriptPubKey(%s) = %s, want %s", test.script, b, test.addr)
+        }
+    }
+}
+
+func TestP2SHRoundTrip(t *testing.T) {
+    script, _ := hex.DecodeString("a914751e76e8199196d454941c45d1b3a323f1433bd687")
+    for _, net := range []*Params{&MainNetParams, &TestNetParams, &RegTestParams} {
+        a, err := FromScriptPubKey(script, net)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if _, ok := a.(*P2SHAddress); !ok {
+            t.Errorf("%s: FromScriptPubKey returned %T", net.Name, a)
+        }
+        if !MatchesScriptPubKey(a.String(), script, net) {
+            t.Errorf("%s: %s does not decode to its script", net.Name, a)
+        }
+    }
+}
+
+func TestDecodeWrongNetwork(t *testing.T) {
+    tests := []struct {
+        net *Params
+        addr string
+    }{
+        {&TestNetParams, "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+        {&MainNetParams, "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+        // unsupported witness version
+        {&MainNetParams, "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs"},
+    }
+    for _, test := range tests {
+        if a, err := Decode(test.addr, test.net); err == nil {
+            t.Errorf("Decode(%s, %s) = %s", test.addr, test.net.Name, a)
+        }
+    }
+}
+
+func TestFromScriptPubKeyUnsupported(t *testing.T) {
+    script, _ := hex.DecodeString("6a0401020304")
+    if _, err := FromScriptPubKey(script, &MainNetParams); err != ErrUnsupportedScript {
+        t.Errorf("FromScriptPubKey(OP_RETURN) error = %v", err)
+    }
+}
@@ -0,0 +1,83 @@
+package address
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/humblenginr/btc-miner/utils"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var (
+    ErrChecksum = errors.New("checksum mismatch")
+    ErrInvalidBase58 = errors.New("invalid base58 character")
+    ErrInvalidFormat = errors.New("invalid format: version and/or checksum bytes missing")
+)
+
+var bigRadix = big.NewInt(58)
+
+// Base58Encode encodes a byte slice to a base58 string. Every leading zero byte is encoded as a '1'.
+func Base58Encode(b []byte) string {
+    x := new(big.Int).SetBytes(b)
+    mod := new(big.Int)
+    encoded := make([]byte, 0, len(b)*138/100+1)
+    for x.Sign() > 0 {
+        x.DivMod(x, bigRadix, mod)
+        encoded = append(encoded, base58Alphabet[mod.Int64()])
+    }
+    for _, c := range b {
+        if c != 0 {
+            break
+        }
+        encoded = append(encoded, base58Alphabet[0])
+    }
+    return string(utils.ReverseBytes(encoded))
+}
+
+// Base58Decode decodes a base58 string into a byte slice.
+func Base58Decode(s string) ([]byte, error) {
+    x := new(big.Int)
+    for i := 0; i < len(s); i++ {
+        idx := bytes.IndexByte([]byte(base58Alphabet), s[i])
+        if idx == -1 {
+            return nil, ErrInvalidBase58
+        }
+        x.Mul(x, bigRadix)
+        x.Add(x, big.NewInt(int64(idx)))
+    }
+    decoded := x.Bytes()
+    numZeros := 0
+    for numZeros < len(s) && s[numZeros] == base58Alphabet[0] {
+        numZeros++
+    }
+    return append(make([]byte, numZeros), decoded...), nil
+}
+
+// CheckEncode prepends the version byte and appends a four byte checksum (the first four bytes of the double sha256 of the payload) before base58 encoding.
+func CheckEncode(input []byte, version byte) string {
+    b := make([]byte, 0, 1+len(input)+4)
+    b = append(b, version)
+    b = append(b, input...)
+    cksum := utils.DoubleHash(b)
+    b = append(b, cksum[:4]...)
+    return Base58Encode(b)
+}
+
+// CheckDecode decodes a base58check string and returns the payload and the version byte.
+func CheckDecode(input string) ([]byte, byte, error) {
+    decoded, err := Base58Decode(input)
+    if err != nil {
+        return nil, 0, err
+    }
+    if len(decoded) < 5 {
+        return nil, 0, ErrInvalidFormat
+    }
+    version := decoded[0]
+    cksum := utils.DoubleHash(decoded[:len(decoded)-4])
+    if !bytes.Equal(cksum[:4], decoded[len(decoded)-4:]) {
+        return nil, 0, ErrChecksum
+    }
+    return decoded[1 : len(decoded)-4], version, nil
+}
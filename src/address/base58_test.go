@@ -0,0 +1,62 @@
+package address
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// vectors from bitcoin core (src/test/data/base58_encode_decode.json)
+var base58Tests = []struct {
+    hex string
+    encoded string
+}{
+    {"", ""},
+    {"61", "2g"},
+    {"626262", "a3gV"},
+    {"636363", "aPEr"},
+    {"73696d706c792061206c6f6e6720737472696e67", "2cFupjhnEsSn59qHXstmK2ffpLv2"},
+    {"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+    {"516b6fcd0f", "ABnLTmg"},
+    {"bf4f89001e670274dd", "3SEo3LWLoPntC"},
+    {"572e4794", "3EFU7m"},
+    {"ecac89cad93923c02321", "EJDM8drfXA6uyA"},
+    {"10c8511e", "Rt5zm"},
+    {"00000000000000000000", "1111111111"},
+}
+
+func TestBase58(t *testing.T) {
+    for _, test := range base58Tests {
+        b, _ := hex.DecodeString(test.hex)
+        if got := Base58Encode(b); got != test.encoded {
+            t.Errorf("Base58Encode(%s) = %s, want %s", test.hex, got, test.encoded)
+        }
+        decoded, err := Base58Decode(test.encoded)
+        if err != nil {
+            t.Errorf("Base58Decode(%s): %v", test.encoded, err)
+            continue
+        }
+        if got := hex.EncodeToString(decoded); got != test.hex {
+            t.Errorf("Base58Decode(%s) = %s, want %s", test.encoded, got, test.hex)
+        }
+    }
+}
+
+func TestBase58DecodeInvalid(t *testing.T) {
+    for _, s := range []string{"0", "O", "I", "l", "3mJr7AoUXx2Wqd", "3mJr7AoUCHxNqd"} {
+        if _, _, err := CheckDecode(s); err == nil {
+            t.Errorf("CheckDecode(%s) succeeded", s)
+        }
+    }
+}
+
+func TestCheckEncode(t *testing.T) {
+    payload, _ := hex.DecodeString("751e76e8199196d454941c45d1b3a323f1433bd6")
+    encoded := CheckEncode(payload, MainNetParams.PubKeyHashAddrID)
+    if encoded != "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH" {
+        t.Errorf("CheckEncode = %s", encoded)
+    }
+    decoded, version, err := CheckDecode(encoded)
+    if err != nil || version != MainNetParams.PubKeyHashAddrID || hex.EncodeToString(decoded) != hex.EncodeToString(payload) {
+        t.Errorf("CheckDecode(%s) = %x, %d, %v", encoded, decoded, version, err)
+    }
+}
@@ -0,0 +1,197 @@
+package address
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Implemented using BIP173 (bech32) and BIP350 (bech32m) as the reference
+
+type Encoding int
+
+const (
+    Bech32 Encoding = iota
+    Bech32m
+)
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+const (
+    bech32Const uint32 = 1
+    bech32mConst uint32 = 0x2bc830a3
+)
+
+var gen = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func polymod(values []byte) uint32 {
+    chk := uint32(1)
+    for _, v := range values {
+        b := chk >> 25
+        chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+        for i := 0; i < 5; i++ {
+            if (b>>uint(i))&1 == 1 {
+                chk ^= gen[i]
+            }
+        }
+    }
+    return chk
+}
+
+func hrpExpand(hrp string) []byte {
+    v := make([]byte, 0, len(hrp)*2+1)
+    for i := 0; i < len(hrp); i++ {
+        v = append(v, hrp[i]>>5)
+    }
+    v = append(v, 0)
+    for i := 0; i < len(hrp); i++ {
+        v = append(v, hrp[i]&31)
+    }
+    return v
+}
+
+func checksumConst(enc Encoding) uint32 {
+    if enc == Bech32m {
+        return bech32mConst
+    }
+    return bech32Const
+}
+
+func createChecksum(hrp string, data []byte, enc Encoding) []byte {
+    values := append(hrpExpand(hrp), data...)
+    values = append(values, 0, 0, 0, 0, 0, 0)
+    mod := polymod(values) ^ checksumConst(enc)
+    cksum := make([]byte, 6)
+    for i := 0; i < 6; i++ {
+        cksum[i] = byte((mod >> uint(5*(5-i))) & 31)
+    }
+    return cksum
+}
+
+// EncodeBech32 encodes the 5-bit data groups with the given human readable part.
+func EncodeBech32(hrp string, data []byte, enc Encoding) (string, error) {
+    var sb strings.Builder
+    sb.WriteString(hrp)
+    sb.WriteByte('1')
+    for _, d := range append(data, createChecksum(hrp, data, enc)...) {
+        if d >= 32 {
+            return "", fmt.Errorf("invalid data byte: %d", d)
+        }
+        sb.WriteByte(charset[d])
+    }
+    return sb.String(), nil
+}
+
+// DecodeBech32 decodes a bech32 or bech32m string and returns the human readable part, the 5-bit data groups (without the checksum) and the checksum variant that was used.
+func DecodeBech32(s string) (string, []byte, Encoding, error) {
+    if len(s) > 90 {
+        return "", nil, 0, errors.New("bech32 string too long")
+    }
+    lower, upper := false, false
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        if c < 33 || c > 126 {
+            return "", nil, 0, fmt.Errorf("invalid character in bech32 string: %q", c)
+        }
+        lower = lower || (c >= 'a' && c <= 'z')
+        upper = upper || (c >= 'A' && c <= 'Z')
+    }
+    if lower && upper {
+        return "", nil, 0, errors.New("bech32 string has mixed case")
+    }
+    s = strings.ToLower(s)
+    pos := strings.LastIndexByte(s, '1')
+    if pos < 1 || pos+7 > len(s) {
+        return "", nil, 0, errors.New("invalid bech32 separator position")
+    }
+    hrp := s[:pos]
+    data := make([]byte, 0, len(s)-pos-1)
+    for i := pos + 1; i < len(s); i++ {
+        d := strings.IndexByte(charset, s[i])
+        if d == -1 {
+            return "", nil, 0, fmt.Errorf("invalid bech32 character: %q", s[i])
+        }
+        data = append(data, byte(d))
+    }
+    var enc Encoding
+    switch polymod(append(hrpExpand(hrp), data...)) {
+    case bech32Const:
+        enc = Bech32
+    case bech32mConst:
+        enc = Bech32m
+    default:
+        return "", nil, 0, ErrChecksum
+    }
+    return hrp, data[:len(data)-6], enc, nil
+}
+
+// convertBits regroups a byte slice from fromBits-bit groups to toBits-bit groups.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+    acc, bits := uint32(0), uint(0)
+    maxv := uint32(1)<<toBits - 1
+    out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+    for _, v := range data {
+        if uint32(v)>>fromBits != 0 {
+            return nil, fmt.Errorf("invalid data range: %d", v)
+        }
+        acc = acc<<fromBits | uint32(v)
+        bits += fromBits
+        for bits >= toBits {
+            bits -= toBits
+            out = append(out, byte((acc>>bits)&maxv))
+        }
+    }
+    if pad {
+        if bits > 0 {
+            out = append(out, byte((acc<<(toBits-bits))&maxv))
+        }
+    } else if bits >= fromBits || (acc<<(toBits-bits))&maxv != 0 {
+        return nil, errors.New("invalid padding")
+    }
+    return out, nil
+}
+
+// encodeSegwitAddress encodes a witness program as a segwit address. Version 0 programs use bech32, every other version uses bech32m (BIP350).
+func encodeSegwitAddress(hrp string, version byte, program []byte) (string, error) {
+    conv, err := convertBits(program, 8, 5, true)
+    if err != nil {
+        return "", err
+    }
+    enc := Bech32m
+    if version == 0 {
+        enc = Bech32
+    }
+    return EncodeBech32(hrp, append([]byte{version}, conv...), enc)
+}
+
+// decodeSegwitAddress decodes a segwit address and returns the witness version and the witness program.
+func decodeSegwitAddress(hrp string, addr string) (byte, []byte, error) {
+    gotHRP, data, enc, err := DecodeBech32(addr)
+    if err != nil {
+        return 0, nil, err
+    }
+    if gotHRP != hrp {
+        return 0, nil, fmt.Errorf("invalid human readable part: want %s, have %s", hrp, gotHRP)
+    }
+    if len(data) < 1 {
+        return 0, nil, errors.New("empty witness data")
+    }
+    version := data[0]
+    if version > 16 {
+        return 0, nil, fmt.Errorf("invalid witness version: %d", version)
+    }
+    if (version == 0 && enc != Bech32) || (version != 0 && enc != Bech32m) {
+        return 0, nil, errors.New("invalid checksum variant for witness version")
+    }
+    program, err := convertBits(data[1:], 5, 8, false)
+    if err != nil {
+        return 0, nil, err
+    }
+    if len(program) < 2 || len(program) > 40 {
+        return 0, nil, fmt.Errorf("invalid witness program length: %d", len(program))
+    }
+    if version == 0 && len(program) != 20 && len(program) != 32 {
+        return 0, nil, fmt.Errorf("invalid witness v0 program length: %d", len(program))
+    }
+    return version, program, nil
+}
@@ -0,0 +1,141 @@
+package address
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// vectors from BIP173 and BIP350
+
+func TestDecodeBech32Checksum(t *testing.T) {
+    tests := []struct {
+        s string
+        enc Encoding
+    }{
+        {"A12UEL5L", Bech32},
+        {"a12uel5l", Bech32},
+        {"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", Bech32},
+        {"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", Bech32},
+        {"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", Bech32},
+        {"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", Bech32},
+        {"?1ezyfcl", Bech32},
+        {"A1LQFN3A", Bech32m},
+        {"a1lqfn3a", Bech32m},
+        {"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", Bech32m},
+        {"split1checkupstagehandshakeupstreamerranterredcaperredlc445v", Bech32m},
+        {"?1v759aa", Bech32m},
+    }
+    for _, test := range tests {
+        hrp, data, enc, err := DecodeBech32(test.s)
+        if err != nil {
+            t.Errorf("DecodeBech32(%s): %v", test.s, err)
+            continue
+        }
+        if enc != test.enc {
+            t.Errorf("DecodeBech32(%s) encoding = %d, want %d", test.s, enc, test.enc)
+        }
+        encoded, err := EncodeBech32(hrp, data, enc)
+        if err != nil || encoded != strings.ToLower(test.s) {
+            t.Errorf("EncodeBech32 = %s, %v, want %s", encoded, err, strings.ToLower(test.s))
+        }
+    }
+}
+
+func TestDecodeBech32Invalid(t *testing.T) {
+    for _, s := range []string{
+        "\x201nwldj5",
+        "an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx",
+        "pzry9x0s0muk",
+        "1pzry9x0s0muk",
+        "x1b4n0q5v",
+        "li1dgmt3",
+        "A1G7SGD8",
+        "10a06t8",
+        "1qzzfhee",
+        "M1VUXWEZ",
+        "1qyrz8wqd",
+        "16plkw9",
+    } {
+        if _, _, _, err := DecodeBech32(s); err == nil {
+            t.Errorf("DecodeBech32(%q) succeeded", s)
+        }
+    }
+}
+
+// witnessScript returns the scriptPubKey of the witness program
+func witnessScript(version byte, program []byte) string {
+    op := version
+    if version > 0 {
+        op = 0x50 + version
+    }
+    return hex.EncodeToString(append([]byte{op, byte(len(program))}, program...))
+}
+
+func TestSegwitAddress(t *testing.T) {
+    tests := []struct {
+        hrp string
+        addr string
+        script string
+    }{
+        {"bc", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+        {"tb", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+        {"bc", "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+        {"bc", "BC1SW50QGDZ25J", "6002751e"},
+        {"bc", "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", "5210751e76e8199196d454941c45d1b3a323"},
+        {"tb", "tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", "0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+        {"tb", "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+        {"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+    }
+    for _, test := range tests {
+        version, program, err := decodeSegwitAddress(test.hrp, test.addr)
+        if err != nil {
+            t.Errorf("decodeSegwitAddress(%s): %v", test.addr, err)
+            continue
+        }
+        if got := witnessScript(version, program); got != test.script {
+            t.Errorf("decodeSegwitAddress(%s) = %s, want %s", test.addr, got, test.script)
+        }
+        encoded, err := encodeSegwitAddress(test.hrp, version, program)
+        if err != nil || encoded != strings.ToLower(test.addr) {
+            t.Errorf("encodeSegwitAddress = %s, %v, want %s", encoded, err, strings.ToLower(test.addr))
+        }
+    }
+}
+
+func TestSegwitAddressInvalid(t *testing.T) {
+    tests := []struct {
+        hrp string
+        addr string
+    }{
+        // invalid human readable part
+        {"bc", "tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut"},
+        // bech32 instead of bech32m
+        {"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd"},
+        {"tb", "tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf"},
+        {"bc", "BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL"},
+        // bech32m instead of bech32
+        {"bc", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh"},
+        {"tb", "tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47"},
+        // invalid character
+        {"bc", "bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4"},
+        // invalid witness version
+        {"bc", "BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R"},
+        // invalid program lengths
+        {"bc", "bc1pw5dgrnzv"},
+        {"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav"},
+        {"bc", "BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P"},
+        // mixed case
+        {"tb", "tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq"},
+        // invalid padding
+        {"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf"},
+        {"tb", "tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j"},
+        // empty data
+        {"bc", "bc1gmk9yu"},
+    }
+    for _, test := range tests {
+        if _, _, err := decodeSegwitAddress(test.hrp, test.addr); err == nil {
+            t.Errorf("decodeSegwitAddress(%s) succeeded", test.addr)
+        }
+    }
+}
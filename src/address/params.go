@@ -0,0 +1,36 @@
+package address
+
+// Params holds the network specific values needed to encode and decode addresses.
+type Params struct {
+    Name string
+    // version byte prepended to the hash of base58check P2PKH addresses
+    PubKeyHashAddrID byte
+    // version byte prepended to the hash of base58check P2SH addresses
+    ScriptHashAddrID byte
+    // human readable part of segwit addresses (BIP173)
+    Bech32HRP string
+}
+
+var (
+    MainNetParams = Params{Name: "mainnet", PubKeyHashAddrID: 0x00, ScriptHashAddrID: 0x05, Bech32HRP: "bc"}
+    TestNetParams = Params{Name: "testnet", PubKeyHashAddrID: 0x6f, ScriptHashAddrID: 0xc4, Bech32HRP: "tb"}
+    // signet uses the same encoding as testnet
+    SigNetParams = Params{Name: "signet", PubKeyHashAddrID: 0x6f, ScriptHashAddrID: 0xc4, Bech32HRP: "tb"}
+    RegTestParams = Params{Name: "regtest", PubKeyHashAddrID: 0x6f, ScriptHashAddrID: 0xc4, Bech32HRP: "bcrt"}
+)
+
+// ParamsByName returns the network parameters for the given network name.
+func ParamsByName(name string) (*Params, bool) {
+    switch name {
+    case MainNetParams.Name:
+        return &MainNetParams, true
+    case TestNetParams.Name, "testnet3":
+        return &TestNetParams, true
+    case SigNetParams.Name:
+        return &SigNetParams, true
+    case RegTestParams.Name:
+        return &RegTestParams, true
+    default:
+        return nil, false
+    }
+}
@@ -2,7 +2,4 @@ module github.com/humblenginr/btc-miner
 
 go 1.22.0
 
-require (
-	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.2.0
-	github.com/x1m3/priorityQueue v0.0.0-20180318192439-29f82ba34a27
-)
+require github.com/decred/dcrd/dcrec/secp256k1/v4 v4.2.0
@@ -0,0 +1,35 @@
+// Package txtest holds the helpers building fake transactions shared by the tests of several packages
+package txtest
+
+import (
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+var count int
+
+// NextID returns a number never returned before, used to make fake transactions unique
+func NextID() int {
+    count++
+    return count
+}
+
+// AsTRUC returns the transaction opting in to the TRUC rules
+func AsTRUC(tx *txn.Transaction) *txn.Transaction {
+    tx.Version = policy.TRUCVersion
+    return tx
+}
+
+// WithDust returns the transaction with a dust output of 0 sats added, which leaves its fee unchanged
+func WithDust(tx *txn.Transaction) *txn.Transaction {
+    tx.Vout = append(tx.Vout, txn.Vout{ScriptPubKey: "51", Value: 0})
+    return tx
+}
+
+// Respend returns a transaction spending the same outputs as tx and paying fee
+func Respend(tx *txn.Transaction, fee int) *txn.Transaction {
+    r := &txn.Transaction{Version: tx.Version, Locktime: uint32(NextID())}
+    r.Vin = append(r.Vin, tx.Vin...)
+    r.Vout = []txn.Vout{{ScriptPubKey: "51", Value: tx.Vout[0].Value + tx.GetFees() - fee}}
+    return r
+}
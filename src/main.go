@@ -1,22 +1,70 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/humblenginr/btc-miner/address"
 	"github.com/humblenginr/btc-miner/mining"
+	"github.com/humblenginr/btc-miner/policy"
+	"github.com/humblenginr/btc-miner/source"
 	txn "github.com/humblenginr/btc-miner/transaction"
 	"github.com/humblenginr/btc-miner/utils"
 	"github.com/humblenginr/btc-miner/txnpicker"
+	"github.com/humblenginr/btc-miner/txpool"
+	"github.com/humblenginr/btc-miner/utxo"
 )
 
 
 var (
     OutputFilePath = "../output.txt"
+    // mempool the transactions are picked from, read in MempoolFormat ("-" reads stdin)
     MempoolDirPath = "../mempool"
-    MaxTxWeight = 4000000
-    BlockHeaderWeight = 320
-    MaxTotalWeight = 4000000 - BlockHeaderWeight
-    MaxFee = 31616923
+    // dir (a folder of JSON files), jsonl (JSON lines), hex (raw transactions, one per line) or mempooldat (mempool.dat of bitcoin core)
+    MempoolFormat = "dir"
+    // limits of the block template, a zero value disables the limit
+    MaxBlockWeight = 4000000
+    // weight of the header and the coinbase transaction, computed from the coinbase transaction if negative
+    ReservedWeight = -1
+    MaxSigOpCost = txn.MaxBlockSigOpsCost - txnpicker.CoinbaseReservedSigOpCost
+    MaxTxCount = 0
+    // in sat/vB
+    MinFeeRate = 0.0
+    MaxFee = 0
+    // address the block reward is paid to, an anyone-can-spend output is used if empty
+    PayoutAddress = ""
+    Network = "mainnet"
+    // utxo file used to resolve the prevouts, the prevouts given in the mempool files are trusted if empty
+    UTXOFilePath = ""
+    // folder the transactions of the mined block are written to, nothing is written if empty
+    ExportDirPath = ""
+    // name of the selection strategy (see txnpicker.StrategyNames)
+    Strategy = txnpicker.DefaultStrategyName
+    // time the optimize strategy is allowed to search for
+    OptimizeBudget = txnpicker.DefaultOptimizeBudget
+    CompareStrategies = false
+    // how mempool transactions spending the same outputs are resolved (feerate, bip125, firstseen)
+    ConflictRule = "feerate"
+    // file the selection report is written to, as CSV if it ends with .csv and as JSON otherwise. No report is written if empty.
+    ReportFilePath = ""
+    // file the dependency graph of the mempool is written to, as Graphviz DOT if it ends with .dot and as JSON otherwise. No graph is written if empty.
+    GraphFilePath = ""
+    // load the mempool one transaction at a time, keeping only a summary of each transaction in memory (dir and jsonl formats)
+    Streaming = false
+    // number of successive blocks simulated to estimate fee rates, no estimation is done if zero
+    EstimateBlocks = 0
+    // package (read in MempoolFormat, parents first) validated instead of building a block, nothing is validated if empty
+    TestPackagePath = ""
+    // submit the mempool transactions one after the other to a stateful mempool and pick from what it keeps
+    Replay = false
+    // let the replayed transactions replace transactions that do not signal replaceability
+    FullRBF = false
+    // with Replay, follow the template as the transactions are replayed (see txnpicker.TemplateManager) and mine the last one emitted, a new template being emitted when it gains more than MinFeeGain sats. The template is picked from the replayed mempool once if negative.
+    MinFeeGain = -1
+    // print diagnostics (resolved conflicts, binding constraints, ...) to stderr
+    Verbose = false
 )
 
 func LogDetailsAboutTx(tx txn.Transaction){
@@ -27,9 +75,292 @@ func LogDetailsAboutTx(tx txn.Transaction){
      fmt.Printf("Filename: %x\n", utils.Hash(rev))
 }
 
+func writeReport(report txnpicker.Report, path string) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if strings.HasSuffix(path, ".csv") {
+        err = report.WriteCSV(f)
+    } else {
+        err = report.WriteJSON(f)
+    }
+    // the error of Close tells whether the report was written out
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+// testPackage validates the transactions read from path as a package, at the minimum relay fee rate
+func testPackage(path string, utxoSet utxo.UTXOView) error {
+    src, err := source.New(MempoolFormat, path, utxoSet)
+    if err != nil {
+        return err
+    }
+    entries, err := src.Load()
+    if err != nil {
+        return err
+    }
+    txns := make([]*txn.Transaction, 0, len(entries))
+    for _, e := range entries {
+        txns = append(txns, e.Tx)
+    }
+    fmt.Println(txnpicker.TestPackageAccept(txns, utxoSet, float64(policy.MinRelayFeeRate)))
+    return nil
+}
+
+// replayMempool submits the transactions of src to a new mempool in the order they are read, and prints the rejected ones to stderr if Verbose is set
+func replayMempool(src source.MempoolSource, utxoSet utxo.UTXOView) (*txpool.Mempool, error) {
+    entries, err := src.Load()
+    if err != nil {
+        return nil, err
+    }
+    txns := make([]*txn.Transaction, 0, len(entries))
+    for _, e := range entries {
+        if e.PrevOutErr != nil {
+            if Verbose {
+                fmt.Fprintf(os.Stderr, "%s rejected: %v\n", e.Name, e.PrevOutErr)
+            }
+            continue
+        }
+        txns = append(txns, e.Tx)
+    }
+    pool := txpool.New(FullRBF)
+    pool.UTXOSet = utxoSet
+    accepted, evicted := 0, 0
+    for _, r := range pool.Replay(txns) {
+        if r.Accepted {
+            accepted++
+        } else if Verbose {
+            fmt.Fprintf(os.Stderr, "%s rejected: %v\n", r.Txid, r.Err)
+        }
+        evicted += len(r.Evicted)
+    }
+    if Verbose {
+        fmt.Fprintf(os.Stderr, "replayed %d transactions: %d accepted, %d evicted, %d in the mempool\n", len(txns), accepted, evicted, pool.Len())
+    }
+    return pool, nil
+}
+
+// replayTemplates submits the transactions of src in the order they are read to a template manager, which updates the template of its mempool as they are accepted. The manager is returned once all of them are submitted, the number of templates emitted is printed to stderr if Verbose is set.
+func replayTemplates(src source.MempoolSource, utxoSet utxo.UTXOView, constraints txnpicker.Constraints, strategy txnpicker.SelectionStrategy, payoutScript []byte) (*txnpicker.TemplateManager, error) {
+    entries, err := src.Load()
+    if err != nil {
+        return nil, err
+    }
+    pool := txpool.New(FullRBF)
+    pool.UTXOSet = utxoSet
+    m := txnpicker.NewTemplateManager(pool, constraints, strategy, MinFeeGain, payoutScript, true)
+    // the template of the empty mempool
+    m.Update()
+    emitted := 0
+    for _, e := range entries {
+        if e.PrevOutErr != nil {
+            if Verbose {
+                fmt.Fprintf(os.Stderr, "%s rejected: %v\n", e.Name, e.PrevOutErr)
+            }
+            continue
+        }
+        block, err := m.Add(e.Tx)
+        if err != nil && Verbose {
+            fmt.Fprintf(os.Stderr, "%s rejected: %v\n", e.Name, err)
+        }
+        if block != nil {
+            emitted++
+        }
+    }
+    if Verbose {
+        fmt.Fprintf(os.Stderr, "replayed %d transactions: %d templates emitted, %d transactions in the mempool\n", len(entries), emitted, pool.Len())
+    }
+    return m, nil
+}
+
+func writeGraph(graph txnpicker.GraphExport, path string) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if strings.HasSuffix(path, ".dot") {
+        err = graph.WriteDOT(f)
+    } else {
+        err = graph.WriteJSON(f)
+    }
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
 func main() {
-    picker := txnpicker.NewTransactionPicker(MempoolDirPath, MaxTxWeight, MaxTotalWeight, MaxFee)
-    txns := picker.PickUsingPQ()
-    candidateBlock := mining.GetCandidateBlock(txns, true)
-    mining.MineBlock(candidateBlock, OutputFilePath)
+    flag.StringVar(&MempoolDirPath, "mempool", MempoolDirPath, "mempool to pick the transactions from (\"-\" for stdin)")
+    flag.StringVar(&MempoolFormat, "format", MempoolFormat, "format of the mempool (dir, jsonl, hex, mempooldat)")
+    flag.StringVar(&PayoutAddress, "payout", PayoutAddress, "address the coinbase transaction pays to")
+    flag.StringVar(&Network, "network", Network, "network of the payout address (mainnet, testnet, signet, regtest)")
+    flag.StringVar(&UTXOFilePath, "utxo", UTXOFilePath, "utxo file (JSON lines) used to resolve the prevouts of the mempool transactions")
+    flag.StringVar(&ExportDirPath, "export", ExportDirPath, "folder to write the transactions of the mined block to, in the mempool file format")
+    flag.StringVar(&Strategy, "strategy", Strategy, "transaction selection strategy ("+strings.Join(txnpicker.StrategyNames(), ", ")+")")
+    flag.DurationVar(&OptimizeBudget, "budget", OptimizeBudget, "time budget of the optimize strategy")
+    flag.BoolVar(&CompareStrategies, "compare", CompareStrategies, "compare the templates picked by all the selection strategies")
+    flag.StringVar(&ConflictRule, "conflict-rule", ConflictRule, "how conflicting mempool transactions are resolved (feerate, bip125, firstseen)")
+    flag.IntVar(&EstimateBlocks, "estimate", EstimateBlocks, "simulate that many successive blocks with the selection strategy and print the fee rates needed to get into them, along with a fee rate histogram of the mempool")
+    flag.StringVar(&ReportFilePath, "report", ReportFilePath, "write the decision taken for every mempool transaction to this file (CSV if it ends with .csv, JSON otherwise)")
+    flag.StringVar(&GraphFilePath, "graph", GraphFilePath, "write the dependency graph of the mempool, with the picked transactions highlighted, to this file (DOT if it ends with .dot, JSON otherwise)")
+    flag.BoolVar(&Streaming, "stream", Streaming, "load the mempool one transaction at a time with bounded memory, rejecting the transactions that cannot be read (dir and jsonl formats only)")
+    flag.IntVar(&MaxBlockWeight, "max-weight", MaxBlockWeight, "maximum block weight (0 for no limit)")
+    flag.IntVar(&ReservedWeight, "reserved-weight", ReservedWeight, "block weight reserved for the header and the coinbase transaction (negative to compute it exactly)")
+    flag.IntVar(&MaxSigOpCost, "max-sigops", MaxSigOpCost, "maximum total sigop cost of the picked transactions (0 for no limit)")
+    flag.IntVar(&MaxTxCount, "max-txs", MaxTxCount, "maximum number of picked transactions (0 for no limit)")
+    flag.Float64Var(&MinFeeRate, "min-feerate", MinFeeRate, "minimum fee rate in sat/vB of the picked transactions (0 for no limit)")
+    flag.IntVar(&MaxFee, "max-fee", MaxFee, "maximum total fee of the picked transactions (0 for no limit)")
+    flag.StringVar(&TestPackagePath, "test-package", TestPackagePath, "validate the transactions of this file (in the -format format, parents first) as a package and exit")
+    flag.BoolVar(&Replay, "replay", Replay, "submit the mempool transactions in order to a mempool applying the replacement rules and limits, and pick from it")
+    flag.BoolVar(&FullRBF, "full-rbf", FullRBF, "with -replay, let transactions replace conflicting ones that do not signal replaceability")
+    flag.IntVar(&MinFeeGain, "min-fee-gain", MinFeeGain, "with -replay, update the template as every transaction is replayed, emitting a new one when it gains more than this many sats, and mine the last one (disabled if negative)")
+    flag.BoolVar(&Verbose, "v", Verbose, "print diagnostics such as the resolved mempool conflicts and the binding constraints of the template to stderr")
+    flag.Parse()
+
+    var payoutScript []byte
+    if PayoutAddress != "" {
+        net, ok := address.ParamsByName(Network)
+        if !ok {
+            panic("unknown network: " + Network)
+        }
+        script, err := mining.PayoutScript(PayoutAddress, net)
+        if err != nil {
+            panic(err)
+        }
+        payoutScript = script
+    }
+    if ReservedWeight < 0 {
+        ReservedWeight = mining.ReservedWeight(payoutScript, true)
+    }
+    constraints := txnpicker.Constraints{
+        MaxWeight: MaxBlockWeight,
+        ReservedWeight: ReservedWeight,
+        MaxSigOpCost: MaxSigOpCost,
+        MaxTxCount: MaxTxCount,
+        MinFeeRate: MinFeeRate,
+        MaxFee: MaxFee,
+    }
+    picker := txnpicker.NewTransactionPicker(MempoolDirPath, constraints)
+    if UTXOFilePath != "" {
+        utxoSet, err := utxo.OpenFileView(UTXOFilePath)
+        if err != nil {
+            panic(err)
+        }
+        defer utxoSet.Close()
+        picker.UTXOSet = utxoSet
+    }
+    if TestPackagePath != "" {
+        if err := testPackage(TestPackagePath, picker.UTXOSet); err != nil {
+            panic(err)
+        }
+        return
+    }
+    src, err := source.New(MempoolFormat, MempoolDirPath, picker.UTXOSet)
+    if err != nil {
+        panic(err)
+    }
+    picker.Source = src
+    if _, ok := src.(source.StreamSource); Streaming && !ok {
+        panic("-stream cannot be used with -format " + MempoolFormat)
+    }
+    strategy, err := txnpicker.StrategyByName(Strategy)
+    if err != nil {
+        panic(err)
+    }
+    optimize, isOptimize := strategy.(*txnpicker.OptimizeStrategy)
+    if isOptimize {
+        optimize.Budget = OptimizeBudget
+    }
+    if MinFeeGain >= 0 && !Replay {
+        panic("-min-fee-gain can only be used with -replay")
+    }
+    var manager *txnpicker.TemplateManager
+    if Replay {
+        if Streaming {
+            panic("-replay cannot be used with -stream")
+        }
+        if MinFeeGain >= 0 {
+            manager, err = replayTemplates(src, picker.UTXOSet, constraints, strategy, payoutScript)
+            if err != nil {
+                panic(err)
+            }
+            picker.Pool = manager.Picker.Pool
+        } else {
+            pool, err := replayMempool(src, picker.UTXOSet)
+            if err != nil {
+                panic(err)
+            }
+            picker.Pool = pool
+        }
+    }
+    conflictRule, err := txnpicker.ParseConflictRule(ConflictRule)
+    if err != nil {
+        panic(err)
+    }
+    picker.ConflictRule = conflictRule
+    picker.Streaming = Streaming
+    if Verbose {
+        for _, c := range picker.Conflicts() {
+            fmt.Fprintln(os.Stderr, c)
+        }
+    }
+    if CompareStrategies {
+        all := make([]txnpicker.SelectionStrategy, 0)
+        for _, name := range txnpicker.StrategyNames() {
+            s, _ := txnpicker.StrategyByName(name)
+            if o, ok := s.(*txnpicker.OptimizeStrategy); ok {
+                o.Budget = OptimizeBudget
+            }
+            all = append(all, s)
+        }
+        fmt.Println(picker.CompareStrategies(all...))
+        fmt.Println(picker.CompareClusters())
+    }
+    if EstimateBlocks > 0 {
+        blocks := picker.EstimateBlocks(EstimateBlocks, strategy)
+        for _, b := range blocks {
+            fmt.Printf("%s, fee rate needed within %d block(s): %.2f sat/vB\n", b, b.Height, txnpicker.EstimateFeeRate(blocks, b.Height))
+        }
+        fmt.Println(txnpicker.FormatHistogram(picker.FeeRateHistogram(txnpicker.DefaultFeeRateBuckets)))
+    }
+    var result txnpicker.SelectionResult
+    if manager != nil {
+        result = manager.Result()
+    } else {
+        result = picker.Select(strategy)
+    }
+    if isOptimize {
+        fmt.Println(optimize.Result)
+    }
+    if Verbose {
+        fmt.Fprintln(os.Stderr, picker.ExplainConstraints(result))
+    }
+    if ReportFilePath != "" {
+        if err := writeReport(picker.Report(result), ReportFilePath); err != nil {
+            panic(err)
+        }
+    }
+    if GraphFilePath != "" {
+        if err := writeGraph(picker.ExportGraph(result), GraphFilePath); err != nil {
+            panic(err)
+        }
+    }
+    var candidateBlock mining.Block
+    if manager != nil {
+        candidateBlock = *manager.Template()
+    } else {
+        candidateBlock = mining.GetCandidateBlock(result.Txns, payoutScript, true)
+    }
+    if Verbose {
+        fmt.Fprintf(os.Stderr, "block weight: %d / %d\n", candidateBlock.Weight(), mining.MaxBlockWeight)
+    }
+    mining.MineBlock(&candidateBlock, OutputFilePath)
+    if ExportDirPath != "" {
+        if err := candidateBlock.WriteTransactionsToDir(ExportDirPath); err != nil {
+            panic(err)
+        }
+    }
 }
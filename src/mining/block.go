@@ -5,8 +5,11 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	txn "github.com/humblenginr/btc-miner/transaction"
 	"github.com/humblenginr/btc-miner/utils"
@@ -58,6 +61,14 @@ func (bh *BlockHeader) Serialize(w io.Writer) error {
 	return nil
 }
 
+// Hash returns the block hash in the reversed hex form used by block explorers
+func (bh *BlockHeader) Hash() string {
+    buf := bytes.NewBuffer(make([]byte, 0, 80))
+    bh.Serialize(buf)
+    hash := utils.DoubleHash(buf.Bytes())
+    return hex.EncodeToString(utils.ReverseBytes(hash))
+}
+
 func NewBlockHeader(version int32, prevBlockHash [32]byte, merkleRoot [32]byte, time int64, bits uint32, nonce uint32) BlockHeader {
     return BlockHeader{version, prevBlockHash, merkleRoot, time, bits, nonce}
 }
@@ -111,3 +122,22 @@ func NewBlock(header BlockHeader, coinbase txn.Transaction, txns []txn.Transacti
     return Block{header, coinbase, txns}
 }
 
+// WriteTransactionsToDir writes every transaction of the block, including the coinbase, to dirPath in the same format as the mempool files. As in the mempool folder, each file is named after the sha256 of the txid.
+func (b *Block) WriteTransactionsToDir(dirPath string) error {
+    if err := os.MkdirAll(dirPath, 0755); err != nil {
+        return err
+    }
+    status := txn.TxStatus{Confirmed: true, BlockHash: b.BlockHeader.Hash(), BlockTime: b.BlockHeader.Time}
+    for _, t := range b.Transactions {
+        txid := utils.ReverseBytes(t.TxHash())
+        data, err := json.MarshalIndent(t.ToEsplora(status), "", "  ")
+        if err != nil {
+            return err
+        }
+        fileName := fmt.Sprintf("%x.json", utils.Hash(txid))
+        if err := os.WriteFile(filepath.Join(dirPath, fileName), data, 0644); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"math"
 
+	"github.com/humblenginr/btc-miner/address"
 	txn "github.com/humblenginr/btc-miner/transaction"
 	"github.com/humblenginr/btc-miner/utils"
 )
@@ -13,8 +14,23 @@ var WitnessReserveHexString = "0000000000000000000000000000000000000000000000000
 // in satoshis
 var BlockSubsidy int = 0
 var CoinbaseTransactionVersion int32 = 1
+// OP_TRUE - anyone can redeem this, used when no payout script is given
+var AnyoneCanSpendScript = []byte{0x51}
 
-func NewCoinbaseTransaction(fees int) txn.Transaction {
+// PayoutScript returns the scriptPubKey paying to the given address
+func PayoutScript(addr string, net *address.Params) ([]byte, error) {
+    a, err := address.Decode(addr, net)
+    if err != nil {
+        return nil, err
+    }
+    return a.ScriptPubKey(), nil
+}
+
+// NewCoinbaseTransaction returns the coinbase transaction paying the subsidy and the fees to payoutScript, or to AnyoneCanSpendScript if it is empty
+func NewCoinbaseTransaction(fees int, payoutScript []byte) txn.Transaction {
+    if len(payoutScript) == 0 {
+        payoutScript = AnyoneCanSpendScript
+    }
     var zeroTxid [32]byte
     t := txn.Transaction{}
     t.Version = CoinbaseTransactionVersion
@@ -29,8 +45,7 @@ func NewCoinbaseTransaction(fees int) txn.Transaction {
     t.Vin = append(t.Vin, vin)
 
     vout := txn.Vout{}
-    // OP_TRUE - anyone can redeem this
-    vout.ScriptPubKey = "51"
+    vout.ScriptPubKey = hex.EncodeToString(payoutScript)
     vout.Value = BlockSubsidy + fees
     t.Vout = append(t.Vout, vout)
 
@@ -48,9 +63,6 @@ var PreWitnessScriptBytes = []byte{
 
 func AddWitnessCommitment(coinbaseTx *txn.Transaction,
 	blockTxns []*txn.Transaction) []byte {
-	var witnessNonce [32]byte
-	coinbaseTx.Vin[0].Witness = []string{hex.EncodeToString(witnessNonce[:])} 
-
     var zeroHash [32]byte
     wtxids := make([][32]byte, 0)
     for _, t := range blockTxns {
@@ -64,7 +76,13 @@ func AddWitnessCommitment(coinbaseTx *txn.Transaction,
         }
     }
 
-	witnessMerkleRoot := GenerateMerkleTreeRoot(wtxids)
+    return addWitnessCommitment(coinbaseTx, GenerateMerkleTreeRoot(wtxids))
+}
+
+// addWitnessCommitment adds the witness nonce and the output committing to the witness merkle root to the coinbase transaction
+func addWitnessCommitment(coinbaseTx *txn.Transaction, witnessMerkleRoot [32]byte) []byte {
+	var witnessNonce [32]byte
+	coinbaseTx.Vin[0].Witness = []string{hex.EncodeToString(witnessNonce[:])} 
 
 	var witnessPreimage [64]byte
 	copy(witnessPreimage[:32], witnessMerkleRoot[:])
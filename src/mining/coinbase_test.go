@@ -0,0 +1,44 @@
+package mining
+
+import (
+	"testing"
+
+	"github.com/humblenginr/btc-miner/address"
+)
+
+func TestCoinbasePayoutScript(t *testing.T) {
+    script, err := PayoutScript("bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", &address.MainNetParams)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        script []byte
+        want string
+    }{
+        {nil, "51"},
+        {script, "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+    }
+    for _, test := range tests {
+        cb := NewCoinbaseTransaction(1000, test.script)
+        if got := cb.Vout[0].ScriptPubKey; got != test.want {
+            t.Errorf("coinbase pays to %s, want %s", got, test.want)
+        }
+        if cb.Vout[0].Value != BlockSubsidy+1000 {
+            t.Errorf("coinbase value = %d", cb.Vout[0].Value)
+        }
+        // the reserved weight follows the size of the payout script
+        diff := ReservedWeight(test.script, true) - ReservedWeight(nil, true)
+        if want := 4 * (len(test.want)/2 - 1); diff != want {
+            t.Errorf("reserved weight grows by %d, want %d", diff, want)
+        }
+    }
+}
+
+func TestPayoutScriptInvalid(t *testing.T) {
+    if _, err := PayoutScript("bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", &address.TestNetParams); err == nil {
+        t.Error("mainnet address decoded for testnet")
+    }
+    if _, err := PayoutScript("not an address", &address.MainNetParams); err == nil {
+        t.Error("invalid address decoded")
+    }
+}
@@ -38,3 +38,89 @@ func GenerateMerkleTreeRoot(txids [][32]byte) [32]byte{
   return level[0]
 }
 
+
+// MerkleTree keeps every level of a merkle tree, so that changing the leaves only recomputes the nodes above the ones that changed. Appending a transaction to a block only recomputes one node per level for instance.
+type MerkleTree struct {
+    // levels[0] are the leaves in internal byte order, the last level is the root
+    levels [][][32]byte
+}
+
+// Update sets the leaves of the tree, given in the same (reversed) form as the txids of GenerateMerkleTreeRoot, and recomputes the nodes above the leaves that changed
+func (m *MerkleTree) Update(txids [][32]byte) {
+    leaves := make([][32]byte, 0, len(txids))
+    for _, t := range txids {
+        leaves = append(leaves, [32]byte(utils.ReverseBytes(t[:])))
+    }
+    // index of the first leaf that changed
+    first := 0
+    if len(m.levels) > 0 {
+        old := m.levels[0]
+        for first < len(old) && first < len(leaves) && old[first] == leaves[first] {
+            first++
+        }
+        if first == len(old) && first == len(leaves) {
+            return
+        }
+    }
+
+    levels := [][][32]byte{leaves}
+    for level := leaves; len(level) > 1; level = levels[len(levels)-1] {
+        first /= 2
+        next := make([][32]byte, (len(level)+1)/2)
+        depth := len(levels)
+        // the nodes before the first changed one are unchanged
+        if depth < len(m.levels) {
+            copy(next[:first], m.levels[depth])
+        }
+        for i := first; i < len(next); i++ {
+            next[i] = hashChildren(level, i)
+        }
+        levels = append(levels, next)
+    }
+    m.levels = levels
+}
+
+// UpdateLeaf sets the i-th leaf of the tree, given in the same form as the txids of Update, and only recomputes the nodes on its path to the root. The tree must have more than i leaves.
+func (m *MerkleTree) UpdateLeaf(i int, txid [32]byte) {
+    m.levels[0][i] = [32]byte(utils.ReverseBytes(txid[:]))
+    for depth := 1; depth < len(m.levels); depth++ {
+        i /= 2
+        m.levels[depth][i] = hashChildren(m.levels[depth-1], i)
+    }
+}
+
+// Leaf returns the i-th leaf of the tree, in the same form as the txids of Update
+func (m *MerkleTree) Leaf(i int) [32]byte {
+    // ReverseBytes reverses in place
+    leaf := m.levels[0][i]
+    return [32]byte(utils.ReverseBytes(leaf[:]))
+}
+
+// Len returns the number of leaves of the tree
+func (m *MerkleTree) Len() int {
+    if len(m.levels) == 0 {
+        return 0
+    }
+    return len(m.levels[0])
+}
+
+// hashChildren returns the parent of the i-th pair of nodes of the level
+func hashChildren(level [][32]byte, i int) [32]byte {
+    var x [64]byte
+    copy(x[:32], level[2*i][:])
+    if 2*i+1 < len(level) {
+        copy(x[32:], level[2*i+1][:])
+    } else {
+        // in case of an odd number of elements, duplicate the last one
+        copy(x[32:], level[2*i][:])
+    }
+    return utils.DoubleHashRaw(x[:])
+}
+
+// Root returns the merkle root, the same as GenerateMerkleTreeRoot of the leaves. The root of a tree without leaves is zero.
+func (m *MerkleTree) Root() [32]byte {
+    if m.Len() == 0 {
+        return [32]byte{}
+    }
+    return m.levels[len(m.levels)-1][0]
+}
@@ -0,0 +1,121 @@
+package mining
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utils"
+)
+
+func leaf(i int) [32]byte {
+    return sha256.Sum256([]byte{byte(i)})
+}
+
+func leaves(ids ...int) [][32]byte {
+    l := make([][32]byte, 0, len(ids))
+    for _, i := range ids {
+        l = append(l, leaf(i))
+    }
+    return l
+}
+
+func TestMerkleTreeUpdate(t *testing.T) {
+    // the updates are applied one after the other to the same tree
+    tests := []struct {
+        name string
+        txids [][32]byte
+    }{
+        {"build", leaves(1, 2, 3, 4, 5)},
+        {"unchanged", leaves(1, 2, 3, 4, 5)},
+        {"append", leaves(1, 2, 3, 4, 5, 6)},
+        {"append odd", leaves(1, 2, 3, 4, 5, 6, 7)},
+        {"remove", leaves(1, 2, 4, 5, 6, 7)},
+        {"replace last", leaves(1, 2, 4, 5, 6, 8)},
+        {"shrink", leaves(1, 2, 4)},
+        {"shrink to one", leaves(1)},
+        {"grow", leaves(1, 9, 10, 11, 12, 13, 14, 15, 16)},
+        {"replace first", leaves(2, 9, 10, 11, 12, 13, 14, 15, 16)},
+    }
+    var tree MerkleTree
+    for _, test := range tests {
+        tree.Update(test.txids)
+        if got, want := tree.Root(), GenerateMerkleTreeRoot(test.txids); got != want {
+            t.Errorf("%s: root %x, want %x", test.name, got, want)
+        }
+        if tree.Len() != len(test.txids) {
+            t.Errorf("%s: %d leaves, want %d", test.name, tree.Len(), len(test.txids))
+        }
+    }
+}
+
+func TestMerkleTreeUpdateLeaf(t *testing.T) {
+    for _, n := range []int{1, 2, 5, 8} {
+        txids := leaves(1, 2, 3, 4, 5, 6, 7, 8)[:n]
+        var tree MerkleTree
+        tree.Update(txids)
+        for i := 0; i < n; i++ {
+            txids[i] = leaf(100 + i)
+            tree.UpdateLeaf(i, txids[i])
+            if got, want := tree.Root(), GenerateMerkleTreeRoot(txids); got != want {
+                t.Errorf("%d leaves, leaf %d updated: root %x, want %x", n, i, got, want)
+            }
+            if tree.Leaf(i) != txids[i] {
+                t.Errorf("%d leaves: leaf %d is %x, want %x", n, i, tree.Leaf(i), txids[i])
+            }
+        }
+    }
+}
+
+func TestMerkleTreeEmpty(t *testing.T) {
+    var tree MerkleTree
+    if tree.Root() != [32]byte{} {
+        t.Errorf("root of a new tree is %x", tree.Root())
+    }
+    tree.Update(leaves(1, 2))
+    tree.Update(nil)
+    if tree.Root() != [32]byte{} || tree.Len() != 0 {
+        t.Errorf("root of a tree without leaves is %x", tree.Root())
+    }
+}
+
+func testTx(i int, fee int) *txn.Transaction {
+    prevOut := txn.Vout{ScriptPubKey: "51", Value: 100000}
+    return &txn.Transaction{
+        Version: 2,
+        Locktime: uint32(i),
+        Vin: []txn.Vin{{Txid: fmt.Sprintf("%064x", i), PrevOut: prevOut, Sequence: 0xffffffff}},
+        Vout: []txn.Vout{{ScriptPubKey: "51", Value: prevOut.Value - fee}},
+    }
+}
+
+func TestTemplateBuilderMerkleRoot(t *testing.T) {
+    a, b, c, d := testTx(1, 1000), testTx(2, 2000), testTx(3, 500), testTx(4, 3000)
+    // the blocks are built one after the other by the same builder, the coinbase changes with the fees of each of them
+    tests := []struct {
+        name string
+        txns []*txn.Transaction
+    }{
+        {"first", []*txn.Transaction{a, b, c}},
+        {"append", []*txn.Transaction{a, b, c, d}},
+        {"remove", []*txn.Transaction{a, c, d}},
+        {"same transactions", []*txn.Transaction{a, c, d}},
+        {"empty", nil},
+        {"refill", []*txn.Transaction{d, a}},
+    }
+    builder := TemplateBuilder{HasWitness: true}
+    for _, test := range tests {
+        block := builder.Build(test.txns)
+        txids := [][32]byte{[32]byte(utils.ReverseBytes(block.Coinbase.TxHash()))}
+        for _, tx := range test.txns {
+            txids = append(txids, [32]byte(utils.ReverseBytes(tx.TxHash())))
+        }
+        if got, want := block.BlockHeader.MerkleRoot, GenerateMerkleTreeRoot(txids); got != want {
+            t.Errorf("%s: merkle root %x, want %x", test.name, got, want)
+        }
+        if want := GetCandidateBlock(test.txns, nil, true); block.Coinbase.Txid() != want.Coinbase.Txid() {
+            t.Errorf("%s: coinbase %s, want %s", test.name, block.Coinbase.Txid(), want.Coinbase.Txid())
+        }
+    }
+}
@@ -27,47 +27,84 @@ func findValidPrevBlockHash(nBits uint32) [32]byte {
 
 
 
-var MaxBlockWeight = 4000000 - 1000 // 1000 is the buffer amount
+// MaxBlockWeight is the maximum weight of the serialized block, header and coinbase transaction included
+var MaxBlockWeight = 4000000
 
-func GetCandidateBlock(txns []*txn.Transaction, hasWitness bool) Block {
+func GetCandidateBlock(txns []*txn.Transaction, payoutScript []byte, hasWitness bool) Block {
+    b := TemplateBuilder{PayoutScript: payoutScript, HasWitness: hasWitness}
+    return b.Build(txns)
+}
+
+// TemplateBuilder builds successive candidate blocks from changing lists of transactions. The merkle trees of the txids and wtxids are kept between the blocks, and only the nodes above the transactions that changed (and above the coinbase for the txids) are recomputed.
+type TemplateBuilder struct {
+    // PayoutScript is the scriptPubKey the coinbase transaction pays to (see NewCoinbaseTransaction)
+    PayoutScript []byte
+    HasWitness bool
+    txids MerkleTree
+    wtxids MerkleTree
+    // txid and wtxid of the transactions of the last block, to avoid hashing them again
+    hashes map[*txn.Transaction][2][32]byte
+}
+
+// Build returns the candidate block with the transactions, in order, leaving out the ones that would make the block exceed MaxBlockWeight or the sigop limit
+func (b *TemplateBuilder) Build(txns []*txn.Transaction) Block {
     tarDif := new(big.Int)
     fmt.Sscanf(targetDifficultyHexString, "%064x", tarDif)
     candidateBlock := Block{}
-    
-    var blockTxns []*txn.Transaction
 
-    // coinbase transaction
-    cb := NewCoinbaseTransaction(calculateFees(txns))
-    blockTxns = append(blockTxns, &cb)
+    // the weight and sigop cost of the coinbase do not depend on the fees
+    emptyCb := NewCoinbaseTransaction(0, b.PayoutScript)
+    txns = limitTxns(txns, MaxBlockWeight - ReservedWeight(b.PayoutScript, b.HasWitness), txn.MaxBlockSigOpsCost - emptyCb.GetSigOpCost())
+
+    hashes := make(map[*txn.Transaction][2][32]byte, len(txns))
+    txids := make([][32]byte, 1, len(txns)+1)
+    wtxids := make([][32]byte, 1, len(txns)+1)
     for _, t := range txns {
-        blockTxns = append(blockTxns, t)
+        h, ok := b.hashes[t]
+        if !ok {
+            h[0] = [32]byte(utils.ReverseBytes(t.TxHash()))
+            h[1] = h[0]
+            if t.HasWitness() {
+                h[1] = [32]byte(utils.ReverseBytes(t.WitnessHash()))
+            }
+        }
+        hashes[t] = h
+        txids = append(txids, h[0])
+        wtxids = append(wtxids, h[1])
     }
+    b.hashes = hashes
 
-    if(hasWitness){
-        AddWitnessCommitment(&cb, blockTxns)
+    // coinbase transaction, its wtxid is zero
+    cb := NewCoinbaseTransaction(calculateFees(txns), b.PayoutScript)
+    if b.HasWitness {
+        b.wtxids.Update(wtxids)
+        addWitnessCommitment(&cb, b.wtxids.Root())
     }
     candidateBlock.Coinbase = cb
+    // the coinbase changes with the fees of every block: the other leaves are compared with the ones of the last block, and only the path of the coinbase is recomputed
+    if b.txids.Len() > 0 {
+        txids[0] = b.txids.Leaf(0)
+    }
+    b.txids.Update(txids)
+    b.txids.UpdateLeaf(0, [32]byte(utils.ReverseBytes(cb.TxHash())))
 
     // header
     nBits := TargetToNbits(tarDif)
     prevBH,_ := hex.DecodeString(prevBlockHash)
-    txids := make([][32]byte, 0)
-    for _, t := range blockTxns {
-        txids = append(txids, [32]byte(utils.ReverseBytes(t.TxHash())))
-    }
-    header := NewBlockHeader(BlockVersion, *utils.NewHash(prevBH), GenerateMerkleTreeRoot(txids), time.Now().Unix(),nBits, 0)
+    header := NewBlockHeader(BlockVersion, *utils.NewHash(prevBH), b.txids.Root(), time.Now().Unix(),nBits, 0)
     candidateBlock.BlockHeader = header
 
     // transactions
-    for _, t := range blockTxns {
+    candidateBlock.AddTransaction(cb)
+    for _, t := range txns {
         candidateBlock.AddTransaction(*t)
     }
 
     return candidateBlock
 }
 
-func MineBlock(candidateBlock Block, outputFilePath string) error {
-    findNonce(&candidateBlock)
+func MineBlock(candidateBlock *Block, outputFilePath string) error {
+    findNonce(candidateBlock)
     err := candidateBlock.WriteToFile(outputFilePath)
     if err != nil {
         return err
@@ -101,6 +138,39 @@ func findNonce(candidateBlock *Block) uint32 {
     }
 }
 
+// limitTxns returns the transactions, in order, leaving out the ones that would make the total weight exceed maxWeight or the total sigop cost exceed maxSigOpCost.
+// The weight includes the varint giving the number of transactions of the block. Transactions spending outputs of a left out transaction are left out as well.
+func limitTxns(txns []*txn.Transaction, maxWeight int, maxSigOpCost int) []*txn.Transaction {
+    limited := make([]*txn.Transaction, 0, len(txns))
+    dropped := make(map[string]bool)
+    weight, sigOpCost := 0, 0
+    for _, t := range txns {
+        w, cost := t.GetWeight(), t.GetSigOpCost()
+        // +2 for the coinbase and the transaction itself
+        tooHeavy := weight + w + TxCountWeight(len(limited)+2) > maxWeight
+        if tooHeavy || sigOpCost + cost > maxSigOpCost || spendsAny(t, dropped) {
+            dropped[t.Txid()] = true
+            continue
+        }
+        weight += w
+        sigOpCost += cost
+        limited = append(limited, t)
+    }
+    return limited
+}
+
+func spendsAny(t *txn.Transaction, txids map[string]bool) bool {
+    if len(txids) == 0 {
+        return false
+    }
+    for _, in := range t.Vin {
+        if txids[in.Txid] {
+            return true
+        }
+    }
+    return false
+}
+
 func calculateFees(txns []*txn.Transaction) int {
     fees := 0
     for _, t := range txns {
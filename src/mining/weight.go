@@ -0,0 +1,32 @@
+package mining
+
+import (
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// BlockHeaderSize is the size of the serialized block header in bytes
+const BlockHeaderSize = 80
+
+// TxCountWeight returns the weight of the varint giving the number of transactions of a block, count including the coinbase transaction
+func TxCountWeight(count int) int {
+    return txn.VarIntSerializeSize(uint64(count)) * txn.WitnessScaleFactor
+}
+
+// ReservedWeight returns the weight of the header and of the coinbase transaction paying to payoutScript, with its witness commitment if hasWitness is set. The value of the coinbase output is always serialized in 8 bytes, so this does not depend on the fees.
+// The varint giving the number of transactions depends on how many are picked, and is not part of it (see TxCountWeight).
+func ReservedWeight(payoutScript []byte, hasWitness bool) int {
+    cb := NewCoinbaseTransaction(0, payoutScript)
+    if hasWitness {
+        AddWitnessCommitment(&cb, []*txn.Transaction{&cb})
+    }
+    return BlockHeaderSize*txn.WitnessScaleFactor + cb.GetWeight()
+}
+
+// Weight returns the weight of the serialized block
+func (b *Block) Weight() int {
+    weight := BlockHeaderSize*txn.WitnessScaleFactor + TxCountWeight(len(b.Transactions))
+    for _, t := range b.Transactions {
+        weight += t.GetWeight()
+    }
+    return weight
+}
@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// Implemented using the dust and ephemeral dust policy of bitcoin core (GetDustThreshold, PreCheckEphemeralTx and CheckEphemeralSpends) as the reference
+
+var (
+    // DustRelayFeeRate is the fee rate (in sat/vB) below which spending an output costs more than the output is worth
+    DustRelayFeeRate = 3
+    // MaxDustOutputsPerTx is the number of dust outputs a transaction can have, as ephemeral dust
+    MaxDustOutputsPerTx = 1
+)
+
+// scripts bigger than this cannot be executed, so their outputs cannot be spent
+const maxScriptSize = 10000
+
+var (
+    ErrTooManyDust = errors.New("transaction has too many dust outputs")
+    ErrDustWithFee = errors.New("transaction with dust output pays a fee")
+    ErrEphemeralDustUnspent = errors.New("ephemeral dust of an unconfirmed parent is not spent")
+)
+
+// DustThreshold returns the value below which the output is dust: the fee of the input spending it at DustRelayFeeRate, counting the output itself. Unspendable outputs are never dust.
+func DustThreshold(out txn.Vout) int {
+    script, err := hex.DecodeString(out.ScriptPubKey)
+    if err != nil || len(script) > 0 && script[0] == txn.OP_RETURN || len(script) > maxScriptSize {
+        return 0
+    }
+    size := out.SerializeSize()
+    if _, _, ok := txn.ExtractWitnessProgram(script); ok {
+        // outpoint, empty scriptSig, sequence and a signature with its pubkey at the witness discount
+        size += 32 + 4 + 1 + 107/txn.WitnessScaleFactor + 4
+    } else {
+        size += 32 + 4 + 1 + 107 + 4
+    }
+    return size * DustRelayFeeRate
+}
+
+func IsDust(out txn.Vout) bool {
+    return out.Value < DustThreshold(out)
+}
+
+// DustOutputs returns the indexes of the dust outputs of the transaction, whatever its version and the type of its outputs: as in bitcoin core, any transaction can have ephemeral dust (not only TRUC transactions with a P2A anchor), and a dust output is ephemeral dust or makes the transaction non standard
+func DustOutputs(tx *txn.Transaction) []int {
+    dust := make([]int, 0)
+    for i, out := range tx.Vout {
+        if IsDust(out) {
+            dust = append(dust, i)
+        }
+    }
+    return dust
+}
+
+// CheckEphemeralDust checks that a transaction with a dust output (ephemeral dust, an anchor for instance) pays no fee, so that it is only mined along with a child spending the dust. fee is the fee of the transaction without its fee delta.
+func CheckEphemeralDust(tx *txn.Transaction, fee int) error {
+    return TxInfo{Dust: DustOutputs(tx)}.CheckEphemeralDust(fee)
+}
+
+// CheckEphemeralDust is CheckEphemeralDust for the transaction described by i
+func (i TxInfo) CheckEphemeralDust(fee int) error {
+    if len(i.Dust) == 0 {
+        return nil
+    }
+    if len(i.Dust) > MaxDustOutputsPerTx {
+        return fmt.Errorf("%w: %d > %d", ErrTooManyDust, len(i.Dust), MaxDustOutputsPerTx)
+    }
+    if fee != 0 {
+        return fmt.Errorf("%w: %d sats", ErrDustWithFee, fee)
+    }
+    return nil
+}
+
+// CheckEphemeralSpends checks that the transaction spends every dust output of its unconfirmed parents
+func CheckEphemeralSpends(tx *txn.Transaction, parents []*txn.Transaction) error {
+    if len(parents) == 0 {
+        return nil
+    }
+    return NewTxInfo(tx).CheckEphemeralSpends(txInfos(parents))
+}
+
+// CheckEphemeralSpends is CheckEphemeralSpends for the transaction described by i
+func (i TxInfo) CheckEphemeralSpends(parents []TxInfo) error {
+    spent := make(map[txn.OutPoint]bool, len(i.Inputs))
+    for _, op := range i.Inputs {
+        spent[op] = true
+    }
+    for _, p := range parents {
+        for _, d := range p.Dust {
+            if op := txn.NewOutPoint(p.Txid, d); !spent[op] {
+                return fmt.Errorf("%w: %s", ErrEphemeralDustUnspent, op)
+            }
+        }
+    }
+    return nil
+}
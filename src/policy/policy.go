@@ -0,0 +1,40 @@
+package policy
+
+import (
+	"errors"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// ErrCoinbase is returned for a coinbase transaction submitted on its own, as it can only be mined as the first transaction of the block creating it
+var ErrCoinbase = errors.New("coinbase transactions are not accepted")
+
+// TxInfo is what the TRUC and ephemeral dust rules need to know about a transaction, so that they can be checked without holding the full transaction
+type TxInfo struct {
+    Txid string
+    Version int32
+    // Inputs are the outputs spent by the transaction, in the order of its inputs
+    Inputs []txn.OutPoint
+    // Dust are the indexes of the dust outputs of the transaction
+    Dust []int
+}
+
+// NewTxInfo returns what the TRUC and ephemeral dust rules need to know about the transaction
+func NewTxInfo(tx *txn.Transaction) TxInfo {
+    info := TxInfo{Txid: tx.Txid(), Version: tx.Version, Inputs: make([]txn.OutPoint, len(tx.Vin))}
+    for i, in := range tx.Vin {
+        info.Inputs[i] = in.OutPoint()
+    }
+    if dust := DustOutputs(tx); len(dust) > 0 {
+        info.Dust = dust
+    }
+    return info
+}
+
+func txInfos(txns []*txn.Transaction) []TxInfo {
+    infos := make([]TxInfo, 0, len(txns))
+    for _, t := range txns {
+        infos = append(infos, NewTxInfo(t))
+    }
+    return infos
+}
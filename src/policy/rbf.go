@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// Implemented using BIP125 (https://github.com/bitcoin/bips/blob/master/bip-0125.mediawiki) as the reference
+
+var (
+    // IncrementalRelayFeeRate is the fee rate (in sat/vB) a replacement has to pay for its own size on top of the fees of the transactions it replaces
+    IncrementalRelayFeeRate = 1
+    // MinRelayFeeRate is the lowest fee rate (in sat/vB) of a transaction accepted in the mempool
+    MinRelayFeeRate = 1
+    // MaxReplacementEvictions is the maximum number of transactions (direct conflicts and their descendants) a replacement can evict
+    MaxReplacementEvictions = 100
+)
+
+// MaxBIP125RBFSequence is the highest sequence number that signals replaceability
+const MaxBIP125RBFSequence = 0xfffffffd
+
+var (
+    ErrNotReplaceable = errors.New("conflicting transaction does not signal replaceability")
+    ErrInsufficientFee = errors.New("replacement does not pay enough fee")
+    ErrInsufficientFeeRate = errors.New("replacement fee rate is not higher than the replaced transaction")
+    ErrTooManyReplacements = errors.New("replacement evicts too many transactions")
+)
+
+// SignalsReplacement reports whether the transaction explicitly opts in to replacement by having an input with a sequence number below 0xfffffffe (BIP125 rule 1). TRUC transactions are always replaceable.
+func SignalsReplacement(tx *txn.Transaction) bool {
+    if IsTRUC(tx) {
+        return true
+    }
+    for _, in := range tx.Vin {
+        if uint32(in.Sequence) <= MaxBIP125RBFSequence {
+            return true
+        }
+    }
+    return false
+}
+
+// GetVSize returns the virtual size of a transaction of the given weight
+func GetVSize(weight int) int {
+    return (weight + txn.WitnessScaleFactor - 1) / txn.WitnessScaleFactor
+}
+
+// ReplacedTx is a mempool transaction that would be evicted by a replacement
+type ReplacedTx struct {
+    Fee int
+    Weight int
+    // Signals is true if the transaction opts in to replacement
+    Signals bool
+}
+
+// CheckReplacement checks whether a transaction with the given fee and weight can replace the mempool transactions it conflicts with.
+// direct are the transactions spending the same outputs as the replacement, evicted are all the transactions that would be removed: the direct conflicts and all of their descendants.
+// With fullRBF, the direct conflicts do not need to signal replaceability.
+func CheckReplacement(fee int, weight int, direct []ReplacedTx, evicted []ReplacedTx, fullRBF bool) error {
+    // rule 1
+    if !fullRBF {
+        for _, r := range direct {
+            if !r.Signals {
+                return ErrNotReplaceable
+            }
+        }
+    }
+    // rule 5
+    if len(evicted) > MaxReplacementEvictions {
+        return fmt.Errorf("%w: %d > %d", ErrTooManyReplacements, len(evicted), MaxReplacementEvictions)
+    }
+    // rule 6: fee rate of the replacement is compared with every direct conflict, fee1/weight1 > fee2/weight2 is checked by cross multiplying
+    for _, r := range direct {
+        if fee*r.Weight <= r.Fee*weight {
+            return ErrInsufficientFeeRate
+        }
+    }
+    // rule 3 and 4
+    evictedFees := 0
+    for _, r := range evicted {
+        evictedFees += r.Fee
+    }
+    if fee < evictedFees {
+        return fmt.Errorf("%w: %d < %d of the evicted transactions", ErrInsufficientFee, fee, evictedFees)
+    }
+    if relayFee := IncrementalRelayFeeRate * GetVSize(weight); fee-evictedFees < relayFee {
+        return fmt.Errorf("%w: additional fee %d < %d required for relay", ErrInsufficientFee, fee-evictedFees, relayFee)
+    }
+    return nil
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// Implemented using BIP431 (https://github.com/bitcoin/bips/blob/master/bip-0431.mediawiki) and the TRUC policy of bitcoin core (truc_policy.cpp) as the reference
+
+// TRUCVersion is the version of the transactions opting in to the topologically restricted until confirmation (TRUC) rules
+const TRUCVersion = 3
+
+var (
+    // TRUCAncestorLimit is the maximum number of unconfirmed transactions in the ancestor set of a TRUC transaction, itself included
+    TRUCAncestorLimit = 2
+    // TRUCDescendantLimit is the maximum number of unconfirmed transactions in the descendant set of a TRUC transaction, itself included
+    TRUCDescendantLimit = 2
+    // TRUCMaxVSize is the maximum virtual size of a TRUC transaction
+    TRUCMaxVSize = 10000
+    // TRUCChildMaxVSize is the maximum virtual size of a TRUC transaction with an unconfirmed parent
+    TRUCChildMaxVSize = 1000
+)
+
+var (
+    ErrTRUCTooLarge = errors.New("TRUC transaction is too large")
+    ErrTRUCChildTooLarge = errors.New("TRUC child transaction is too large")
+    ErrTRUCTooManyAncestors = errors.New("TRUC transaction would have too many unconfirmed ancestors")
+    ErrTRUCTooManyDescendants = errors.New("TRUC transaction would have too many unconfirmed descendants")
+    ErrTRUCSpendsNonTRUC = errors.New("TRUC transaction spends an unconfirmed non-TRUC transaction")
+    ErrNonTRUCSpendsTRUC = errors.New("non-TRUC transaction spends an unconfirmed TRUC transaction")
+)
+
+// IsTRUC reports whether the transaction opts in to the TRUC rules
+func IsTRUC(tx *txn.Transaction) bool {
+    return tx.Version == TRUCVersion
+}
+
+// IsTRUC reports whether the transaction described by i opts in to the TRUC rules
+func (i TxInfo) IsTRUC() bool {
+    return i.Version == TRUCVersion
+}
+
+// CheckTRUC checks the rules of BIP431 depending on the unconfirmed parents of a transaction: TRUC transactions can only spend unconfirmed TRUC transactions and the other way round, and a TRUC transaction has at most one unconfirmed ancestor, in which case it is small.
+// ancestorCount is the number of unconfirmed ancestors of the transaction, parents included. The limit on the descendants of the parent is left to the caller, as a child above it can evict its sibling instead.
+func CheckTRUC(tx *txn.Transaction, vsize int, parents []*txn.Transaction, ancestorCount int) error {
+    return TxInfo{Version: tx.Version}.CheckTRUC(vsize, txInfos(parents), ancestorCount)
+}
+
+// CheckTRUC is CheckTRUC for the transaction described by i. Only the txids and versions of the parents are used.
+func (i TxInfo) CheckTRUC(vsize int, parents []TxInfo, ancestorCount int) error {
+    if !i.IsTRUC() {
+        for _, p := range parents {
+            if p.IsTRUC() {
+                return fmt.Errorf("%w: %s", ErrNonTRUCSpendsTRUC, p.Txid)
+            }
+        }
+        return nil
+    }
+    for _, p := range parents {
+        if !p.IsTRUC() {
+            return fmt.Errorf("%w: %s", ErrTRUCSpendsNonTRUC, p.Txid)
+        }
+    }
+    if vsize > TRUCMaxVSize {
+        return fmt.Errorf("%w: %d > %d vB", ErrTRUCTooLarge, vsize, TRUCMaxVSize)
+    }
+    if ancestorCount+1 > TRUCAncestorLimit {
+        return fmt.Errorf("%w: %d > %d", ErrTRUCTooManyAncestors, ancestorCount+1, TRUCAncestorLimit)
+    }
+    if len(parents) > 0 && vsize > TRUCChildMaxVSize {
+        return fmt.Errorf("%w: %d > %d vB", ErrTRUCChildTooLarge, vsize, TRUCChildMaxVSize)
+    }
+    return nil
+}
@@ -1,24 +1,20 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
-
+	"github.com/humblenginr/btc-miner/source"
 	txn "github.com/humblenginr/btc-miner/transaction"
 )
 
 
 
 func SelectTransactionsFromPaths(validTxnPaths []string) []*txn.Transaction {
-    txnSlice := make([]*txn.Transaction, 0)
-
-    for _, fileName := range validTxnPaths {
-        var transaction txn.Transaction
-        txnPath := fmt.Sprintf("%s/%s", MempoolDirPath, fileName)
-        byteResult, _ := os.ReadFile(txnPath)
-        json.Unmarshal(byteResult, &transaction)
-        txnSlice = append(txnSlice, &transaction)
+    entries, err := source.DirSource{Path: MempoolDirPath, Names: validTxnPaths}.Load()
+    if err != nil {
+        panic(err)
+    }
+    txnSlice := make([]*txn.Transaction, 0, len(entries))
+    for _, e := range entries {
+        txnSlice = append(txnSlice, e.Tx)
     }
     return txnSlice
 }
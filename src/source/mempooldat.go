@@ -0,0 +1,174 @@
+package source
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utils"
+	"github.com/humblenginr/btc-miner/utxo"
+)
+
+// versions of the mempool.dat format written by bitcoin core (MEMPOOL_DUMP_VERSION_NO_XOR_KEY and MEMPOOL_DUMP_VERSION)
+const (
+    MempoolDatVersionNoXorKey = 1
+    MempoolDatVersion = 2
+)
+
+// MempoolDat is the content of a mempool.dat file
+type MempoolDat struct {
+    Version uint64
+    // XorKey is the key the file is obfuscated with, nil for version 1
+    XorKey []byte
+    // Entries are the mempool transactions with their time and fee delta
+    Entries []Entry
+    // Deltas are the fee deltas (prioritisetransaction) of transactions that were not in the mempool
+    Deltas map[string]int
+    // Unbroadcast are the txids of the transactions that were not broadcast yet
+    Unbroadcast []string
+}
+
+// MempoolDatSource reads a mempool.dat file dumped by bitcoin core.
+// The file does not include the prevouts, so they are resolved using UTXOSet and the outputs of the other transactions of the file. UTXOSet can be nil, in which case only the outputs of the transactions of the file are known.
+type MempoolDatSource struct {
+    Path string
+    UTXOSet utxo.UTXOView
+}
+
+func (s MempoolDatSource) Load() ([]Entry, error) {
+    f, err := os.Open(s.Path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    dat, err := ReadMempoolDat(bufio.NewReader(f))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", s.Path, err)
+    }
+    dat.ResolvePrevOuts(s.UTXOSet)
+    for i := range dat.Entries {
+        dat.Entries[i].FeeDelta += dat.Deltas[dat.Entries[i].Tx.Txid()]
+    }
+    return dat.Entries, nil
+}
+
+// ResolvePrevOuts resolves the prevouts of the transactions using utxoSet and the outputs of the transactions of the file, whatever their order. The PrevOutErr of an entry is set if some of its inputs cannot be resolved.
+func (d *MempoolDat) ResolvePrevOuts(utxoSet utxo.UTXOView) {
+    view := utxo.NewMemoryView(utxoSet)
+    for _, e := range d.Entries {
+        view.AddTxOutputs(e.Tx)
+    }
+    for i := range d.Entries {
+        d.Entries[i].PrevOutErr = utxo.ResolvePrevOuts(d.Entries[i].Tx, view)
+    }
+}
+
+// xorReader undoes the obfuscation of a mempool.dat file. The key is applied according to the position in the file, starting at offset.
+type xorReader struct {
+    r io.Reader
+    key []byte
+    offset int
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+    n, err := x.r.Read(p)
+    if len(x.key) > 0 {
+        for i := 0; i < n; i++ {
+            p[i] ^= x.key[(x.offset+i)%len(x.key)]
+        }
+    }
+    x.offset += n
+    return n, err
+}
+
+// ReadMempoolDat decodes a mempool.dat file: the version, the xor key (version 2 only), the transactions along with the time they entered the mempool and their fee delta, the map of fee deltas and the set of unbroadcast txids.
+// The prevouts of the transactions are left empty.
+func ReadMempoolDat(r io.Reader) (*MempoolDat, error) {
+    d := &MempoolDat{Deltas: make(map[string]int)}
+    x := &xorReader{r: r}
+    var err error
+    if d.Version, err = readUint64(x); err != nil {
+        return nil, err
+    }
+    switch d.Version {
+    case MempoolDatVersionNoXorKey:
+    case MempoolDatVersion:
+        if d.XorKey, err = txn.ReadVarBytes(x, 8); err != nil {
+            return nil, fmt.Errorf("xor key: %w", err)
+        }
+        // everything after the key is obfuscated
+        x.key = d.XorKey
+    default:
+        return nil, fmt.Errorf("unsupported mempool.dat version %d", d.Version)
+    }
+
+    count, err := readUint64(x)
+    if err != nil {
+        return nil, err
+    }
+    for i := uint64(0); i < count; i++ {
+        tx := &txn.Transaction{}
+        if err := tx.Deserialize(x); err != nil {
+            return nil, fmt.Errorf("transaction %d: %w", i, err)
+        }
+        time, err := readUint64(x)
+        if err != nil {
+            return nil, fmt.Errorf("transaction %d: %w", i, err)
+        }
+        feeDelta, err := readUint64(x)
+        if err != nil {
+            return nil, fmt.Errorf("transaction %d: %w", i, err)
+        }
+        d.Entries = append(d.Entries, Entry{Name: tx.Txid(), Tx: tx, Time: int64(time), FeeDelta: int(int64(feeDelta))})
+    }
+
+    count, err = txn.ReadVarInt(x)
+    if err != nil {
+        return nil, fmt.Errorf("fee deltas: %w", err)
+    }
+    for i := uint64(0); i < count; i++ {
+        txid, err := readHash(x)
+        if err != nil {
+            return nil, fmt.Errorf("fee deltas: %w", err)
+        }
+        delta, err := readUint64(x)
+        if err != nil {
+            return nil, fmt.Errorf("fee deltas: %w", err)
+        }
+        d.Deltas[txid] = int(int64(delta))
+    }
+
+    count, err = txn.ReadVarInt(x)
+    if err != nil {
+        return nil, fmt.Errorf("unbroadcast txids: %w", err)
+    }
+    for i := uint64(0); i < count; i++ {
+        txid, err := readHash(x)
+        if err != nil {
+            return nil, fmt.Errorf("unbroadcast txids: %w", err)
+        }
+        d.Unbroadcast = append(d.Unbroadcast, txid)
+    }
+    return d, nil
+}
+
+func readUint64(r io.Reader) (uint64, error) {
+    buffer := make([]byte, 8)
+    if _, err := io.ReadFull(r, buffer); err != nil {
+        return 0, err
+    }
+    return binary.LittleEndian.Uint64(buffer), nil
+}
+
+// readHash reads a 32 byte hash in natural byte order and returns it in the display (reversed) hex form used for txids
+func readHash(r io.Reader) (string, error) {
+    hash := make([]byte, 32)
+    if _, err := io.ReadFull(r, hash); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(utils.ReverseBytes(hash)), nil
+}
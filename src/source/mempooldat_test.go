@@ -0,0 +1,163 @@
+package source
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utils"
+)
+
+type datTx struct {
+    tx *txn.Transaction
+    time int64
+    feeDelta int64
+}
+
+func putUint64(b *bytes.Buffer, v uint64) {
+    var buffer [8]byte
+    binary.LittleEndian.PutUint64(buffer[:], v)
+    b.Write(buffer[:])
+}
+
+func putHash(b *bytes.Buffer, txid string) {
+    hash, _ := hex.DecodeString(txid)
+    b.Write(utils.ReverseBytes(hash))
+}
+
+// encodeMempoolDat serializes a mempool.dat file the way bitcoin core dumps it (DumpMempool)
+func encodeMempoolDat(version uint64, key []byte, txns []datTx, deltas map[string]int64, unbroadcast []string) []byte {
+    var header, body bytes.Buffer
+    putUint64(&header, version)
+    if version == MempoolDatVersion {
+        txn.WriteVarBytes(&header, key)
+    }
+    putUint64(&body, uint64(len(txns)))
+    for _, t := range txns {
+        t.tx.Serialize(true, &body)
+        putUint64(&body, uint64(t.time))
+        putUint64(&body, uint64(t.feeDelta))
+    }
+    txn.WriteVarInt(&body, uint64(len(deltas)))
+    for txid, delta := range deltas {
+        putHash(&body, txid)
+        putUint64(&body, uint64(delta))
+    }
+    txn.WriteVarInt(&body, uint64(len(unbroadcast)))
+    for _, txid := range unbroadcast {
+        putHash(&body, txid)
+    }
+    b := body.Bytes()
+    // the key is applied according to the position in the file, the header included
+    if len(key) > 0 {
+        for i := range b {
+            b[i] ^= key[(header.Len()+i)%len(key)]
+        }
+    }
+    return append(header.Bytes(), b...)
+}
+
+func TestReadMempoolDat(t *testing.T) {
+    parent, child := readTx(t, parentFile), readTx(t, childFile)
+    txns := []datTx{{parent, 1700000000, 0}, {child, 1700000060, -500}}
+    other := "00000000000000000000000000000000000000000000000000000000000000ff"
+    deltas := map[string]int64{other: 1000}
+    unbroadcast := []string{child.Txid()}
+    key := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+
+    tests := []struct {
+        name string
+        version uint64
+        key []byte
+    }{
+        {"version 1", MempoolDatVersionNoXorKey, nil},
+        {"version 2", MempoolDatVersion, key},
+        // bitcoin core writes a zero key when obfuscation is disabled
+        {"version 2 without obfuscation", MempoolDatVersion, make([]byte, 8)},
+    }
+    for _, test := range tests {
+        raw := encodeMempoolDat(test.version, test.key, txns, deltas, unbroadcast)
+        d, err := ReadMempoolDat(bytes.NewReader(raw))
+        if err != nil {
+            t.Fatalf("%s: %v", test.name, err)
+        }
+        if d.Version != test.version || !bytes.Equal(d.XorKey, test.key) {
+            t.Errorf("%s: version %d, key %x", test.name, d.Version, d.XorKey)
+        }
+        if len(d.Entries) != 2 {
+            t.Fatalf("%s: %d entries", test.name, len(d.Entries))
+        }
+        for i, e := range d.Entries {
+            if e.Tx.Txid() != txns[i].tx.Txid() || e.Tx.Wtxid() != txns[i].tx.Wtxid() || e.Name != e.Tx.Txid() {
+                t.Errorf("%s: entry %d is %s", test.name, i, e.Name)
+            }
+            if e.Time != txns[i].time || e.FeeDelta != int(txns[i].feeDelta) {
+                t.Errorf("%s: entry %d: time %d, fee delta %d", test.name, i, e.Time, e.FeeDelta)
+            }
+            if e.Tx.Vin[0].PrevOut.ScriptPubKey != "" {
+                t.Errorf("%s: entry %d has a prevout", test.name, i)
+            }
+        }
+        if len(d.Deltas) != 1 || d.Deltas[other] != 1000 {
+            t.Errorf("%s: deltas %v", test.name, d.Deltas)
+        }
+        if len(d.Unbroadcast) != 1 || d.Unbroadcast[0] != child.Txid() {
+            t.Errorf("%s: unbroadcast %v", test.name, d.Unbroadcast)
+        }
+    }
+
+    // the obfuscated file does not contain the serialized transactions
+    if bytes.Contains(encodeMempoolDat(MempoolDatVersion, key, txns, nil, nil), parent.RawHex()) {
+        t.Error("version 2 file is not obfuscated")
+    }
+}
+
+func TestReadMempoolDatErrors(t *testing.T) {
+    parent := readTx(t, parentFile)
+    valid := encodeMempoolDat(MempoolDatVersion, []byte{1, 2, 3, 4, 5, 6, 7, 8}, []datTx{{parent, 1, 0}}, nil, nil)
+    var badVersion bytes.Buffer
+    putUint64(&badVersion, 3)
+    tests := map[string][]byte{
+        "empty": nil,
+        "unknown version": badVersion.Bytes(),
+        "truncated transaction": valid[:40],
+        "missing unbroadcast set": valid[:len(valid)-1],
+    }
+    for name, raw := range tests {
+        if _, err := ReadMempoolDat(bytes.NewReader(raw)); err == nil {
+            t.Errorf("%s: no error", name)
+        }
+    }
+}
+
+func TestMempoolDatSource(t *testing.T) {
+    parent, child := readTx(t, parentFile), readTx(t, childFile)
+    // the child comes first, its prevouts are resolved from the parent anyway
+    raw := encodeMempoolDat(MempoolDatVersion, []byte{9, 8, 7, 6, 5, 4, 3, 2}, []datTx{{child, 2, 100}, {parent, 1, 0}}, map[string]int64{child.Txid(): 50}, nil)
+    path := filepath.Join(t.TempDir(), "mempool.dat")
+    if err := os.WriteFile(path, raw, 0644); err != nil {
+        t.Fatal(err)
+    }
+    entries, err := MempoolDatSource{Path: path}.Load()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(entries) != 2 {
+        t.Fatalf("%d entries", len(entries))
+    }
+    // the deltas of the map are added to the ones of the entries
+    if entries[0].FeeDelta != 150 || entries[1].FeeDelta != 0 {
+        t.Errorf("fee deltas %d and %d, want 150 and 0", entries[0].FeeDelta, entries[1].FeeDelta)
+    }
+    if entries[0].PrevOutErr != nil || entries[0].Tx.Vin[0].PrevOut != parent.Vout[child.Vin[0].Vout] {
+        t.Errorf("prevout of the child not resolved: %v", entries[0].PrevOutErr)
+    }
+    // without a utxo set, the inputs of the parent are unknown
+    if entries[1].PrevOutErr == nil {
+        t.Error("prevouts of the parent resolved without a utxo set")
+    }
+}
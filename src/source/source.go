@@ -0,0 +1,180 @@
+package source
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utxo"
+)
+
+// maxLineSize is the longest line accepted in the line based formats, enough for any transaction fitting in a block
+const maxLineSize = 16 * 1024 * 1024
+
+// Entry is a transaction read from a mempool source, along with where it was read from (the file name, or the line number for the line based formats)
+type Entry struct {
+    Name string
+    Tx *txn.Transaction
+    // Time is when the transaction entered the mempool (unix time), zero if the source does not tell
+    Time int64
+    // FeeDelta is added to the fee of the transaction when picking transactions (prioritisetransaction), it does not change the fee actually paid
+    FeeDelta int
+    // PrevOutErr is set by the formats that do not include the prevouts when some of them could not be resolved, naming the first such outpoint. That input and the ones after it keep empty prevouts.
+    PrevOutErr error
+}
+
+// MempoolSource provides the transactions of a mempool. The entries are returned in the order they are found in the source.
+type MempoolSource interface {
+    Load() ([]Entry, error)
+}
+
+// DirSource reads a folder with one transaction per file, in the Esplora JSON format
+type DirSource struct {
+    Path string
+    // Names, if set, are the only files of the folder that are read
+    Names []string
+}
+
+func (s DirSource) Load() ([]Entry, error) {
+    names, err := s.names()
+    if err != nil {
+        return nil, err
+    }
+    entries := make([]Entry, 0, len(names))
+    for _, name := range names {
+        byteResult, err := os.ReadFile(filepath.Join(s.Path, name))
+        if err != nil {
+            return nil, err
+        }
+        var transaction txn.Transaction
+        if err := json.Unmarshal(byteResult, &transaction); err != nil {
+            return nil, fmt.Errorf("%s: %w", name, err)
+        }
+        entries = append(entries, Entry{Name: name, Tx: &transaction})
+    }
+    return entries, nil
+}
+
+// JSONLinesSource reads a file with one transaction per line, in the Esplora JSON format. Empty lines are skipped.
+type JSONLinesSource struct {
+    Path string
+}
+
+func (s JSONLinesSource) Load() ([]Entry, error) {
+    f, err := os.Open(s.Path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return ReadJSONLines(f)
+}
+
+// HexSource reads a file with one hex encoded serialized transaction per line. Empty lines are skipped.
+// The serialization does not include the prevouts, so they are resolved using UTXOSet and the outputs of the transactions found earlier in the file. UTXOSet can be nil, in which case only the outputs of the earlier transactions are known.
+type HexSource struct {
+    Path string
+    UTXOSet utxo.UTXOView
+}
+
+func (s HexSource) Load() ([]Entry, error) {
+    f, err := os.Open(s.Path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return ReadHexLines(f, s.UTXOSet)
+}
+
+// ReaderSource reads the transactions from Reader (stdin for instance), either in the JSON lines format (Format "jsonl") or the hex format (Format "hex")
+type ReaderSource struct {
+    Reader io.Reader
+    Format string
+    UTXOSet utxo.UTXOView
+}
+
+func (s ReaderSource) Load() ([]Entry, error) {
+    switch s.Format {
+    case "jsonl":
+        return ReadJSONLines(s.Reader)
+    case "hex":
+        return ReadHexLines(s.Reader, s.UTXOSet)
+    }
+    return nil, fmt.Errorf("unknown format %q for reading transactions from a stream", s.Format)
+}
+
+// ReadJSONLines reads transactions in the Esplora JSON format, one per line. The entries are named after the line they were found on.
+func ReadJSONLines(r io.Reader) ([]Entry, error) {
+    entries := make([]Entry, 0)
+    err := forEachLine(r, func(name string, line string) error {
+        var transaction txn.Transaction
+        if err := json.Unmarshal([]byte(line), &transaction); err != nil {
+            return err
+        }
+        entries = append(entries, Entry{Name: name, Tx: &transaction})
+        return nil
+    })
+    return entries, err
+}
+
+// ReadHexLines reads hex encoded serialized transactions, one per line, resolving their prevouts using utxoSet and the outputs of the earlier transactions. The entries are named after the line they were found on.
+func ReadHexLines(r io.Reader, utxoSet utxo.UTXOView) ([]Entry, error) {
+    view := utxo.NewMemoryView(utxoSet)
+    entries := make([]Entry, 0)
+    err := forEachLine(r, func(name string, line string) error {
+        transaction, err := txn.NewTransactionFromHex(line)
+        if err != nil {
+            return err
+        }
+        err = utxo.ResolvePrevOuts(transaction, view)
+        view.AddTxOutputs(transaction)
+        entries = append(entries, Entry{Name: name, Tx: transaction, PrevOutErr: err})
+        return nil
+    })
+    return entries, err
+}
+
+// forEachLine calls fn with every non empty line of r, trimmed, and the name of the line ("line N")
+func forEachLine(r io.Reader, fn func(name string, line string) error) error {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+    lineNumber := 0
+    for scanner.Scan() {
+        lineNumber++
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+        name := fmt.Sprintf("line %d", lineNumber)
+        if err := fn(name, line); err != nil {
+            return fmt.Errorf("%s: %w", name, err)
+        }
+    }
+    return scanner.Err()
+}
+
+// New returns the source reading path in the given format: "dir" (a folder of JSON files), "jsonl", "hex" or "mempooldat" (a mempool.dat file of bitcoin core). The path "-" reads the jsonl and hex formats from stdin.
+// utxoSet is used to resolve the prevouts of the hex and mempooldat formats.
+func New(format string, path string, utxoSet utxo.UTXOView) (MempoolSource, error) {
+    if path == "-" {
+        if format != "jsonl" && format != "hex" {
+            return nil, fmt.Errorf("the %q format cannot be read from stdin", format)
+        }
+        return ReaderSource{Reader: os.Stdin, Format: format, UTXOSet: utxoSet}, nil
+    }
+    switch format {
+    case "dir":
+        return DirSource{Path: path}, nil
+    case "jsonl":
+        return JSONLinesSource{Path: path}, nil
+    case "hex":
+        return HexSource{Path: path, UTXOSet: utxoSet}, nil
+    case "mempooldat":
+        return MempoolDatSource{Path: path, UTXOSet: utxoSet}, nil
+    }
+    return nil, fmt.Errorf("unknown mempool format %q (expected dir, jsonl, hex or mempooldat)", format)
+}
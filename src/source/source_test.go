@@ -0,0 +1,115 @@
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utxo"
+)
+
+// a mainnet transaction and its child
+const (
+    parentFile = "91a58688c0cf2a504866a07e463bf3034a811a4b7201c7d309a26ad8ac382889.json"
+    childFile = "0404b9545838693dac96a8e16916cb7fc5bbbc44b9c8fa42ee34a41648b735a3.json"
+)
+
+func readTx(t *testing.T, name string) *txn.Transaction {
+    b, err := os.ReadFile(filepath.Join("testdata", name))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var tx txn.Transaction
+    if err := json.Unmarshal(b, &tx); err != nil {
+        t.Fatal(err)
+    }
+    return &tx
+}
+
+func hexLines(txns ...*txn.Transaction) string {
+    var sb strings.Builder
+    for _, t := range txns {
+        fmt.Fprintf(&sb, "%x\n\n", t.RawHex())
+    }
+    return sb.String()
+}
+
+func TestReadHexLines(t *testing.T) {
+    parent, child := readTx(t, parentFile), readTx(t, childFile)
+    confirmed := utxo.NewMemoryView(nil)
+    for _, in := range parent.Vin {
+        confirmed.AddUTXO(in.OutPoint(), in.PrevOut)
+    }
+
+    tests := []struct {
+        name string
+        lines string
+        utxoSet utxo.UTXOView
+        // whether the prevouts of each entry are resolved
+        resolved []bool
+    }{
+        {"without utxo set", hexLines(parent, child), nil, []bool{false, true}},
+        {"with utxo set", hexLines(parent, child), confirmed, []bool{true, true}},
+        // the outputs of the later transactions are not known yet
+        {"child first", hexLines(child, parent), confirmed, []bool{false, true}},
+    }
+    for _, test := range tests {
+        entries, err := ReadHexLines(strings.NewReader(test.lines), test.utxoSet)
+        if err != nil {
+            t.Fatalf("%s: %v", test.name, err)
+        }
+        if len(entries) != len(test.resolved) {
+            t.Fatalf("%s: %d entries, want %d", test.name, len(entries), len(test.resolved))
+        }
+        for i, e := range entries {
+            if want := fmt.Sprintf("line %d", 2*i+1); e.Name != want {
+                t.Errorf("%s: entry %d named %q, want %q", test.name, i, e.Name, want)
+            }
+            if test.resolved[i] != (e.PrevOutErr == nil) {
+                t.Errorf("%s: entry %d: unexpected prevout error %v", test.name, i, e.PrevOutErr)
+                continue
+            }
+            if e.PrevOutErr != nil {
+                if !errors.Is(e.PrevOutErr, utxo.ErrNotFound) || !strings.Contains(e.PrevOutErr.Error(), e.Tx.Vin[0].OutPoint().String()) {
+                    t.Errorf("%s: entry %d: error %v does not name the missing outpoint", test.name, i, e.PrevOutErr)
+                }
+                continue
+            }
+            for j, in := range e.Tx.Vin {
+                if in.PrevOut.ScriptPubKey == "" || in.PrevOut.Value == 0 {
+                    t.Errorf("%s: entry %d: input %d not resolved", test.name, i, j)
+                }
+            }
+        }
+    }
+
+    if _, err := ReadHexLines(strings.NewReader("00\n"), nil); err == nil || !strings.HasPrefix(err.Error(), "line 1") {
+        t.Errorf("ReadHexLines() of an invalid transaction returned %v", err)
+    }
+}
+
+func TestNew(t *testing.T) {
+    tests := []struct {
+        format string
+        path string
+        ok bool
+    }{
+        {"dir", "mempool", true},
+        {"jsonl", "-", true},
+        {"hex", "-", true},
+        {"mempooldat", "mempool.dat", true},
+        {"dir", "-", false},
+        {"mempooldat", "-", false},
+        {"csv", "mempool.csv", false},
+    }
+    for _, test := range tests {
+        if _, err := New(test.format, test.path, nil); (err == nil) != test.ok {
+            t.Errorf("New(%q, %q) returned %v", test.format, test.path, err)
+        }
+    }
+}
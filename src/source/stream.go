@@ -0,0 +1,123 @@
+package source
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// Location tells where a transaction was read from in a source, so that it can be read again
+type Location struct {
+    // Name is the name of the entry (the file name, or the line for the line based formats)
+    Name string
+    // Offset is the position of the transaction in the file, zero for the formats with a file per transaction
+    Offset int64
+}
+
+// StreamSource is a mempool source able to give its transactions one at a time, without holding all of them in memory, and to read a transaction again from where it was found.
+type StreamSource interface {
+    MempoolSource
+    // Stream calls fn with every transaction of the source, in order. The transactions that cannot be read are passed to skip, along with the error, and the streaming goes on. An error is returned only if the source itself cannot be read.
+    Stream(fn func(e Entry, loc Location), skip func(name string, err error)) error
+    // Reload reads the transaction found at loc by Stream again
+    Reload(loc Location) (*txn.Transaction, error)
+}
+
+func (s DirSource) names() ([]string, error) {
+    if s.Names != nil {
+        return s.Names, nil
+    }
+    files, err := os.ReadDir(s.Path)
+    if err != nil {
+        return nil, err
+    }
+    names := make([]string, 0, len(files))
+    for _, f := range files {
+        names = append(names, f.Name())
+    }
+    return names, nil
+}
+
+func (s DirSource) Stream(fn func(e Entry, loc Location), skip func(name string, err error)) error {
+    names, err := s.names()
+    if err != nil {
+        return err
+    }
+    for _, name := range names {
+        loc := Location{Name: name}
+        transaction, err := s.Reload(loc)
+        if err != nil {
+            skip(name, err)
+            continue
+        }
+        fn(Entry{Name: name, Tx: transaction}, loc)
+    }
+    return nil
+}
+
+func (s DirSource) Reload(loc Location) (*txn.Transaction, error) {
+    byteResult, err := os.ReadFile(filepath.Join(s.Path, loc.Name))
+    if err != nil {
+        return nil, err
+    }
+    var transaction txn.Transaction
+    if err := json.Unmarshal(byteResult, &transaction); err != nil {
+        return nil, err
+    }
+    return &transaction, nil
+}
+
+func (s JSONLinesSource) Stream(fn func(e Entry, loc Location), skip func(name string, err error)) error {
+    f, err := os.Open(s.Path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    r := bufio.NewReader(f)
+    offset := int64(0)
+    for lineNumber := 1; ; lineNumber++ {
+        line, err := r.ReadString('\n')
+        if err != nil && err != io.EOF {
+            return err
+        }
+        loc := Location{Name: fmt.Sprintf("line %d", lineNumber), Offset: offset}
+        offset += int64(len(line))
+        if trimmed := strings.TrimSpace(line); trimmed != "" {
+            var transaction txn.Transaction
+            if err := json.Unmarshal([]byte(trimmed), &transaction); err != nil {
+                skip(loc.Name, err)
+            } else {
+                fn(Entry{Name: loc.Name, Tx: &transaction}, loc)
+            }
+        }
+        if err == io.EOF {
+            return nil
+        }
+    }
+}
+
+func (s JSONLinesSource) Reload(loc Location) (*txn.Transaction, error) {
+    f, err := os.Open(s.Path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    if _, err := f.Seek(loc.Offset, io.SeekStart); err != nil {
+        return nil, err
+    }
+    line, err := bufio.NewReaderSize(f, 64*1024).ReadString('\n')
+    if err != nil && err != io.EOF {
+        return nil, err
+    }
+    var transaction txn.Transaction
+    if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &transaction); err != nil {
+        return nil, fmt.Errorf("%s: %w", loc.Name, err)
+    }
+    return &transaction, nil
+}
@@ -0,0 +1,176 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/humblenginr/btc-miner/utils"
+)
+
+// maxTxItems limits the number of inputs, outputs and witness items read, so that a malformed length cannot make the decoder allocate too much. No transaction fitting in a block can have more.
+const maxTxItems = 4000000 / 10
+
+// maxScriptSize limits the size of the scripts and witness items read
+const maxScriptSize = 4000000
+
+var ErrTrailingData = errors.New("trailing data after the transaction")
+
+// Deserialize decodes a transaction in the serialization format used by Serialize, with or without witness data.
+// The asm, type and address fields are computed from the scripts. The prevouts of the inputs are not part of the serialization, so they are left empty and have to be resolved separately (see utxo.ResolvePrevOuts).
+func (t *Transaction) Deserialize(r io.Reader) error {
+    buffer := make([]byte, 4)
+    if _, err := io.ReadFull(r, buffer); err != nil {
+        return err
+    }
+    t.Version = int32(binary.LittleEndian.Uint32(buffer))
+
+    inCount, err := ReadVarInt(r)
+    if err != nil {
+        return err
+    }
+    // an empty input list is the marker of the witness serialization, followed by the flag
+    hasWitness := false
+    if inCount == 0 {
+        flag := make([]byte, 1)
+        if _, err := io.ReadFull(r, flag); err != nil {
+            return err
+        }
+        if flag[0] != 0x01 {
+            return fmt.Errorf("invalid witness flag %d", flag[0])
+        }
+        hasWitness = true
+        if inCount, err = ReadVarInt(r); err != nil {
+            return err
+        }
+    }
+    if inCount > maxTxItems {
+        return fmt.Errorf("too many inputs: %d", inCount)
+    }
+    t.Vin = make([]Vin, inCount)
+    for i := range t.Vin {
+        if err := readTxInput(r, &t.Vin[i]); err != nil {
+            return fmt.Errorf("input %d: %w", i, err)
+        }
+    }
+
+    outCount, err := ReadVarInt(r)
+    if err != nil {
+        return err
+    }
+    if outCount > maxTxItems {
+        return fmt.Errorf("too many outputs: %d", outCount)
+    }
+    t.Vout = make([]Vout, outCount)
+    for i := range t.Vout {
+        if err := readTxOutput(r, &t.Vout[i]); err != nil {
+            return fmt.Errorf("output %d: %w", i, err)
+        }
+    }
+
+    if hasWitness {
+        for i := range t.Vin {
+            witness, err := readTxWitness(r)
+            if err != nil {
+                return fmt.Errorf("witness of input %d: %w", i, err)
+            }
+            t.Vin[i].Witness = witness
+        }
+    }
+
+    if _, err := io.ReadFull(r, buffer); err != nil {
+        return err
+    }
+    t.Locktime = binary.LittleEndian.Uint32(buffer)
+    return nil
+}
+
+// NewTransactionFromHex decodes a hex encoded serialized transaction
+func NewTransactionFromHex(s string) (*Transaction, error) {
+    raw, err := hex.DecodeString(s)
+    if err != nil {
+        return nil, err
+    }
+    r := bytes.NewReader(raw)
+    t := &Transaction{}
+    if err := t.Deserialize(r); err != nil {
+        return nil, err
+    }
+    if r.Len() != 0 {
+        return nil, ErrTrailingData
+    }
+    return t, nil
+}
+
+func readTxInput(r io.Reader, ti *Vin) error {
+    // reference output transaction id, in natural byte order
+    txid := make([]byte, 32)
+    if _, err := io.ReadFull(r, txid); err != nil {
+        return err
+    }
+    ti.Txid = hex.EncodeToString(utils.ReverseBytes(txid))
+    buffer := make([]byte, 4)
+    if _, err := io.ReadFull(r, buffer); err != nil {
+        return err
+    }
+    index := binary.LittleEndian.Uint32(buffer)
+    ti.Vout = int(index)
+    sigScript, err := ReadVarBytes(r, maxScriptSize)
+    if err != nil {
+        return err
+    }
+    ti.ScriptSig = hex.EncodeToString(sigScript)
+    ti.ScriptSigAsm = DisassembleScript(sigScript)
+    if _, err := io.ReadFull(r, buffer); err != nil {
+        return err
+    }
+    ti.Sequence = int(binary.LittleEndian.Uint32(buffer))
+    ti.IsCoinbase = index == 0xffffffff && bytes.Equal(txid, make([]byte, 32))
+    return nil
+}
+
+func readTxOutput(r io.Reader, to *Vout) error {
+    buffer := make([]byte, 8)
+    if _, err := io.ReadFull(r, buffer); err != nil {
+        return err
+    }
+    value := binary.LittleEndian.Uint64(buffer)
+    pkScript, err := ReadVarBytes(r, maxScriptSize)
+    if err != nil {
+        return err
+    }
+    out := Vout{ScriptPubKey: hex.EncodeToString(pkScript), Value: int(value)}.ToEsploraVout()
+    *to = Vout{
+        ScriptPubKey: out.ScriptPubKey,
+        ScriptPubKeyAsm: out.ScriptPubKeyAsm,
+        ScriptPubKeyType: out.ScriptPubKeyType,
+        ScriptPubKeyAddr: out.ScriptPubKeyAddr,
+        Value: out.Value,
+    }
+    return nil
+}
+
+func readTxWitness(r io.Reader) ([]string, error) {
+    count, err := ReadVarInt(r)
+    if err != nil {
+        return nil, err
+    }
+    if count > maxTxItems {
+        return nil, fmt.Errorf("too many witness items: %d", count)
+    }
+    if count == 0 {
+        return nil, nil
+    }
+    witness := make([]string, count)
+    for i := range witness {
+        item, err := ReadVarBytes(r, maxScriptSize)
+        if err != nil {
+            return nil, err
+        }
+        witness[i] = hex.EncodeToString(item)
+    }
+    return witness, nil
+}
@@ -0,0 +1,125 @@
+package transaction
+
+import (
+	"encoding/hex"
+
+	"github.com/humblenginr/btc-miner/address"
+)
+
+// AddressNetwork is the network used to compute the addresses of the outputs when exporting transactions
+var AddressNetwork = &address.MainNetParams
+
+// TxStatus is the confirmation status of a transaction, as given by the Esplora API
+type TxStatus struct {
+    Confirmed bool `json:"confirmed"`
+    BlockHeight int `json:"block_height,omitempty"`
+    BlockHash string `json:"block_hash,omitempty"`
+    BlockTime int64 `json:"block_time,omitempty"`
+}
+
+type EsploraVout struct {
+    ScriptPubKey string `json:"scriptpubkey"`
+    ScriptPubKeyAsm string `json:"scriptpubkey_asm"`
+    ScriptPubKeyType ScriptPubKeyType `json:"scriptpubkey_type"`
+    ScriptPubKeyAddr string `json:"scriptpubkey_address,omitempty"`
+    Value int `json:"value"`
+}
+
+type EsploraVin struct {
+    Txid string `json:"txid"`
+    Vout int `json:"vout"`
+    // nil for coinbase inputs
+    PrevOut *EsploraVout `json:"prevout"`
+    ScriptSig string `json:"scriptsig"`
+    ScriptSigAsm string `json:"scriptsig_asm"`
+    Witness []string `json:"witness,omitempty"`
+    IsCoinbase bool `json:"is_coinbase"`
+    Sequence int `json:"sequence"`
+    InnerRedeemScriptAsm string `json:"inner_redeemscript_asm,omitempty"`
+    InnerWitnessScriptAsm string `json:"inner_witnessscript_asm,omitempty"`
+}
+
+// EsploraTx is a transaction in the format returned by the Esplora API (and used by the mempool files), including the fields computed from the transaction.
+type EsploraTx struct {
+    Txid string `json:"txid"`
+    Version int32 `json:"version"`
+    Locktime uint32 `json:"locktime"`
+    Vin []EsploraVin `json:"vin"`
+    Vout []EsploraVout `json:"vout"`
+    Size int `json:"size"`
+    Weight int `json:"weight"`
+    Fee int `json:"fee"`
+    Status TxStatus `json:"status"`
+}
+
+// ToEsploraVout recomputes the asm, type and address of the output from its scriptPubKey
+func (o Vout) ToEsploraVout() EsploraVout {
+    script, _ := hex.DecodeString(o.ScriptPubKey)
+    ev := EsploraVout{
+        ScriptPubKey: o.ScriptPubKey,
+        ScriptPubKeyAsm: DisassembleScript(script),
+        ScriptPubKeyType: ClassifyScript(script),
+        Value: o.Value,
+    }
+    if addr, err := address.FromScriptPubKey(script, AddressNetwork); err == nil {
+        ev.ScriptPubKeyAddr = addr.String()
+    }
+    return ev
+}
+
+func (i Vin) toEsploraVin() EsploraVin {
+    scriptSig, _ := hex.DecodeString(i.ScriptSig)
+    ev := EsploraVin{
+        Txid: i.Txid,
+        Vout: i.Vout,
+        ScriptSig: i.ScriptSig,
+        ScriptSigAsm: DisassembleScript(scriptSig),
+        Witness: i.Witness,
+        IsCoinbase: i.IsCoinbase,
+        Sequence: i.Sequence,
+    }
+    if i.IsCoinbase {
+        return ev
+    }
+    prevOut := i.PrevOut.ToEsploraVout()
+    ev.PrevOut = &prevOut
+
+    // the scripts revealed by the input
+    pkScript, _ := hex.DecodeString(i.PrevOut.ScriptPubKey)
+    if IsPayToScriptHash(pkScript) {
+        if redeemScript, ok := lastPush(scriptSig); ok {
+            ev.InnerRedeemScriptAsm = DisassembleScript(redeemScript)
+            pkScript = redeemScript
+        }
+    }
+    if version, program, ok := ExtractWitnessProgram(pkScript); ok && version == 0 && len(program) == 32 && len(i.Witness) > 0 {
+        witnessScript, _ := hex.DecodeString(i.Witness[len(i.Witness)-1])
+        ev.InnerWitnessScriptAsm = DisassembleScript(witnessScript)
+    }
+    return ev
+}
+
+// ToEsplora converts the transaction to the Esplora format with the given status
+func (t Transaction) ToEsplora(status TxStatus) EsploraTx {
+    et := EsploraTx{
+        Txid: t.Txid(),
+        Version: t.Version,
+        Locktime: t.Locktime,
+        Vin: make([]EsploraVin, 0, len(t.Vin)),
+        Vout: make([]EsploraVout, 0, len(t.Vout)),
+        Size: t.SerializeSize(true),
+        Weight: t.GetWeight(),
+        Status: status,
+    }
+    for _, in := range t.Vin {
+        et.Vin = append(et.Vin, in.toEsploraVin())
+    }
+    for _, out := range t.Vout {
+        et.Vout = append(et.Vout, out.ToEsploraVout())
+    }
+    // coinbase transactions do not pay any fee
+    if !t.IsCoinbase() {
+        et.Fee = t.GetFees()
+    }
+    return et
+}
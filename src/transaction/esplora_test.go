@@ -0,0 +1,85 @@
+package transaction
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testTxns = []string{
+    // p2wpkh
+    "000cb561188c762c81f76976f816829424e2af9e0e491c617b7bf41038df3d35",
+    // p2pkh
+    "00d12b523d8b7ad90e2269767478764c243625539dc59bcd457d14ca1aa4e38c",
+    // p2tr
+    "00d9c01fd8722f63cc327c93e59de64395d1e6ca5861ae6b9b149b364d082352",
+}
+
+func readTestTx(t *testing.T, name string) ([]byte, Transaction) {
+    b, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var tx Transaction
+    if err := json.Unmarshal(b, &tx); err != nil {
+        t.Fatal(err)
+    }
+    return b, tx
+}
+
+func TestToEsplora(t *testing.T) {
+    for _, name := range testTxns {
+        _, tx := readTestTx(t, name)
+        et := tx.ToEsplora(TxStatus{})
+        if et.Txid != tx.Txid() || et.Weight != tx.GetWeight() || et.Size != tx.SerializeSize(true) || et.Fee != tx.GetFees() {
+            t.Errorf("%s: computed fields %s, %d, %d, %d", name, et.Txid, et.Weight, et.Size, et.Fee)
+        }
+        // the recomputed asm, type and address are the ones given by esplora
+        for i, out := range tx.Vout {
+            want := EsploraVout(out)
+            if got := et.Vout[i]; got != want {
+                t.Errorf("%s: output %d = %+v, want %+v", name, i, got, want)
+            }
+        }
+        for i, in := range tx.Vin {
+            if got, want := *et.Vin[i].PrevOut, EsploraVout(in.PrevOut); got != want {
+                t.Errorf("%s: prevout %d = %+v, want %+v", name, i, got, want)
+            }
+            if et.Vin[i].ScriptSigAsm != in.ScriptSigAsm {
+                t.Errorf("%s: scriptsig asm %d = %s, want %s", name, i, et.Vin[i].ScriptSigAsm, in.ScriptSigAsm)
+            }
+        }
+
+        // the exported transaction reads back as the same transaction
+        b, err := json.Marshal(et)
+        if err != nil {
+            t.Fatal(err)
+        }
+        var back Transaction
+        if err := json.Unmarshal(b, &back); err != nil {
+            t.Fatal(err)
+        }
+        if back.Txid() != tx.Txid() || back.Wtxid() != tx.Wtxid() || back.GetFees() != tx.GetFees() {
+            t.Errorf("%s: exported transaction reads back as %s", name, back.Txid())
+        }
+    }
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+    for _, name := range testTxns {
+        _, tx := readTestTx(t, name)
+        b, err := json.Marshal(tx)
+        if err != nil {
+            t.Fatal(err)
+        }
+        var back Transaction
+        if err := json.Unmarshal(b, &back); err != nil {
+            t.Fatal(err)
+        }
+        if !reflect.DeepEqual(back, tx) {
+            t.Errorf("%s: JSON round trip changed the transaction", name)
+        }
+    }
+}
@@ -0,0 +1,58 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/humblenginr/btc-miner/utils"
+)
+
+// OutPoint identifies a transaction output. The txid is in the same (reversed) hex form used by the Vin.Txid field.
+type OutPoint struct {
+    Txid string
+    Vout int
+}
+
+func NewOutPoint(txid string, vout int) OutPoint {
+    return OutPoint{Txid: txid, Vout: vout}
+}
+
+// ParseOutPoint parses an outpoint of the form txid:vout
+func ParseOutPoint(s string) (OutPoint, error) {
+    txid, vout, found := strings.Cut(s, ":")
+    if !found {
+        return OutPoint{}, fmt.Errorf("invalid outpoint %q: missing ':'", s)
+    }
+    if b, err := hex.DecodeString(txid); err != nil || len(b) != 32 {
+        return OutPoint{}, fmt.Errorf("invalid outpoint %q: bad txid", s)
+    }
+    idx, err := strconv.Atoi(vout)
+    if err != nil || idx < 0 {
+        return OutPoint{}, fmt.Errorf("invalid outpoint %q: bad output index", s)
+    }
+    return OutPoint{Txid: txid, Vout: idx}, nil
+}
+
+func (o OutPoint) String() string {
+    return fmt.Sprintf("%s:%d", o.Txid, o.Vout)
+}
+
+// OutPoint returns the outpoint that the input spends
+func (i Vin) OutPoint() OutPoint {
+    return OutPoint{Txid: i.Txid, Vout: i.Vout}
+}
+
+// Txid returns the transaction hash in the reversed hex form that is used to refer to the transaction in the inputs spending it
+func (t Transaction) Txid() string {
+    return hex.EncodeToString(utils.ReverseBytes(t.TxHash()))
+}
+
+// Wtxid returns the hash of the transaction including its witness data, in the same reversed hex form as Txid. It is the same as the txid for transactions without witness data.
+func (t Transaction) Wtxid() string {
+    if !t.HasWitness() {
+        return t.Txid()
+    }
+    return hex.EncodeToString(utils.ReverseBytes(t.WitnessHash()))
+}
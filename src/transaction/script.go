@@ -0,0 +1,155 @@
+package transaction
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// opcodes needed to parse scripts and count signature operations
+const (
+    OP_0 = 0x00
+    OP_DATA_1 = 0x01
+    OP_DATA_75 = 0x4b
+    OP_PUSHDATA1 = 0x4c
+    OP_PUSHDATA2 = 0x4d
+    OP_PUSHDATA4 = 0x4e
+    OP_1NEGATE = 0x4f
+    OP_RESERVED = 0x50
+    OP_1 = 0x51
+    OP_16 = 0x60
+    OP_RETURN = 0x6a
+    OP_DUP = 0x76
+    OP_EQUAL = 0x87
+    OP_EQUALVERIFY = 0x88
+    OP_HASH160 = 0xa9
+    OP_CHECKSIG = 0xac
+    OP_CHECKSIGVERIFY = 0xad
+    OP_CHECKMULTISIG = 0xae
+    OP_CHECKMULTISIGVERIFY = 0xaf
+)
+
+// MaxPubKeysPerMultiSig is the number of sigops a CHECKMULTISIG is counted as when the number of keys cannot be determined
+const MaxPubKeysPerMultiSig = 20
+
+var ErrMalformedPush = errors.New("script push exceeds the script length")
+
+// ScriptOp is a single parsed instruction of a script, Data holds the pushed bytes for push opcodes.
+type ScriptOp struct {
+    Opcode byte
+    Data []byte
+}
+
+// ParseScript splits the script into its instructions. The instructions parsed before a malformed push are returned along with the error.
+func ParseScript(script []byte) ([]ScriptOp, error) {
+    ops := make([]ScriptOp, 0)
+    for i := 0; i < len(script); {
+        op := script[i]
+        i++
+        var n int
+        switch {
+        case op >= OP_DATA_1 && op <= OP_DATA_75:
+            n = int(op)
+        case op == OP_PUSHDATA1:
+            if i+1 > len(script) {
+                return ops, ErrMalformedPush
+            }
+            n = int(script[i])
+            i++
+        case op == OP_PUSHDATA2:
+            if i+2 > len(script) {
+                return ops, ErrMalformedPush
+            }
+            n = int(binary.LittleEndian.Uint16(script[i:]))
+            i += 2
+        case op == OP_PUSHDATA4:
+            if i+4 > len(script) {
+                return ops, ErrMalformedPush
+            }
+            n = int(binary.LittleEndian.Uint32(script[i:]))
+            i += 4
+        default:
+            ops = append(ops, ScriptOp{Opcode: op})
+            continue
+        }
+        if n < 0 || i+n > len(script) {
+            return ops, ErrMalformedPush
+        }
+        ops = append(ops, ScriptOp{Opcode: op, Data: script[i : i+n]})
+        i += n
+    }
+    return ops, nil
+}
+
+// IsPushOnly reports whether the script consists only of push instructions
+func IsPushOnly(script []byte) bool {
+    ops, err := ParseScript(script)
+    if err != nil {
+        return false
+    }
+    for _, op := range ops {
+        if op.Opcode > OP_16 {
+            return false
+        }
+    }
+    return true
+}
+
+// IsPayToScriptHash reports whether the script is of the form OP_HASH160 OP_DATA_20 <20-byte-hash> OP_EQUAL
+func IsPayToScriptHash(script []byte) bool {
+    return len(script) == 23 && script[0] == OP_HASH160 && script[1] == 0x14 && script[22] == OP_EQUAL
+}
+
+// IsPayToAnchor reports whether the script is the pay to anchor script: OP_1 OP_DATA_2 0x4e73
+func IsPayToAnchor(script []byte) bool {
+    return len(script) == 4 && script[0] == OP_1 && script[1] == 0x02 && script[2] == 0x4e && script[3] == 0x73
+}
+
+// ExtractWitnessProgram returns the version and the program of a witness program script (BIP141), ok is false if the script is not one.
+func ExtractWitnessProgram(script []byte) (version int, program []byte, ok bool) {
+    if len(script) < 4 || len(script) > 42 {
+        return 0, nil, false
+    }
+    if script[0] != OP_0 && (script[0] < OP_1 || script[0] > OP_16) {
+        return 0, nil, false
+    }
+    if int(script[1])+2 != len(script) {
+        return 0, nil, false
+    }
+    if script[0] != OP_0 {
+        version = int(script[0]) - OP_1 + 1
+    }
+    return version, script[2:], true
+}
+
+// CountSigOps counts the signature operations in the script. With accurate counting, a CHECKMULTISIG preceded by OP_1 to OP_16 counts as that many sigops instead of MaxPubKeysPerMultiSig. Counting stops at the first malformed push, as done by bitcoin core.
+func CountSigOps(script []byte, accurate bool) int {
+    ops, _ := ParseScript(script)
+    n := 0
+    lastOpcode := byte(0xff)
+    for _, op := range ops {
+        switch op.Opcode {
+        case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+            n++
+        case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+            if accurate && lastOpcode >= OP_1 && lastOpcode <= OP_16 {
+                n += int(lastOpcode) - OP_1 + 1
+            } else {
+                n += MaxPubKeysPerMultiSig
+            }
+        }
+        lastOpcode = op.Opcode
+    }
+    return n
+}
+
+// lastPush returns the data pushed by the last instruction of a push only script
+func lastPush(script []byte) ([]byte, bool) {
+    if !IsPushOnly(script) {
+        return nil, false
+    }
+    ops, _ := ParseScript(script)
+    if len(ops) == 0 {
+        return nil, false
+    }
+    return ops[len(ops)-1].Data, true
+}
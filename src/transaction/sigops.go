@@ -0,0 +1,92 @@
+package transaction
+
+import (
+	"encoding/hex"
+)
+
+// Implemented using BIP141 (https://github.com/bitcoin/bips/blob/master/bip-0141.mediawiki#sigops) as the reference
+
+const (
+    // MaxBlockSigOpsCost is the maximum sigop cost allowed in a block
+    MaxBlockSigOpsCost = 80000
+    // WitnessScaleFactor is the factor legacy sigops are multiplied with when calculating the sigop cost
+    WitnessScaleFactor = 4
+)
+
+// GetLegacySigOpCount counts the sigops in the signature scripts of the inputs and the pubkey scripts of the outputs, without looking at the spent outputs.
+func (t Transaction) GetLegacySigOpCount() int {
+    n := 0
+    for _, in := range t.Vin {
+        sigScript, _ := hex.DecodeString(in.ScriptSig)
+        n += CountSigOps(sigScript, false)
+    }
+    for _, out := range t.Vout {
+        pkScript, _ := hex.DecodeString(out.ScriptPubKey)
+        n += CountSigOps(pkScript, false)
+    }
+    return n
+}
+
+// GetP2SHSigOpCount counts the sigops in the redeem scripts of the inputs spending P2SH outputs. It depends on the prevouts of the inputs.
+func (t Transaction) GetP2SHSigOpCount() int {
+    if t.IsCoinbase() {
+        return 0
+    }
+    n := 0
+    for _, in := range t.Vin {
+        pkScript, _ := hex.DecodeString(in.PrevOut.ScriptPubKey)
+        if !IsPayToScriptHash(pkScript) {
+            continue
+        }
+        sigScript, _ := hex.DecodeString(in.ScriptSig)
+        redeemScript, ok := lastPush(sigScript)
+        if !ok {
+            continue
+        }
+        n += CountSigOps(redeemScript, true)
+    }
+    return n
+}
+
+// GetWitnessSigOpCount counts the sigops of the witness programs spent by the inputs, including P2SH wrapped ones. Taproot inputs are not counted. It depends on the prevouts of the inputs.
+func (t Transaction) GetWitnessSigOpCount() int {
+    if t.IsCoinbase() {
+        return 0
+    }
+    n := 0
+    for _, in := range t.Vin {
+        pkScript, _ := hex.DecodeString(in.PrevOut.ScriptPubKey)
+        if IsPayToScriptHash(pkScript) {
+            sigScript, _ := hex.DecodeString(in.ScriptSig)
+            redeemScript, ok := lastPush(sigScript)
+            if !ok {
+                continue
+            }
+            pkScript = redeemScript
+        }
+        version, program, ok := ExtractWitnessProgram(pkScript)
+        if !ok || version != 0 {
+            continue
+        }
+        switch len(program) {
+        case 20:
+            n += 1
+        case 32:
+            if len(in.Witness) > 0 {
+                witnessScript, _ := hex.DecodeString(in.Witness[len(in.Witness)-1])
+                n += CountSigOps(witnessScript, true)
+            }
+        }
+    }
+    return n
+}
+
+// GetSigOpCost returns the sigop cost of the transaction as defined by BIP141
+func (t Transaction) GetSigOpCost() int {
+    return (t.GetLegacySigOpCount()+t.GetP2SHSigOpCount())*WitnessScaleFactor + t.GetWitnessSigOpCount()
+}
+
+// IsCoinbase reports whether the transaction is a coinbase transaction
+func (t Transaction) IsCoinbase() bool {
+    return len(t.Vin) == 1 && t.Vin[0].IsCoinbase
+}
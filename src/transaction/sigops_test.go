@@ -0,0 +1,90 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+var (
+    pubkey = "02" + strings.Repeat("11", 32)
+    // 2-of-3 multisig: OP_2 <pk> <pk> <pk> OP_3 OP_CHECKMULTISIG
+    multisig2of3 = "52" + strings.Repeat("21"+pubkey, 3) + "53ae"
+)
+
+// push returns the script pushing the hex encoded data
+func push(data string) string {
+    b, _ := hex.DecodeString(data)
+    switch {
+    case len(b) < OP_PUSHDATA1:
+        return hex.EncodeToString([]byte{byte(len(b))}) + data
+    case len(b) <= 0xff:
+        return hex.EncodeToString([]byte{OP_PUSHDATA1, byte(len(b))}) + data
+    default:
+        return hex.EncodeToString([]byte{OP_PUSHDATA2, byte(len(b)), byte(len(b) >> 8)}) + data
+    }
+}
+
+func TestCountSigOps(t *testing.T) {
+    tests := []struct {
+        name string
+        script string
+        inaccurate int
+        accurate int
+    }{
+        {"empty", "", 0, 0},
+        {"checksig", "ac", 1, 1},
+        {"checksigverify", "ad", 1, 1},
+        {"p2pkh", "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", 1, 1},
+        {"bare checkmultisig", "ae", 20, 20},
+        {"checkmultisigverify after OP_16", "60af", 20, 16},
+        {"2-of-3 multisig", multisig2of3, 20, 3},
+        {"pushed data is not counted", push("acacae"), 0, 0},
+        {"counting stops at a malformed push", "ac4c", 1, 1},
+        {"checksig after a truncated push", "ac05acac", 1, 1},
+    }
+    for _, test := range tests {
+        script, _ := hex.DecodeString(test.script)
+        if got := CountSigOps(script, false); got != test.inaccurate {
+            t.Errorf("%s: CountSigOps(inaccurate) = %d, want %d", test.name, got, test.inaccurate)
+        }
+        if got := CountSigOps(script, true); got != test.accurate {
+            t.Errorf("%s: CountSigOps(accurate) = %d, want %d", test.name, got, test.accurate)
+        }
+    }
+}
+
+func TestGetSigOpCost(t *testing.T) {
+    p2sh := "a914" + strings.Repeat("22", 20) + "87"
+    p2wsh := "0020" + strings.Repeat("33", 32)
+    tests := []struct {
+        name string
+        in Vin
+        outScript string
+        cost int
+    }{
+        // legacy sigops are scaled by the witness scale factor
+        {"p2pkh input and output", Vin{ScriptSig: push("30") + push(pubkey), PrevOut: Vout{ScriptPubKey: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac"}}, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", 4},
+        {"p2wpkh input", Vin{Witness: []string{"30", pubkey}, PrevOut: Vout{ScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6"}}, "51", 1},
+        {"p2sh multisig input", Vin{ScriptSig: "00" + push("30") + push("30") + push(multisig2of3), PrevOut: Vout{ScriptPubKey: p2sh}}, "51", 12},
+        {"p2wsh multisig input", Vin{Witness: []string{"", "30", "30", multisig2of3}, PrevOut: Vout{ScriptPubKey: p2wsh}}, "51", 3},
+        {"p2sh wrapped p2wpkh input", Vin{ScriptSig: push("0014751e76e8199196d454941c45d1b3a323f1433bd6"), Witness: []string{"30", pubkey}, PrevOut: Vout{ScriptPubKey: p2sh}}, "51", 1},
+        {"p2sh wrapped p2wsh input", Vin{ScriptSig: push(p2wsh), Witness: []string{"", "30", "30", multisig2of3}, PrevOut: Vout{ScriptPubKey: p2sh}}, "51", 3},
+        {"taproot input is not counted", Vin{Witness: []string{"30"}, PrevOut: Vout{ScriptPubKey: "5120" + strings.Repeat("44", 32)}}, "51", 0},
+        {"bare multisig output", Vin{PrevOut: Vout{ScriptPubKey: "51"}}, multisig2of3, 80},
+    }
+    for _, test := range tests {
+        tx := Transaction{Version: 2, Vin: []Vin{test.in}, Vout: []Vout{{ScriptPubKey: test.outScript}}}
+        if got := tx.GetSigOpCost(); got != test.cost {
+            t.Errorf("%s: GetSigOpCost() = %d, want %d", test.name, got, test.cost)
+        }
+    }
+}
+
+func TestCoinbaseSigOpCost(t *testing.T) {
+    // the prevout of a coinbase input is not looked at
+    tx := Transaction{Vin: []Vin{{IsCoinbase: true, ScriptSig: "03951a06", PrevOut: Vout{ScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6"}}}, Vout: []Vout{{ScriptPubKey: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac"}}}
+    if got := tx.GetSigOpCost(); got != 4 {
+        t.Errorf("GetSigOpCost() = %d, want 4", got)
+    }
+}
@@ -17,6 +17,11 @@ const (
 	P2WPKH ScriptPubKeyType = "v0_p2wpkh"
 	P2WSH ScriptPubKeyType = "v0_p2wsh"
 	P2TR ScriptPubKeyType = "v1_p2tr"
+	// pay to anchor, the keyless anchor output of BIP431 packages
+	P2A ScriptPubKeyType = "anchor"
+	ScriptP2PK ScriptPubKeyType = "p2pk"
+	ScriptOpReturn ScriptPubKeyType = "op_return"
+	ScriptUnknown ScriptPubKeyType = "unknown"
 )
 
 type Vout struct {
@@ -86,7 +91,6 @@ type Transaction struct {
     Locktime uint32 `json:"locktime"`
     Vin []Vin `json:"vin"`
     Vout []Vout `json:"vout"`
-    Priority int
 }
 
 
@@ -153,14 +157,6 @@ func (tx *Transaction) SerializeSize(witness bool) int {
 }
 
 
-func (t *Transaction) UpdatePriority() {
-    t.Priority = int(t.getFeeByWeight()*1000000)
-}
-
-func (t Transaction) getFeeByWeight() float64 {
-    return float64(t.GetFees())/float64(t.GetWeight())
-}
-
 func (t Transaction) GetWeight() int {
     nonWitnessSize := t.SerializeSize(false)
     witnessSize := t.SerializeSize(true) - nonWitnessSize
@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
 )
@@ -88,3 +89,44 @@ func WriteVarInt(w io.Writer, val uint64) error {
         _, err = w.Write(buffer)
 		return err
 }
+
+// ReadVarInt reads a variable length integer from r.
+func ReadVarInt(r io.Reader) (uint64, error) {
+	var discriminant [1]byte
+	if _, err := io.ReadFull(r, discriminant[:]); err != nil {
+		return 0, err
+	}
+	var size int
+	switch discriminant[0] {
+	case 0xfd:
+		size = 2
+	case 0xfe:
+		size = 4
+	case 0xff:
+		size = 8
+	default:
+		return uint64(discriminant[0]), nil
+	}
+	buffer := make([]byte, 8)
+	if _, err := io.ReadFull(r, buffer[:size]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buffer), nil
+}
+
+// ReadVarBytes reads a variable length byte array written by WriteVarBytes.
+// An error is returned if the length is more than maxLen.
+func ReadVarBytes(r io.Reader, maxLen uint64) ([]byte, error) {
+	n, err := ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxLen {
+		return nil, fmt.Errorf("variable length byte array is too long (%d bytes, max %d)", n, maxLen)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,123 @@
+package txnpicker
+
+import (
+	"fmt"
+	"sort"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// ancestorState holds the totals of a transaction and its ancestors that are not in the block yet
+type ancestorState struct {
+    ancestors map[*TxNode]bool
+    fee int
+    // modifiedFee includes the fee deltas, the packages are ordered by it
+    modifiedFee int
+    weight int
+    sigOpCost int
+    // incremented every time the totals change, queue items with an older version are stale
+    version int
+}
+
+type ancestorItem struct {
+    node *TxNode
+    modifiedFee int
+    weight int
+    version int
+}
+
+// a package with higher fee/weight ratio is of higher priority, ties are broken by the wtxid of the transaction
+func higherAncestorScore(i, o ancestorItem) bool {
+    if c := compareFeeRate(i.modifiedFee, i.weight, o.modifiedFee, o.weight); c != 0 {
+        return c > 0
+    }
+    return i.node.Wtxid < o.node.Wtxid
+}
+
+// PickUsingAncestorScore picks transactions the way bitcoin core builds block templates (BlockAssembler::addPackageTxs): the transaction whose package (itself and its ancestors that are not in the block yet) has the highest fee/weight ratio is picked first, along with its ancestors. So a low fee parent is picked if its child pays enough for both (CPFP).
+// Once a package is in the block, the totals of the descendants of its transactions are updated so that they do not count the picked ancestors anymore.
+func (tp *TransactionsPicker) PickUsingAncestorScore() []*txn.Transaction {
+    g := tp.Graph()
+    states := make(map[*TxNode]*ancestorState, len(g.Nodes))
+    q := newPriorityQueue(higherAncestorScore)
+    for _, n := range g.Nodes {
+        s := &ancestorState{ancestors: map[*TxNode]bool{n: true}, fee: n.Fee, modifiedFee: n.ModifiedFee, weight: n.Weight, sigOpCost: n.SigOpCost}
+        for _, a := range g.Ancestors(n) {
+            s.ancestors[a] = true
+            s.fee += a.Fee
+            s.modifiedFee += a.ModifiedFee
+            s.weight += a.Weight
+            s.sigOpCost += a.SigOpCost
+        }
+        states[n] = s
+        q.push(ancestorItem{n, s.modifiedFee, s.weight, s.version})
+    }
+
+    inBlock := make(map[*TxNode]bool)
+    failed := make(map[*TxNode]bool)
+    picked := make([]*TxNode, 0)
+    total := totals{}
+
+    for it, ok := q.pop(); ok; it, ok = q.pop() {
+        n := it.node
+        s := states[n]
+        if inBlock[n] || failed[n] || it.version != s.version {
+            continue
+        }
+        if !tp.Constraints.fits(total, totals{weight: s.weight, fee: s.fee, sigOpCost: s.sigOpCost, count: len(s.ancestors)}) {
+            failed[n] = true
+            continue
+        }
+
+        pkg := make([]*TxNode, 0, len(s.ancestors))
+        for a := range s.ancestors {
+            pkg = append(pkg, a)
+        }
+        // a transaction has more ancestors left than any of its ancestors, so this puts the parents first
+        sort.Slice(pkg, func(i, j int) bool {
+            ai, aj := len(states[pkg[i]].ancestors), len(states[pkg[j]].ancestors)
+            if ai != aj {
+                return ai < aj
+            }
+            return pkg[i].Txid < pkg[j].Txid
+        })
+        for _, a := range pkg {
+            inBlock[a] = true
+            picked = append(picked, a)
+            total.add(nodeTotals(a))
+        }
+        for _, a := range pkg {
+            for _, d := range g.Descendants(a) {
+                if inBlock[d] {
+                    continue
+                }
+                ds := states[d]
+                delete(ds.ancestors, a)
+                ds.fee -= a.Fee
+                ds.modifiedFee -= a.ModifiedFee
+                ds.weight -= a.Weight
+                ds.sigOpCost -= a.SigOpCost
+                ds.version++
+                q.push(ancestorItem{d, ds.modifiedFee, ds.weight, ds.version})
+            }
+        }
+    }
+    return tp.transactions(picked)
+}
+
+// TotalFees returns the sum of the fees of the transactions
+func TotalFees(txns []*txn.Transaction) int {
+    fees := 0
+    for _, t := range txns {
+        fees += t.GetFees()
+    }
+    return fees
+}
+
+// CompareAncestorScore picks transactions using both PickUsingPQ and PickUsingAncestorScore and describes the difference in the fees collected
+func (tp *TransactionsPicker) CompareAncestorScore() string {
+    greedy := tp.PickUsingPQ()
+    ancestor := tp.PickUsingAncestorScore()
+    greedyFees, ancestorFees := TotalFees(greedy), TotalFees(ancestor)
+    return fmt.Sprintf("greedy: %d txns, %d sats\nancestor score: %d txns, %d sats\ndifference: %+d sats", len(greedy), greedyFees, len(ancestor), ancestorFees, ancestorFees-greedyFees)
+}
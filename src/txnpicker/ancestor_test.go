@@ -0,0 +1,64 @@
+package txnpicker
+
+import (
+	"testing"
+
+	"github.com/humblenginr/btc-miner/mining"
+)
+
+func TestPickUsingAncestorScore(t *testing.T) {
+    // the child pays for its low fee parent, together they pay more per weight than the other transaction
+    parent := fakeTx(100, 0)
+    child := fakeTx(10000, 0, parent)
+    other := fakeTx(3000, 0)
+    weight := parent.GetWeight()
+    if child.GetWeight() != weight || other.GetWeight() != weight {
+        t.Fatal("transactions of different weights")
+    }
+    // room for two transactions
+    c := Constraints{MaxWeight: 2*weight + mining.TxCountWeight(3)}
+    tp := graphPicker(c, parent, child, other)
+
+    greedy := tp.PickUsingPQ()
+    if len(greedy) != 2 || greedy[0] != other || greedy[1] != parent {
+        t.Errorf("PickUsingPQ() = %v, want the other transaction and the parent", txids(greedy))
+    }
+    ancestor := tp.PickUsingAncestorScore()
+    if len(ancestor) != 2 || ancestor[0] != parent || ancestor[1] != child {
+        t.Errorf("PickUsingAncestorScore() = %v, want the parent and the child", txids(ancestor))
+    }
+    if TotalFees(ancestor) != 10100 || TotalFees(greedy) != 3100 {
+        t.Errorf("fees %d and %d, want 10100 and 3100", TotalFees(ancestor), TotalFees(greedy))
+    }
+}
+
+func TestAncestorScoreUpdatesDescendants(t *testing.T) {
+    // once the high fee parent is picked, the child only counts itself and comes before the mid fee transaction
+    parent := fakeTx(20000, 0)
+    child := fakeTx(4000, 0, parent)
+    mid := fakeTx(3000, 0)
+    low := fakeTx(1000, 0)
+    tp := graphPicker(Constraints{}, low, mid, child, parent)
+    got := tp.PickUsingAncestorScore()
+    want := []string{parent.Txid(), child.Txid(), mid.Txid(), low.Txid()}
+    if len(got) != len(want) {
+        t.Fatalf("PickUsingAncestorScore() = %v, want %v", txids(got), want)
+    }
+    for i := range want {
+        if got[i].Txid() != want[i] {
+            t.Fatalf("PickUsingAncestorScore() = %v, want %v", txids(got), want)
+        }
+    }
+}
+
+func TestAncestorScoreSkipsPackagesThatDoNotFit(t *testing.T) {
+    // the package of the child does not fit, but the parent alone does
+    parent := fakeTx(100, 0)
+    child := fakeTx(10000, 0, parent)
+    weight := parent.GetWeight()
+    tp := graphPicker(Constraints{MaxWeight: weight + mining.TxCountWeight(2)}, parent, child)
+    got := tp.PickUsingAncestorScore()
+    if len(got) != 1 || got[0] != parent {
+        t.Errorf("PickUsingAncestorScore() = %v, want only the parent", txids(got))
+    }
+}
@@ -0,0 +1,328 @@
+package txnpicker
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// MaxExhaustiveClusterSize is the size up to which clusters are linearized optimally by searching all the subsets of the cluster. Bigger clusters are linearized using ancestor sets. It cannot be more than 32.
+var MaxExhaustiveClusterSize = 12
+
+// Cluster is a set of mempool transactions connected through parent/child relations. Transactions of different clusters do not depend on each other.
+type Cluster struct {
+    Nodes []*TxNode
+}
+
+// Chunk is a group of consecutive transactions of a linearization that are picked together
+type Chunk struct {
+    Nodes []*TxNode
+    Fee int
+    // ModifiedFee includes the fee deltas, the chunks are formed and ordered by it
+    ModifiedFee int
+    Weight int
+    SigOpCost int
+}
+
+func (c *Chunk) add(n *TxNode) {
+    c.Nodes = append(c.Nodes, n)
+    c.Fee += n.Fee
+    c.ModifiedFee += n.ModifiedFee
+    c.Weight += n.Weight
+    c.SigOpCost += n.SigOpCost
+}
+
+// Clusters partitions the graph into its connected components, in the order of their first transaction
+func (g *DependencyGraph) Clusters() []*Cluster {
+    visited := make(map[*TxNode]bool, len(g.Nodes))
+    clusters := make([]*Cluster, 0)
+    for _, n := range g.Nodes {
+        if visited[n] {
+            continue
+        }
+        c := &Cluster{}
+        stack := []*TxNode{n}
+        visited[n] = true
+        for len(stack) > 0 {
+            cur := stack[len(stack)-1]
+            stack = stack[:len(stack)-1]
+            c.Nodes = append(c.Nodes, cur)
+            for _, next := range append(append([]*TxNode{}, cur.Parents...), cur.Children...) {
+                if !visited[next] {
+                    visited[next] = true
+                    stack = append(stack, next)
+                }
+            }
+        }
+        clusters = append(clusters, c)
+    }
+    return clusters
+}
+
+// Linearize orders the transactions of the cluster so that parents come before their children and transactions paying more per weight come as early as possible.
+// Small clusters are linearized optimally by repeatedly taking the highest fee rate topologically closed subset of the remaining transactions. Bigger clusters repeatedly take the highest fee rate ancestor set instead.
+func (c *Cluster) Linearize() []*TxNode {
+    if len(c.Nodes) <= MaxExhaustiveClusterSize {
+        return c.linearizeExhaustive()
+    }
+    return c.linearizeAncestorSets()
+}
+
+func (c *Cluster) linearizeExhaustive() []*TxNode {
+    index := make(map[*TxNode]uint, len(c.Nodes))
+    for i, n := range c.Nodes {
+        index[n] = uint(i)
+    }
+    // parentMask[i] has the bits of the in-cluster parents of the i-th transaction
+    parentMask := make([]uint32, len(c.Nodes))
+    for i, n := range c.Nodes {
+        for _, p := range n.Parents {
+            parentMask[i] |= 1 << index[p]
+        }
+    }
+
+    result := make([]*TxNode, 0, len(c.Nodes))
+    remaining := uint32(1)<<uint(len(c.Nodes)) - 1
+    for remaining != 0 {
+        var best uint32
+        bestFee, bestWeight := 0, 0
+        // iterate over all the non-empty subsets of remaining
+        for s := remaining; s != 0; s = (s - 1) & remaining {
+            fee, weight, closed := 0, 0, true
+            for m := s; m != 0; m &= m - 1 {
+                i := bits.TrailingZeros32(m)
+                if parentMask[i]&remaining&^s != 0 {
+                    closed = false
+                    break
+                }
+                fee += c.Nodes[i].ModifiedFee
+                weight += c.Nodes[i].Weight
+            }
+            if !closed {
+                continue
+            }
+            // on equal fee rates, the smaller subset is taken first
+            if best == 0 || higherFeeRate(fee, weight, bestFee, bestWeight) ||
+                (!higherFeeRate(bestFee, bestWeight, fee, weight) && weight < bestWeight) {
+                best, bestFee, bestWeight = s, fee, weight
+            }
+        }
+        result = append(result, topologicalOrder(c.Nodes, best)...)
+        remaining &^= best
+    }
+    return result
+}
+
+// topologicalOrder returns the transactions of the subset so that parents come before their children
+func topologicalOrder(nodes []*TxNode, subset uint32) []*TxNode {
+    inSubset := make(map[*TxNode]bool)
+    for m := subset; m != 0; m &= m - 1 {
+        inSubset[nodes[bits.TrailingZeros32(m)]] = true
+    }
+    ordered := make([]*TxNode, 0, len(inSubset))
+    added := make(map[*TxNode]bool)
+    var visit func(n *TxNode)
+    visit = func(n *TxNode) {
+        if added[n] {
+            return
+        }
+        for _, p := range n.Parents {
+            if inSubset[p] {
+                visit(p)
+            }
+        }
+        added[n] = true
+        ordered = append(ordered, n)
+    }
+    for m := subset; m != 0; m &= m - 1 {
+        visit(nodes[bits.TrailingZeros32(m)])
+    }
+    return ordered
+}
+
+func (c *Cluster) linearizeAncestorSets() []*TxNode {
+    done := make(map[*TxNode]bool, len(c.Nodes))
+    result := make([]*TxNode, 0, len(c.Nodes))
+    for len(result) < len(c.Nodes) {
+        var best []*TxNode
+        bestFee, bestWeight := 0, 0
+        for _, n := range c.Nodes {
+            if done[n] {
+                continue
+            }
+            set := remainingAncestorSet(n, done)
+            fee, weight := 0, 0
+            for _, a := range set {
+                fee += a.ModifiedFee
+                weight += a.Weight
+            }
+            if best == nil || higherFeeRate(fee, weight, bestFee, bestWeight) {
+                best, bestFee, bestWeight = set, fee, weight
+            }
+        }
+        for _, a := range best {
+            done[a] = true
+        }
+        result = append(result, best...)
+    }
+    return result
+}
+
+// remainingAncestorSet returns n and its ancestors that are not done yet, parents first
+func remainingAncestorSet(n *TxNode, done map[*TxNode]bool) []*TxNode {
+    added := make(map[*TxNode]bool)
+    set := make([]*TxNode, 0)
+    var visit func(n *TxNode)
+    visit = func(n *TxNode) {
+        if added[n] || done[n] {
+            return
+        }
+        added[n] = true
+        for _, p := range n.Parents {
+            visit(p)
+        }
+        set = append(set, n)
+    }
+    visit(n)
+    return set
+}
+
+func (c Chunk) totals() totals {
+    return totals{weight: c.Weight, fee: c.Fee, sigOpCost: c.SigOpCost, count: len(c.Nodes)}
+}
+
+// ChunkLinearization groups a linearization into chunks of non-increasing fee rate: a transaction is merged into the chunk before it as long as the merged chunk pays more per weight than the previous one.
+func ChunkLinearization(linearization []*TxNode) []Chunk {
+    chunks := make([]Chunk, 0, len(linearization))
+    for _, n := range linearization {
+        c := Chunk{}
+        c.add(n)
+        for len(chunks) > 0 {
+            last := chunks[len(chunks)-1]
+            if !higherFeeRate(c.ModifiedFee, c.Weight, last.ModifiedFee, last.Weight) {
+                break
+            }
+            merged := Chunk{}
+            for _, m := range append(last.Nodes, c.Nodes...) {
+                merged.add(m)
+            }
+            c = merged
+            chunks = chunks[:len(chunks)-1]
+        }
+        chunks = append(chunks, c)
+    }
+    return chunks
+}
+
+type chunkItem struct {
+    cluster int
+    chunk Chunk
+}
+
+func higherChunkFeeRate(i, o chunkItem) bool {
+    if c := compareFeeRate(i.chunk.ModifiedFee, i.chunk.Weight, o.chunk.ModifiedFee, o.chunk.Weight); c != 0 {
+        return c > 0
+    }
+    return i.chunk.Nodes[0].Wtxid < o.chunk.Nodes[0].Wtxid
+}
+
+// PickUsingClusters partitions the mempool into clusters, linearizes and chunks each of them, and then merges the chunks of all the clusters by fee rate.
+// A chunk is picked only as a whole. Once a chunk of a cluster does not fit, the rest of the chunks of that cluster are skipped, since they come after it in the linearization.
+func (tp *TransactionsPicker) PickUsingClusters() []*txn.Transaction {
+    clusterChunks := make([][]Chunk, 0)
+    q := newPriorityQueue(higherChunkFeeRate)
+    for _, c := range tp.Graph().Clusters() {
+        chunks := ChunkLinearization(c.Linearize())
+        clusterChunks = append(clusterChunks, chunks)
+        q.push(chunkItem{len(clusterChunks) - 1, chunks[0]})
+    }
+    next := make([]int, len(clusterChunks))
+
+    picked := make([]*TxNode, 0)
+    total := totals{}
+    for it, ok := q.pop(); ok; it, ok = q.pop() {
+        c := it.chunk
+        if !tp.Constraints.fits(total, c.totals()) {
+            continue
+        }
+        picked = append(picked, c.Nodes...)
+        total.add(c.totals())
+        next[it.cluster]++
+        if next[it.cluster] < len(clusterChunks[it.cluster]) {
+            q.push(chunkItem{it.cluster, clusterChunks[it.cluster][next[it.cluster]]})
+        }
+    }
+    return tp.transactions(picked)
+}
+
+// FeeDiagramPoint is the total weight and fee of a prefix of a block template
+type FeeDiagramPoint struct {
+    Weight int
+    Fee int
+}
+
+// FeeDiagram is the cumulative fee of a block template as a function of its weight, starting at (0, 0)
+type FeeDiagram []FeeDiagramPoint
+
+// NewFeeDiagram returns the fee diagram of the transactions in the order they are given
+func NewFeeDiagram(txns []*txn.Transaction) FeeDiagram {
+    d := FeeDiagram{{0, 0}}
+    for _, t := range txns {
+        last := d[len(d)-1]
+        d = append(d, FeeDiagramPoint{last.Weight + t.GetWeight(), last.Fee + t.GetFees()})
+    }
+    return d
+}
+
+// FeeAt returns the fee collected at the given weight, interpolating linearly between the points of the diagram. The diagram is flat after its last point.
+func (d FeeDiagram) FeeAt(weight int) int {
+    i := sort.Search(len(d), func(i int) bool { return d[i].Weight >= weight })
+    if i == len(d) {
+        return d[len(d)-1].Fee
+    }
+    if i == 0 || d[i].Weight == weight {
+        return d[i].Fee
+    }
+    prev := d[i-1]
+    return prev.Fee + (d[i].Fee-prev.Fee)*(weight-prev.Weight)/(d[i].Weight-prev.Weight)
+}
+
+// CompareFeeDiagrams describes the fees of two templates at every step of the given weight, up to maxWeight. A step below 1 is taken as 1, and only the header is returned if maxWeight is not positive.
+func CompareFeeDiagrams(nameA string, a FeeDiagram, nameB string, b FeeDiagram, step int, maxWeight int) string {
+    var sb strings.Builder
+    fmt.Fprintf(&sb, "%10s %14s %14s %12s\n", "weight", nameA, nameB, "difference")
+    if maxWeight <= 0 {
+        return sb.String()
+    }
+    if step < 1 {
+        step = 1
+    }
+    for w := step; ; w += step {
+        if w > maxWeight {
+            w = maxWeight
+        }
+        feeA, feeB := a.FeeAt(w), b.FeeAt(w)
+        fmt.Fprintf(&sb, "%10d %14d %14d %+12d\n", w, feeA, feeB, feeB-feeA)
+        if w == maxWeight {
+            break
+        }
+    }
+    return sb.String()
+}
+
+// CompareClusters picks transactions using both PickUsingPQ and PickUsingClusters and compares their fee diagrams
+func (tp *TransactionsPicker) CompareClusters() string {
+    greedy := NewFeeDiagram(tp.PickUsingPQ())
+    cluster := NewFeeDiagram(tp.PickUsingClusters())
+    maxWeight := tp.Constraints.WeightLimit()
+    if maxWeight == 0 {
+        maxWeight = greedy[len(greedy)-1].Weight
+        if last := cluster[len(cluster)-1].Weight; last > maxWeight {
+            maxWeight = last
+        }
+    }
+    return CompareFeeDiagrams("greedy", greedy, "cluster", cluster, maxWeight/10, maxWeight)
+}
@@ -0,0 +1,168 @@
+package txnpicker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/humblenginr/btc-miner/mining"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+func nodeTxids(nodes []*TxNode) []string {
+    ids := make([]string, 0, len(nodes))
+    for _, n := range nodes {
+        ids = append(ids, n.Txid)
+    }
+    return ids
+}
+
+// childrenPayForParent returns a cluster where the zero fee parent and its two children together pay more per weight than the best ancestor set, which is the other root
+func childrenPayForParent() (parent, a, b, other, joint *txn.Transaction) {
+    parent = fakeTx(0, 0)
+    a = fakeTx(10000, 0, parent)
+    b = fakeTx(10000, 0, parent)
+    other = fakeTx(6000, 0)
+    joint = fakeTx(0, 0, other, parent)
+    return
+}
+
+func TestClusters(t *testing.T) {
+    parent, a, b, other, joint := childrenPayForParent()
+    single := fakeTx(100, 0)
+    g := NewDependencyGraph([]*txn.Transaction{parent, a, b, other, joint, single})
+    clusters := g.Clusters()
+    if len(clusters) != 2 || len(clusters[0].Nodes) != 5 || len(clusters[1].Nodes) != 1 {
+        t.Fatalf("got %d clusters", len(clusters))
+    }
+    if clusters[1].Nodes[0].Tx != single {
+        t.Error("clusters are not in the order of their first transaction")
+    }
+}
+
+func TestLinearize(t *testing.T) {
+    parent, a, b, other, joint := childrenPayForParent()
+    g := NewDependencyGraph([]*txn.Transaction{joint, other, b, a, parent})
+    c := g.Clusters()[0]
+
+    exhaustive := c.linearizeExhaustive()
+    chunks := ChunkLinearization(exhaustive)
+    if len(chunks) == 0 || chunks[0].Fee != 20000 || len(chunks[0].Nodes) != 3 || chunks[0].Nodes[0].Tx != parent {
+        t.Errorf("exhaustive linearization %v does not start with the parent and its children", nodeTxids(exhaustive))
+    }
+    ancestorSets := c.linearizeAncestorSets()
+    if ancestorSets[0].Tx != other {
+        t.Errorf("ancestor set linearization %v does not start with the other root", nodeTxids(ancestorSets))
+    }
+    for _, l := range [][]*TxNode{exhaustive, ancestorSets} {
+        txns := make([]*txn.Transaction, 0, len(l))
+        for _, n := range l {
+            txns = append(txns, n.Tx)
+        }
+        if len(l) != 5 || !IsTopologicallySorted(txns) {
+            t.Errorf("linearization %v is not a topological order of the cluster", nodeTxids(l))
+        }
+    }
+}
+
+func TestChunkLinearization(t *testing.T) {
+    node := func(fee int) *TxNode {
+        return &TxNode{Fee: fee, ModifiedFee: fee, Weight: 400}
+    }
+    a, b, c, d := node(100), node(1000), node(200), node(300)
+    tests := []struct {
+        linearization []*TxNode
+        chunks [][]*TxNode
+    }{
+        {[]*TxNode{b, c, a}, [][]*TxNode{{b}, {c}, {a}}},
+        // a is merged with the higher fee rate b, then c is merged because the chunk is still lower than d's
+        {[]*TxNode{a, b, d}, [][]*TxNode{{a, b}, {d}}},
+        {[]*TxNode{a, c, d}, [][]*TxNode{{a, c, d}}},
+        {[]*TxNode{}, [][]*TxNode{}},
+    }
+    for i, test := range tests {
+        chunks := ChunkLinearization(test.linearization)
+        if len(chunks) != len(test.chunks) {
+            t.Errorf("%d: %d chunks, want %d", i, len(chunks), len(test.chunks))
+            continue
+        }
+        for j, c := range chunks {
+            fee := 0
+            for _, n := range test.chunks[j] {
+                fee += n.Fee
+            }
+            if len(c.Nodes) != len(test.chunks[j]) || c.Fee != fee || c.Weight != 400*len(c.Nodes) {
+                t.Errorf("%d: chunk %d has %d transactions and fee %d, want %d and %d", i, j, len(c.Nodes), c.Fee, len(test.chunks[j]), fee)
+            }
+        }
+    }
+}
+
+func TestPickUsingClusters(t *testing.T) {
+    parent, a, b, other, joint := childrenPayForParent()
+    weight := parent.GetWeight()
+    c := Constraints{MaxWeight: 3*weight + mining.TxCountWeight(4)}
+    tp := graphPicker(c, parent, a, b, other, joint)
+
+    got := tp.PickUsingClusters()
+    if TotalFees(got) != 20000 || len(got) != 3 || got[0] != parent {
+        t.Errorf("PickUsingClusters() = %v, want the parent and its children", txids(got))
+    }
+    // greedy picks the other root first and then has room for only one child
+    if fees := TotalFees(tp.PickUsingPQ()); fees != 16000 {
+        t.Errorf("PickUsingPQ() collected %d, want 16000", fees)
+    }
+}
+
+func TestFeeDiagram(t *testing.T) {
+    d := FeeDiagram{{0, 0}, {100, 50}, {200, 60}}
+    tests := []struct {
+        weight int
+        fee int
+    }{
+        {0, 0},
+        {50, 25},
+        {100, 50},
+        {150, 55},
+        {200, 60},
+        {300, 60},
+    }
+    for _, test := range tests {
+        if got := d.FeeAt(test.weight); got != test.fee {
+            t.Errorf("FeeAt(%d) = %d, want %d", test.weight, got, test.fee)
+        }
+    }
+
+    parent := fakeTx(100, 0)
+    child := fakeTx(300, 0, parent)
+    w := parent.GetWeight()
+    diagram := NewFeeDiagram([]*txn.Transaction{parent, child})
+    if len(diagram) != 3 || diagram[2] != (FeeDiagramPoint{2 * w, 400}) {
+        t.Errorf("NewFeeDiagram() = %v", diagram)
+    }
+}
+
+func TestCompareFeeDiagrams(t *testing.T) {
+    d := FeeDiagram{{0, 0}, {100, 50}, {200, 60}}
+    tests := []struct {
+        name string
+        step int
+        maxWeight int
+        rows int
+    }{
+        {"steps", 50, 200, 4},
+        {"last step shorter", 150, 200, 2},
+        // maxWeight/10 is zero below a weight of 10
+        {"zero step", 0, 5, 5},
+        {"negative step", -1, 3, 3},
+        {"no weight", 10, 0, 0},
+        {"negative weight", 10, -100, 0},
+    }
+    for _, test := range tests {
+        out := CompareFeeDiagrams("a", d, "b", d, test.step, test.maxWeight)
+        // the header comes first
+        if rows := strings.Count(out, "\n") - 1; rows != test.rows {
+            t.Errorf("%s: %d rows, want %d:\n%s", test.name, rows, test.rows, out)
+        }
+    }
+}
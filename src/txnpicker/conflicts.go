@@ -0,0 +1,237 @@
+package txnpicker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// ConflictRule decides which transactions are kept when mempool transactions spend the same outputs
+type ConflictRule int
+
+const (
+    // ConflictRuleFeeRate keeps the transactions with the higher fee/weight ratio
+    ConflictRuleFeeRate ConflictRule = iota
+    // ConflictRuleBIP125 goes through the transactions in file order and lets a transaction replace the ones it conflicts with only if the BIP125 rules allow it
+    ConflictRuleBIP125
+    // ConflictRuleFirstSeen keeps the transactions that come first in file order
+    ConflictRuleFirstSeen
+)
+
+var conflictRuleNames = map[ConflictRule]string{
+    ConflictRuleFeeRate: "feerate",
+    ConflictRuleBIP125: "bip125",
+    ConflictRuleFirstSeen: "firstseen",
+}
+
+func (r ConflictRule) String() string {
+    return conflictRuleNames[r]
+}
+
+// ParseConflictRule returns the rule with the given name (feerate, bip125 or firstseen)
+func ParseConflictRule(name string) (ConflictRule, error) {
+    for r, n := range conflictRuleNames {
+        if n == name {
+            return r, nil
+        }
+    }
+    return 0, fmt.Errorf("unknown conflict rule: %s", name)
+}
+
+// ConflictSet is a group of mempool transactions connected by spending the same outputs, and how the conflict was resolved
+type ConflictSet struct {
+    // outputs spent by more than one transaction of the set
+    OutPoints []txn.OutPoint
+    // all the transactions of the set, in file order
+    Txids []string
+    Kept []string
+    Evicted []string
+}
+
+func (c ConflictSet) String() string {
+    ops := make([]string, 0, len(c.OutPoints))
+    for _, op := range c.OutPoints {
+        ops = append(ops, op.String())
+    }
+    return fmt.Sprintf("conflict on %s: kept [%s], evicted [%s]", strings.Join(ops, ", "), strings.Join(c.Kept, ", "), strings.Join(c.Evicted, ", "))
+}
+
+type conflictEntry struct {
+    tx *txSummary
+    txid string
+    // position in file order
+    order int
+    fee int
+    weight int
+    // in-mempool descendants of the transaction, only filled for ConflictRuleBIP125
+    descendants []policy.ReplacedTx
+}
+
+// resolveConflicts indexes the outputs spent by the transactions and, for every group of transactions spending the same outputs, keeps a non-conflicting subset chosen by the rule. The kept transactions are returned in their original order along with the resolved conflict sets.
+// Descendants of the evicted transactions are not removed here, but with ConflictRuleBIP125 their fees are counted against the replacement.
+func resolveConflicts(txns []*txSummary, rule ConflictRule) ([]*txSummary, []ConflictSet) {
+    spenders := make(map[txn.OutPoint][]int)
+    for i, t := range txns {
+        for _, op := range t.Inputs {
+            spenders[op] = append(spenders[op], i)
+        }
+    }
+
+    // union find over the transactions spending the same outputs
+    parent := make([]int, len(txns))
+    for i := range parent {
+        parent[i] = i
+    }
+    var find func(i int) int
+    find = func(i int) int {
+        if parent[i] != i {
+            parent[i] = find(parent[i])
+        }
+        return parent[i]
+    }
+    contested := make([]txn.OutPoint, 0)
+    for op, s := range spenders {
+        if len(s) < 2 {
+            continue
+        }
+        contested = append(contested, op)
+        for _, i := range s[1:] {
+            parent[find(i)] = find(s[0])
+        }
+    }
+    if len(contested) == 0 {
+        return txns, nil
+    }
+    sort.Slice(contested, func(i, j int) bool { return contested[i].String() < contested[j].String() })
+
+    groups := make(map[int][]int)
+    groupOutPoints := make(map[int][]txn.OutPoint)
+    for _, op := range contested {
+        root := find(spenders[op][0])
+        groupOutPoints[root] = append(groupOutPoints[root], op)
+    }
+    for i := range txns {
+        root := find(i)
+        if _, ok := groupOutPoints[root]; ok {
+            groups[root] = append(groups[root], i)
+        }
+    }
+
+    var children map[string][]int
+    if rule == ConflictRuleBIP125 {
+        children = make(map[string][]int)
+        for i, t := range txns {
+            for _, op := range t.Inputs {
+                children[op.Txid] = append(children[op.Txid], i)
+            }
+        }
+    }
+
+    evicted := make(map[int]bool)
+    sets := make([]ConflictSet, 0, len(groups))
+    roots := make([]int, 0, len(groups))
+    for root := range groups {
+        roots = append(roots, root)
+    }
+    sort.Ints(roots)
+    for _, root := range roots {
+        entries := make([]*conflictEntry, 0, len(groups[root]))
+        for _, i := range groups[root] {
+            t := txns[i]
+            e := &conflictEntry{tx: t, txid: t.Txid, order: i, fee: t.fee, weight: t.weight}
+            if children != nil {
+                e.descendants = descendantsOf(e.txid, txns, children)
+            }
+            entries = append(entries, e)
+        }
+        kept := resolveConflictSet(entries, rule)
+        set := ConflictSet{OutPoints: groupOutPoints[root]}
+        for _, e := range entries {
+            set.Txids = append(set.Txids, e.txid)
+            if kept[e] {
+                set.Kept = append(set.Kept, e.txid)
+            } else {
+                set.Evicted = append(set.Evicted, e.txid)
+                evicted[e.order] = true
+            }
+        }
+        sets = append(sets, set)
+    }
+
+    remaining := make([]*txSummary, 0, len(txns)-len(evicted))
+    for i, t := range txns {
+        if !evicted[i] {
+            remaining = append(remaining, t)
+        }
+    }
+    return remaining, sets
+}
+
+func descendantsOf(txid string, txns []*txSummary, children map[string][]int) []policy.ReplacedTx {
+    visited := make(map[int]bool)
+    descendants := make([]policy.ReplacedTx, 0)
+    queue := append([]int{}, children[txid]...)
+    for len(queue) > 0 {
+        i := queue[0]
+        queue = queue[1:]
+        if visited[i] {
+            continue
+        }
+        visited[i] = true
+        t := txns[i]
+        descendants = append(descendants, policy.ReplacedTx{Fee: t.fee, Weight: t.weight, Signals: t.signals})
+        queue = append(queue, children[t.Txid]...)
+    }
+    return descendants
+}
+
+// resolveConflictSet returns the entries (given in file order) that are kept
+func resolveConflictSet(entries []*conflictEntry, rule ConflictRule) map[*conflictEntry]bool {
+    kept := make(map[*conflictEntry]bool)
+    conflictsWithKept := func(e *conflictEntry) []*conflictEntry {
+        conflicts := make([]*conflictEntry, 0)
+        for k := range kept {
+            if spendsSameOutput(e.tx, k.tx) {
+                conflicts = append(conflicts, k)
+            }
+        }
+        return conflicts
+    }
+
+    switch rule {
+    case ConflictRuleBIP125:
+        for _, e := range entries {
+            conflicts := conflictsWithKept(e)
+            direct := make([]policy.ReplacedTx, 0, len(conflicts))
+            evicted := make([]policy.ReplacedTx, 0, len(conflicts))
+            for _, c := range conflicts {
+                r := policy.ReplacedTx{Fee: c.fee, Weight: c.weight, Signals: c.tx.signals}
+                direct = append(direct, r)
+                evicted = append(append(evicted, r), c.descendants...)
+            }
+            if len(conflicts) > 0 && policy.CheckReplacement(e.fee, e.weight, direct, evicted, false) != nil {
+                continue
+            }
+            for _, c := range conflicts {
+                delete(kept, c)
+            }
+            kept[e] = true
+        }
+        return kept
+    case ConflictRuleFeeRate:
+        byFeeRate := append([]*conflictEntry{}, entries...)
+        sort.SliceStable(byFeeRate, func(i, j int) bool {
+            return higherFeeRate(byFeeRate[i].fee, byFeeRate[i].weight, byFeeRate[j].fee, byFeeRate[j].weight)
+        })
+        entries = byFeeRate
+    }
+    for _, e := range entries {
+        if len(conflictsWithKept(e)) == 0 {
+            kept[e] = true
+        }
+    }
+    return kept
+}
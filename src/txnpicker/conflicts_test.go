@@ -0,0 +1,93 @@
+package txnpicker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/humblenginr/btc-miner/internal/txtest"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// signalRBF makes the transaction opt in to replacement
+func signalRBF(tx *txn.Transaction) *txn.Transaction {
+    tx.Vin[0].Sequence = 0xfffffffd
+    return tx
+}
+
+func TestParseConflictRule(t *testing.T) {
+    for _, r := range []ConflictRule{ConflictRuleFeeRate, ConflictRuleBIP125, ConflictRuleFirstSeen} {
+        if got, err := ParseConflictRule(r.String()); err != nil || got != r {
+            t.Errorf("ParseConflictRule(%q) = %v, %v", r.String(), got, err)
+        }
+    }
+    if _, err := ParseConflictRule("lifo"); err == nil {
+        t.Error("unknown rule accepted")
+    }
+}
+
+func TestResolveConflicts(t *testing.T) {
+    original := fakeTx(1000, 0)
+    better := txtest.Respend(original, 5000)
+    signaling := signalRBF(fakeTx(1000, 0))
+    replacement := txtest.Respend(signaling, 5000)
+    // pays more in total, but less per weight than the first seen transaction
+    heavy := fakeTx(1000, 0)
+    heavyRespend := txtest.Respend(heavy, 1100)
+    heavyRespend.Vout = append(heavyRespend.Vout, txn.Vout{ScriptPubKey: "6a" + strings.Repeat("00", 40)})
+
+    tests := []struct {
+        name string
+        txns []*txn.Transaction
+        rule ConflictRule
+        kept []*txn.Transaction
+    }{
+        {"feerate", []*txn.Transaction{original, better}, ConflictRuleFeeRate, []*txn.Transaction{better}},
+        {"feerate keeps the higher ratio", []*txn.Transaction{heavy, heavyRespend}, ConflictRuleFeeRate, []*txn.Transaction{heavy}},
+        {"first seen", []*txn.Transaction{original, better}, ConflictRuleFirstSeen, []*txn.Transaction{original}},
+        {"bip125 without signaling", []*txn.Transaction{original, better}, ConflictRuleBIP125, []*txn.Transaction{original}},
+        {"bip125 with signaling", []*txn.Transaction{signaling, replacement}, ConflictRuleBIP125, []*txn.Transaction{replacement}},
+        {"bip125 lower fee", []*txn.Transaction{replacement, signalRBF(txtest.Respend(signaling, 2000))}, ConflictRuleBIP125, []*txn.Transaction{replacement}},
+    }
+    for _, test := range tests {
+        unrelated := fakeTx(100, 0)
+        txns := append(append([]*txn.Transaction{}, test.txns...), unrelated)
+        summaries, sets := resolveConflicts(summarizeAll(txns), test.rule)
+        remaining := transactionsOf(summaries)
+        want := append(append([]*txn.Transaction{}, test.kept...), unrelated)
+        if len(remaining) != len(want) {
+            t.Errorf("%s: kept %v", test.name, txids(remaining))
+            continue
+        }
+        for i := range want {
+            if remaining[i] != want[i] {
+                t.Errorf("%s: kept %v, want %v", test.name, txids(remaining), txids(want))
+                break
+            }
+        }
+        if len(sets) != 1 || len(sets[0].Txids) != 2 || len(sets[0].Kept) != 1 || len(sets[0].Evicted) != 1 {
+            t.Errorf("%s: conflict sets %v", test.name, sets)
+        }
+    }
+}
+
+func TestResolveConflictsCountsDescendants(t *testing.T) {
+    // the replacement pays more than the transaction it conflicts with, but not more than it and its child
+    original := signalRBF(fakeTx(1000, 0))
+    child := fakeTx(5000, 0, original)
+    replacement := txtest.Respend(original, 3000)
+    summaries, _ := resolveConflicts(summarizeAll([]*txn.Transaction{original, child, replacement}), ConflictRuleBIP125)
+    remaining := transactionsOf(summaries)
+    if len(remaining) != 2 || remaining[0] != original || remaining[1] != child {
+        t.Errorf("kept %v, want the original and its child", txids(remaining))
+    }
+}
+
+func TestResolveConflictsWithoutConflicts(t *testing.T) {
+    parent := fakeTx(100, 0)
+    txns := []*txn.Transaction{parent, fakeTx(100, 0, parent), fakeTx(100, 0)}
+    summaries, sets := resolveConflicts(summarizeAll(txns), ConflictRuleFeeRate)
+    remaining := transactionsOf(summaries)
+    if len(remaining) != 3 || sets != nil {
+        t.Errorf("resolveConflicts() = %v, %v", txids(remaining), sets)
+    }
+}
@@ -0,0 +1,226 @@
+package txnpicker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/humblenginr/btc-miner/mining"
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// names of the constraints, used to tell which of them kept transactions out of the template
+const (
+    ConstraintWeight = "weight"
+    ConstraintSigOps = "sigops"
+    ConstraintTxCount = "tx count"
+    ConstraintMinFeeRate = "min feerate"
+    ConstraintMaxFee = "max fee"
+)
+
+// Constraints are the limits the picked transactions have to respect. A zero field means that limit is not enforced.
+type Constraints struct {
+    // MaxWeight is the weight of the whole block, ReservedWeight of which is kept for the header and the coinbase transaction.
+    // The varint giving the number of transactions of the block is counted along with the picked transactions.
+    MaxWeight int
+    ReservedWeight int
+    // MaxSigOpCost is the maximum total sigop cost of the picked transactions
+    MaxSigOpCost int
+    MaxTxCount int
+    // MinFeeRate is the lowest fee rate (sat/vB) picked. It applies to the unit a strategy picks: a transaction, an ancestor package or a chunk.
+    MinFeeRate float64
+    // MaxFee is the maximum total fee of the picked transactions
+    MaxFee int
+}
+
+// WeightLimit returns the weight available to the picked transactions, zero if the weight is not limited
+func (c Constraints) WeightLimit() int {
+    if c.MaxWeight == 0 {
+        return 0
+    }
+    return c.MaxWeight - c.ReservedWeight
+}
+
+func (c Constraints) String() string {
+    parts := make([]string, 0)
+    if c.MaxWeight > 0 {
+        parts = append(parts, fmt.Sprintf("weight <= %d (%d reserved)", c.WeightLimit(), c.ReservedWeight))
+    }
+    if c.MaxSigOpCost > 0 {
+        parts = append(parts, fmt.Sprintf("sigop cost <= %d", c.MaxSigOpCost))
+    }
+    if c.MaxTxCount > 0 {
+        parts = append(parts, fmt.Sprintf("txns <= %d", c.MaxTxCount))
+    }
+    if c.MinFeeRate > 0 {
+        parts = append(parts, fmt.Sprintf("fee rate >= %g sat/vB", c.MinFeeRate))
+    }
+    if c.MaxFee > 0 {
+        parts = append(parts, fmt.Sprintf("fee <= %d", c.MaxFee))
+    }
+    if len(parts) == 0 {
+        return "no constraints"
+    }
+    return strings.Join(parts, ", ")
+}
+
+// totals of a template, or of a group of transactions added to it
+type totals struct {
+    weight int
+    fee int
+    sigOpCost int
+    count int
+}
+
+func nodeTotals(n *TxNode) totals {
+    return totals{weight: n.Weight, fee: n.Fee, sigOpCost: n.SigOpCost, count: 1}
+}
+
+func (t *totals) add(o totals) {
+    t.weight += o.weight
+    t.fee += o.fee
+    t.sigOpCost += o.sigOpCost
+    t.count += o.count
+}
+
+func (t *totals) sub(o totals) {
+    t.weight -= o.weight
+    t.fee -= o.fee
+    t.sigOpCost -= o.sigOpCost
+    t.count -= o.count
+}
+
+// violation returns the first constraint broken by adding the transactions of pkg to a template of totals t, or an empty string if they can be added
+func (c Constraints) violation(t totals, pkg totals) string {
+    if c.MinFeeRate > 0 && float64(pkg.fee) < c.MinFeeRate*float64(policy.GetVSize(pkg.weight)) {
+        return ConstraintMinFeeRate
+    }
+    if c.MaxWeight > 0 && t.weight+pkg.weight+mining.TxCountWeight(t.count+pkg.count+1) > c.WeightLimit() {
+        return ConstraintWeight
+    }
+    if c.MaxSigOpCost > 0 && t.sigOpCost+pkg.sigOpCost > c.MaxSigOpCost {
+        return ConstraintSigOps
+    }
+    if c.MaxTxCount > 0 && t.count+pkg.count > c.MaxTxCount {
+        return ConstraintTxCount
+    }
+    if c.MaxFee > 0 && t.fee+pkg.fee > c.MaxFee {
+        return ConstraintMaxFee
+    }
+    return ""
+}
+
+func (c Constraints) fits(t totals, pkg totals) bool {
+    return c.violation(t, pkg) == ""
+}
+
+// ConstraintUsage is how a constraint affected a template: how much of the limit is used, and the valid transactions it kept out
+type ConstraintUsage struct {
+    Constraint string
+    Used float64
+    Limit float64
+    KeptOutTxns int
+    KeptOutFee int
+}
+
+// ConstraintReport tells which constraint bound a template
+type ConstraintReport struct {
+    Usage []ConstraintUsage
+    // Binding is the constraint that kept out the most fee, empty if no transaction was kept out by a constraint
+    Binding string
+}
+
+func (r ConstraintReport) String() string {
+    lines := make([]string, 0, len(r.Usage)+1)
+    if r.Binding == "" {
+        lines = append(lines, "binding constraint: none")
+    } else {
+        lines = append(lines, "binding constraint: "+r.Binding)
+    }
+    for _, u := range r.Usage {
+        precision := 0
+        if u.Constraint == ConstraintMinFeeRate {
+            precision = 2
+        }
+        lines = append(lines, fmt.Sprintf("  %-12s %12.*f / %-12.*f kept out %d txns, %d sats", u.Constraint, precision, u.Used, precision, u.Limit, u.KeptOutTxns, u.KeptOutFee))
+    }
+    return strings.Join(lines, "\n")
+}
+
+// templateTotals returns the totals of the picked transactions
+func (tp *TransactionsPicker) templateTotals(txns []*txn.Transaction) totals {
+    g := tp.Graph()
+    t := totals{}
+    for _, tx := range txns {
+        if n, ok := g.Node(tx.Txid()); ok {
+            t.add(nodeTotals(n))
+        }
+    }
+    return t
+}
+
+// ExplainConstraints checks every valid transaction left out of the template whose parents are all in it against the constraints, and reports the constraint keeping out each of them along with how much of every limit the template uses.
+func (tp *TransactionsPicker) ExplainConstraints(result SelectionResult) ConstraintReport {
+    c := tp.Constraints
+    t := tp.templateTotals(result.Txns)
+    selected := make(map[string]bool, len(result.Txns))
+    for _, tx := range result.Txns {
+        selected[tx.Txid()] = true
+    }
+    usage := map[string]*ConstraintUsage{}
+    add := func(name string, used, limit float64) {
+        usage[name] = &ConstraintUsage{Constraint: name, Used: used, Limit: limit}
+    }
+    if c.MaxWeight > 0 {
+        add(ConstraintWeight, float64(t.weight+mining.TxCountWeight(t.count+1)), float64(c.WeightLimit()))
+    }
+    if c.MaxSigOpCost > 0 {
+        add(ConstraintSigOps, float64(t.sigOpCost), float64(c.MaxSigOpCost))
+    }
+    if c.MaxTxCount > 0 {
+        add(ConstraintTxCount, float64(t.count), float64(c.MaxTxCount))
+    }
+    if c.MinFeeRate > 0 {
+        used := 0.0
+        if t.weight > 0 {
+            used = feeRate(t.fee, policy.GetVSize(t.weight))
+        }
+        add(ConstraintMinFeeRate, used, c.MinFeeRate)
+    }
+    if c.MaxFee > 0 {
+        add(ConstraintMaxFee, float64(t.fee), float64(c.MaxFee))
+    }
+
+    for _, n := range tp.Graph().Nodes {
+        if selected[n.Txid] || !parentsSelected(n, selected) {
+            continue
+        }
+        if u, ok := usage[c.violation(t, nodeTotals(n))]; ok {
+            u.KeptOutTxns++
+            u.KeptOutFee += n.Fee
+        }
+    }
+
+    r := ConstraintReport{}
+    for _, name := range []string{ConstraintWeight, ConstraintSigOps, ConstraintTxCount, ConstraintMinFeeRate, ConstraintMaxFee} {
+        if u, ok := usage[name]; ok {
+            r.Usage = append(r.Usage, *u)
+        }
+    }
+    byFee := append([]ConstraintUsage{}, r.Usage...)
+    sort.SliceStable(byFee, func(i, j int) bool { return byFee[i].KeptOutFee > byFee[j].KeptOutFee })
+    if len(byFee) > 0 && byFee[0].KeptOutTxns > 0 {
+        r.Binding = byFee[0].Constraint
+    }
+    return r
+}
+
+func parentsSelected(n *TxNode, selected map[string]bool) bool {
+    for _, p := range n.Parents {
+        if !selected[p.Txid] {
+            return false
+        }
+    }
+    return true
+}
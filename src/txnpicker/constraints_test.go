@@ -0,0 +1,74 @@
+package txnpicker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConstraintViolation(t *testing.T) {
+    // a template of 10 transactions of weight 4000
+    template := totals{weight: 40000, fee: 10000, sigOpCost: 100, count: 10}
+    // 1000 vB paying 2 sat/vB
+    pkg := totals{weight: 4000, fee: 2000, sigOpCost: 10, count: 1}
+    tests := []struct {
+        c Constraints
+        want string
+    }{
+        {Constraints{}, ""},
+        {Constraints{MaxWeight: 44000 + 4, ReservedWeight: 0}, ""},
+        {Constraints{MaxWeight: 44000 + 4, ReservedWeight: 1}, ConstraintWeight},
+        {Constraints{MaxSigOpCost: 110}, ""},
+        {Constraints{MaxSigOpCost: 109}, ConstraintSigOps},
+        {Constraints{MaxTxCount: 11}, ""},
+        {Constraints{MaxTxCount: 10}, ConstraintTxCount},
+        {Constraints{MinFeeRate: 2}, ""},
+        {Constraints{MinFeeRate: 2.01}, ConstraintMinFeeRate},
+        {Constraints{MaxFee: 12000}, ""},
+        {Constraints{MaxFee: 11999}, ConstraintMaxFee},
+        // the fee rate is checked first
+        {Constraints{MinFeeRate: 3, MaxTxCount: 1}, ConstraintMinFeeRate},
+    }
+    for _, test := range tests {
+        if got := test.c.violation(template, pkg); got != test.want {
+            t.Errorf("%s: violation() = %q, want %q", test.c, got, test.want)
+        }
+    }
+}
+
+func TestConstraintsString(t *testing.T) {
+    if s := (Constraints{}).String(); s != "no constraints" {
+        t.Errorf("String() = %q", s)
+    }
+    c := Constraints{MaxWeight: 4000000, ReservedWeight: 1000, MaxTxCount: 5}
+    if s := c.String(); s != "weight <= 3999000 (1000 reserved), txns <= 5" {
+        t.Errorf("String() = %q", s)
+    }
+}
+
+func TestExplainConstraints(t *testing.T) {
+    a := fakeTx(5000, 0)
+    b := fakeTx(4000, 0)
+    c := fakeTx(3000, 0)
+    // kept out by its parent, not by a constraint
+    child := fakeTx(3000, 0, c)
+    tp := graphPicker(Constraints{MaxTxCount: 1, MaxSigOpCost: 1000}, a, b, c, child)
+    result := tp.Select(GreedyStrategy{})
+    r := tp.ExplainConstraints(result)
+    if r.Binding != ConstraintTxCount {
+        t.Errorf("binding constraint %q, want %q", r.Binding, ConstraintTxCount)
+    }
+    if len(r.Usage) != 2 || r.Usage[0].Constraint != ConstraintSigOps || r.Usage[1].Constraint != ConstraintTxCount {
+        t.Fatalf("usage %+v", r.Usage)
+    }
+    if u := r.Usage[1]; u.Used != 1 || u.Limit != 1 || u.KeptOutTxns != 2 || u.KeptOutFee != 7000 {
+        t.Errorf("tx count usage %+v", u)
+    }
+    if !strings.HasPrefix(r.String(), "binding constraint: tx count\n") {
+        t.Errorf("String() = %q", r.String())
+    }
+
+    loose := graphPicker(Constraints{MaxTxCount: 10}, a, b)
+    if r := loose.ExplainConstraints(loose.Select(GreedyStrategy{})); r.Binding != "" {
+        t.Errorf("binding constraint %q of a template that is not bound", r.Binding)
+    }
+}
@@ -0,0 +1,169 @@
+package txnpicker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/humblenginr/btc-miner/policy"
+)
+
+// DefaultFeeRateBuckets are the lower bounds (sat/vB) of the buckets of the fee rate histogram
+var DefaultFeeRateBuckets = []float64{1, 2, 3, 4, 5, 6, 8, 10, 12, 15, 20, 30, 40, 50, 60, 70, 80, 90, 100, 125, 150, 175, 200, 250, 300, 350, 400, 500, 600, 700, 800, 900, 1000, 1200, 1400, 1700, 2000}
+
+// BlockEstimate describes one of the successive blocks mined from the mempool by EstimateBlocks
+type BlockEstimate struct {
+    // Height is the position of the block, the next block being 1
+    Height int
+    Txns int
+    Fee int
+    Weight int
+    // MinFeeRate is the fee rate (sat/vB) a transaction has to beat to get into the block: the fee rate of its last chunk
+    MinFeeRate float64
+    // MedianFeeRate is the fee rate (sat/vB) of the chunk in the middle of the block by weight
+    MedianFeeRate float64
+}
+
+func (b BlockEstimate) String() string {
+    return fmt.Sprintf("block %d: %d txns, %d sats, weight %d, min %.2f sat/vB, median %.2f sat/vB", b.Height, b.Txns, b.Fee, b.Weight, b.MinFeeRate, b.MedianFeeRate)
+}
+
+// feeRate returns the fee rate in sat/vB of the transactions of the given fee and virtual size
+func feeRate(fee int, vsize int) float64 {
+    return float64(fee) / float64(vsize)
+}
+
+func chunkVSize(c Chunk) int {
+    vsize := 0
+    for _, n := range c.Nodes {
+        vsize += policy.GetVSize(n.Weight)
+    }
+    return vsize
+}
+
+// EstimateBlocks mines up to count successive blocks from the mempool using the strategy, removing the transactions of every block from the mempool before picking the next one. It stops early once the mempool is empty.
+// An OptimizeStrategy searches every block for its Budget divided by count, so that the estimation takes no longer than a single search.
+func (tp *TransactionsPicker) EstimateBlocks(count int, s SelectionStrategy) []BlockEstimate {
+    if o, ok := s.(*OptimizeStrategy); ok && count > 0 {
+        s = &OptimizeStrategy{Budget: o.Budget / time.Duration(count)}
+    }
+    remaining := *tp
+    remaining.Pool = nil
+    remaining.graph = tp.Graph()
+    blocks := make([]BlockEstimate, 0, count)
+    for height := 1; height <= count && len(remaining.graph.Nodes) > 0; height++ {
+        txns := s.Select(&remaining)
+        if len(txns) == 0 {
+            break
+        }
+        picked := make(map[string]bool, len(txns))
+        nodes := make([]*TxNode, 0, len(txns))
+        for _, t := range txns {
+            n, _ := remaining.graph.Node(t.Txid())
+            picked[n.Txid] = true
+            nodes = append(nodes, n)
+        }
+        blocks = append(blocks, newBlockEstimate(height, nodes))
+
+        left := make([]*txSummary, 0, len(remaining.graph.Nodes)-len(nodes))
+        for _, n := range remaining.graph.Nodes {
+            if !picked[n.Txid] {
+                left = append(left, n.summary)
+            }
+        }
+        next := newGraph(left)
+        // keep the fee deltas
+        for _, n := range next.Nodes {
+            old, _ := remaining.graph.Node(n.Txid)
+            n.ModifiedFee = old.ModifiedFee
+        }
+        remaining.graph = next
+    }
+    return blocks
+}
+
+func newBlockEstimate(height int, nodes []*TxNode) BlockEstimate {
+    b := BlockEstimate{Height: height, Txns: len(nodes)}
+    for _, n := range nodes {
+        b.Fee += n.Fee
+        b.Weight += n.Weight
+    }
+    // the block is in topological order, so its chunks give the fee rates the transactions were effectively picked at
+    chunks := ChunkLinearization(nodes)
+    last := chunks[len(chunks)-1]
+    b.MinFeeRate = feeRate(last.Fee, chunkVSize(last))
+    weight := 0
+    for _, c := range chunks {
+        weight += c.Weight
+        if 2*weight >= b.Weight {
+            b.MedianFeeRate = feeRate(c.Fee, chunkVSize(c))
+            break
+        }
+    }
+    return b
+}
+
+// EstimateFeeRate returns the fee rate (sat/vB) a transaction needs to get into one of the next n blocks: the lowest minimum fee rate among the first n simulated blocks. The blocks are expected to be simulated for at least n blocks, so fewer blocks mean the mempool is cleared before, and the minimum relay fee rate is enough.
+func EstimateFeeRate(blocks []BlockEstimate, n int) float64 {
+    if n <= 0 || n > len(blocks) {
+        return float64(policy.MinRelayFeeRate)
+    }
+    rate := blocks[0].MinFeeRate
+    for _, b := range blocks[:n] {
+        if b.MinFeeRate < rate {
+            rate = b.MinFeeRate
+        }
+    }
+    return rate
+}
+
+// HistogramBucket counts the mempool transactions whose effective fee rate is at least MinFeeRate, and below the MinFeeRate of the next bucket
+type HistogramBucket struct {
+    MinFeeRate float64
+    Count int
+    VSize int
+    Fee int
+}
+
+// FeeRateHistogram groups the mempool transactions into fee rate buckets, given by their lower bounds in increasing order. Transactions below the first bound are counted in the first bucket.
+// The effective fee rate of a transaction is the fee rate of its chunk in the linearization of its cluster, so a parent paid for by its child is counted at the fee rate of the pair.
+func (tp *TransactionsPicker) FeeRateHistogram(bounds []float64) []HistogramBucket {
+    buckets := make([]HistogramBucket, len(bounds))
+    for i, b := range bounds {
+        buckets[i].MinFeeRate = b
+    }
+    for _, cluster := range tp.Graph().Clusters() {
+        for _, c := range ChunkLinearization(cluster.Linearize()) {
+            rate := feeRate(c.Fee, chunkVSize(c))
+            // index of the first bucket above the rate
+            i := sort.Search(len(bounds), func(i int) bool { return bounds[i] > rate })
+            if i > 0 {
+                i--
+            }
+            for _, n := range c.Nodes {
+                buckets[i].Count++
+                buckets[i].VSize += policy.GetVSize(n.Weight)
+                buckets[i].Fee += n.Fee
+            }
+        }
+    }
+    return buckets
+}
+
+// FormatHistogram describes the non empty buckets of the histogram, the highest fee rates first
+func FormatHistogram(buckets []HistogramBucket) string {
+    lines := []string{fmt.Sprintf("%12s %8s %12s %12s", "sat/vB", "txns", "vsize", "fee")}
+    for i := len(buckets) - 1; i >= 0; i-- {
+        b := buckets[i]
+        if b.Count == 0 {
+            continue
+        }
+        label := fmt.Sprintf("%g+", b.MinFeeRate)
+        if i+1 < len(buckets) {
+            label = fmt.Sprintf("%g-%g", b.MinFeeRate, buckets[i+1].MinFeeRate)
+        }
+        lines = append(lines, fmt.Sprintf("%12s %8d %12d %12d", label, b.Count, b.VSize, b.Fee))
+    }
+    return strings.Join(lines, "\n")
+}
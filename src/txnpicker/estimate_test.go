@@ -0,0 +1,64 @@
+package txnpicker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/humblenginr/btc-miner/policy"
+)
+
+func TestEstimateBlocks(t *testing.T) {
+    high := fakeTx(3000, 0)
+    mid := fakeTx(2000, 0)
+    low := fakeTx(1000, 0)
+    vsize := float64(policy.GetVSize(high.GetWeight()))
+    // one transaction per block
+    tp := graphPicker(Constraints{MaxTxCount: 1}, low, mid, high)
+
+    strategies := []SelectionStrategy{GreedyStrategy{}, &OptimizeStrategy{Budget: time.Minute}}
+    for _, s := range strategies {
+        blocks := tp.EstimateBlocks(5, s)
+        if len(blocks) != 3 {
+            t.Fatalf("%s: %d blocks, want 3 as the mempool is then empty", s.Name(), len(blocks))
+        }
+        for i, fee := range []int{3000, 2000, 1000} {
+            b := blocks[i]
+            if b.Height != i+1 || b.Txns != 1 || b.Fee != fee || b.MinFeeRate != float64(fee)/vsize || b.MedianFeeRate != b.MinFeeRate {
+                t.Errorf("%s: %s", s.Name(), b)
+            }
+        }
+        if r := EstimateFeeRate(blocks, 2); r != 2000/vsize {
+            t.Errorf("%s: fee rate within 2 blocks %.3f, want %.3f", s.Name(), r, 2000/vsize)
+        }
+        if r := EstimateFeeRate(blocks, 4); r != float64(policy.MinRelayFeeRate) {
+            t.Errorf("%s: fee rate within more blocks than simulated %.3f", s.Name(), r)
+        }
+    }
+    // the strategy given is not the one searching the blocks
+    if o := strategies[1].(*OptimizeStrategy); o.Budget != time.Minute || o.Result.NodesExplored != 0 {
+        t.Error("EstimateBlocks() changed the optimize strategy")
+    }
+    // the mempool itself is left untouched
+    if len(tp.Graph().Nodes) != 3 {
+        t.Error("EstimateBlocks() removed transactions from the mempool")
+    }
+}
+
+func TestFeeRateHistogram(t *testing.T) {
+    // the parent is counted at the fee rate of the pair
+    parent := fakeTx(0, 0)
+    child := fakeTx(4*policy.GetVSize(parent.GetWeight()), 0, parent)
+    single := fakeTx(policy.GetVSize(parent.GetWeight())/2, 0)
+    tp := graphPicker(Constraints{}, parent, child, single)
+
+    buckets := tp.FeeRateHistogram([]float64{1, 2, 5})
+    counts := []int{1, 2, 0}
+    for i, b := range buckets {
+        if b.Count != counts[i] {
+            t.Errorf("bucket %g: %d txns, want %d", b.MinFeeRate, b.Count, counts[i])
+        }
+    }
+    if buckets[1].Fee != child.GetFees() {
+        t.Errorf("bucket %g: fee %d, want %d", buckets[1].MinFeeRate, buckets[1].Fee, child.GetFees())
+    }
+}
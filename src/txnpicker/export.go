@@ -0,0 +1,138 @@
+package txnpicker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/humblenginr/btc-miner/policy"
+)
+
+// GraphNode is a transaction of the mempool source in the exported graph
+type GraphNode struct {
+    // Name is where the transaction was found, it identifies the node when Txid is not set (see LoadedEntry)
+    Name string `json:"name"`
+    Txid string `json:"txid"`
+    // Fee is the fee paid by the transaction, without its fee delta
+    Fee int `json:"fee"`
+    Weight int `json:"weight"`
+    // FeeRate is in sat/vB
+    FeeRate float64 `json:"feerate"`
+    // Decision is DecisionSelected, DecisionSkipped or DecisionRejected
+    Decision string `json:"decision"`
+    Reason string `json:"reason,omitempty"`
+}
+
+// ID returns the txid of the node, or its name if the txid is not known
+func (n GraphNode) ID() string {
+    if n.Txid == "" {
+        return n.Name
+    }
+    return n.Txid
+}
+
+// GraphEdge is an output of a mempool transaction spent by another one
+type GraphEdge struct {
+    // Parent is the txid of the transaction creating the output
+    Parent string `json:"parent"`
+    Vout int `json:"vout"`
+    // Child is the txid of the transaction spending the output
+    Child string `json:"child"`
+    // Input is the index of the input of Child spending the output
+    Input int `json:"input"`
+}
+
+// GraphExport is the parent/child graph of the transactions of the mempool source, telling which of them are in a template and why the other ones are not
+type GraphExport struct {
+    Strategy string `json:"strategy"`
+    Nodes []GraphNode `json:"nodes"`
+    Edges []GraphEdge `json:"edges"`
+}
+
+// ExportGraph returns the dependency graph of the loaded transactions, with an edge for every output spent inside the mempool. The transactions of the template picked by a strategy are marked as selected, the other valid ones as skipped, and the ones left out when loading the mempool as rejected, along with the reason (see Report).
+func (tp *TransactionsPicker) ExportGraph(result SelectionResult) GraphExport {
+    g := tp.Graph()
+    selected := make(map[string]bool, len(result.Txns))
+    for _, t := range result.Txns {
+        selected[t.Txid()] = true
+    }
+    total := tp.templateTotals(result.Txns)
+    entries := tp.Entries()
+    exported := make(map[string]bool, len(entries))
+    for _, e := range entries {
+        if e.Txid != "" {
+            exported[e.Txid] = true
+        }
+    }
+    ex := GraphExport{Strategy: result.Strategy, Nodes: make([]GraphNode, 0, len(entries)), Edges: make([]GraphEdge, 0)}
+    for _, e := range entries {
+        node := GraphNode{Name: e.Name, Txid: e.Txid}
+        // the size of the transactions failing CheckTransaction cannot always be computed
+        if e.Txid != "" {
+            node.Fee, node.Weight = e.Fee, e.Weight
+            node.FeeRate = feeRate(node.Fee, policy.GetVSize(node.Weight))
+        }
+        switch {
+        case e.Rejection != "":
+            node.Decision = DecisionRejected
+            node.Reason = e.Rejection
+            if e.Detail != "" {
+                node.Reason += ": " + e.Detail
+            }
+        case selected[e.Txid]:
+            node.Decision = DecisionSelected
+        default:
+            node.Decision = DecisionSkipped
+            n, _ := g.Node(e.Txid)
+            node.Reason = skipReason(tp.Constraints, n, selected, total)
+        }
+        ex.Nodes = append(ex.Nodes, node)
+        if !exported[e.Txid] {
+            continue
+        }
+        for i, op := range e.Inputs {
+            if exported[op.Txid] {
+                ex.Edges = append(ex.Edges, GraphEdge{Parent: op.Txid, Vout: op.Vout, Child: e.Txid, Input: i})
+            }
+        }
+    }
+    return ex
+}
+
+func (e GraphExport) WriteJSON(w io.Writer) error {
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    return enc.Encode(e)
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language. The selected transactions are filled in green, the skipped ones in grey and the rejected ones in red, and every edge is labelled with the output it spends.
+func (e GraphExport) WriteDOT(w io.Writer) error {
+    bw := bufio.NewWriter(w)
+    fmt.Fprintf(bw, "digraph mempool {\n")
+    fmt.Fprintf(bw, "  label=%q;\n", "template picked by "+e.Strategy)
+    fmt.Fprintf(bw, "  rankdir=LR;\n")
+    fmt.Fprintf(bw, "  node [shape=box, style=filled, fontname=monospace];\n")
+    for _, n := range e.Nodes {
+        id := n.ID()
+        if len(id) > 16 {
+            id = id[:16] + "…"
+        }
+        label := fmt.Sprintf("%s\n%.2f sat/vB\nfee %d, weight %d", id, n.FeeRate, n.Fee, n.Weight)
+        color := `fillcolor=palegreen, penwidth=2`
+        switch n.Decision {
+        case DecisionSkipped:
+            label += "\nskipped: " + n.Reason
+            color = `fillcolor=lightgrey, color=grey50`
+        case DecisionRejected:
+            label += "\nrejected: " + n.Reason
+            color = `fillcolor=mistyrose, color=red3, style="filled,dashed"`
+        }
+        fmt.Fprintf(bw, "  %q [label=%q, %s];\n", n.ID(), label, color)
+    }
+    for _, edge := range e.Edges {
+        fmt.Fprintf(bw, "  %q -> %q [label=\"%d\"];\n", edge.Parent, edge.Child, edge.Vout)
+    }
+    fmt.Fprintf(bw, "}\n")
+    return bw.Flush()
+}
@@ -0,0 +1,93 @@
+package txnpicker
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportGraph(t *testing.T) {
+    tp := reportPicker(t, Constraints{MaxTxCount: 1})
+    e := tp.ExportGraph(tp.Select(GreedyStrategy{}))
+    r := tp.Report(tp.Select(GreedyStrategy{}))
+
+    if len(e.Nodes) != testdataMempoolSize+1 {
+        t.Fatalf("got %d nodes, want %d", len(e.Nodes), testdataMempoolSize+1)
+    }
+    // the nodes are the rows of the report
+    for i, n := range e.Nodes {
+        row := r.Transactions[i]
+        if n.Name != row.Name || n.Txid != row.Txid || n.Fee != row.Fee || n.Decision != row.Decision || n.Reason != row.Reason {
+            t.Errorf("node %+v does not match the report row %+v", n, row)
+        }
+    }
+
+    parent, middle := readMempoolFile(t, chainParent).Txid(), readMempoolFile(t, chainMiddle).Txid()
+    ids := make(map[string]GraphNode, len(e.Nodes))
+    for _, n := range e.Nodes {
+        ids[n.ID()] = n
+    }
+    tests := []struct {
+        id string
+        decision string
+        reason string
+    }{
+        {parent, DecisionRejected, RejectInvalid},
+        {middle, DecisionRejected, RejectOrphan},
+        // the malformed transaction has no txid
+        {"bad.json", DecisionRejected, RejectInvalid},
+    }
+    for _, test := range tests {
+        n, ok := ids[test.id]
+        if !ok || n.Decision != test.decision || !strings.HasPrefix(n.Reason, test.reason) {
+            t.Errorf("%s: got node %+v, want %s (%s)", test.id, n, test.decision, test.reason)
+        }
+    }
+
+    // the edges between rejected transactions are kept
+    found := false
+    for _, edge := range e.Edges {
+        if _, ok := ids[edge.Parent]; !ok {
+            t.Errorf("edge from %s, which is not a node", edge.Parent)
+        }
+        if _, ok := ids[edge.Child]; !ok {
+            t.Errorf("edge to %s, which is not a node", edge.Child)
+        }
+        found = found || edge.Parent == parent && edge.Child == middle
+    }
+    if !found {
+        t.Error("no edge from the invalid parent to its orphan child")
+    }
+}
+
+func TestExportGraphOutput(t *testing.T) {
+    tp := reportPicker(t, Constraints{MaxTxCount: 1})
+    e := tp.ExportGraph(tp.Select(GreedyStrategy{}))
+
+    var j bytes.Buffer
+    if err := e.WriteJSON(&j); err != nil {
+        t.Fatal(err)
+    }
+    var decoded GraphExport
+    if err := json.Unmarshal(j.Bytes(), &decoded); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(decoded, e) {
+        t.Error("JSON graph does not decode to the graph")
+    }
+
+    var dot bytes.Buffer
+    if err := e.WriteDOT(&dot); err != nil {
+        t.Fatal(err)
+    }
+    for _, want := range []string{"digraph mempool {", `"bad.json" [label=`, "rejected: " + RejectOrphan, "skipped: ", "palegreen"} {
+        if !strings.Contains(dot.String(), want) {
+            t.Errorf("DOT output has no %q", want)
+        }
+    }
+    if got := strings.Count(dot.String(), " -> "); got != len(e.Edges) {
+        t.Errorf("DOT output has %d edges, want %d", got, len(e.Edges))
+    }
+}
@@ -0,0 +1,69 @@
+package txnpicker
+
+import "math/bits"
+
+// Fee rates are compared exactly by cross multiplying the fees and weights instead of dividing them. The products are computed on 128 bits, as the fees can include large fee deltas.
+
+// compareFeeRate returns 1 if fee1/weight1 > fee2/weight2, -1 if it is lower and 0 if they are equal
+func compareFeeRate(fee1, weight1, fee2, weight2 int) int {
+    return multiply(fee1, weight2).compare(multiply(fee2, weight1))
+}
+
+// product is the result of multiplying two ints: its sign and the 128 bits of its magnitude
+type product struct {
+    negative bool
+    hi, lo uint64
+}
+
+func multiply(x, y int) product {
+    hi, lo := bits.Mul64(magnitude(x), magnitude(y))
+    return product{negative: (x < 0) != (y < 0) && (hi != 0 || lo != 0), hi: hi, lo: lo}
+}
+
+// magnitude returns |x|, which does not overflow for the lowest int once converted
+func magnitude(x int) uint64 {
+    if x < 0 {
+        return uint64(-x)
+    }
+    return uint64(x)
+}
+
+// compare returns 1 if p > o, -1 if it is lower and 0 if they are equal
+func (p product) compare(o product) int {
+    if p.negative != o.negative {
+        if p.negative {
+            return -1
+        }
+        return 1
+    }
+    c := 0
+    switch {
+    case p.hi != o.hi:
+        c = 1
+        if p.hi < o.hi {
+            c = -1
+        }
+    case p.lo != o.lo:
+        c = 1
+        if p.lo < o.lo {
+            c = -1
+        }
+    }
+    if p.negative {
+        return -c
+    }
+    return c
+}
+
+// higherFeeRate reports whether fee1/weight1 > fee2/weight2
+func higherFeeRate(fee1, weight1, fee2, weight2 int) bool {
+    return compareFeeRate(fee1, weight1, fee2, weight2) > 0
+}
+
+// higherPriority reports whether a is picked before b: the transaction with the higher fee rate, counting the fee deltas, or the one with the lower wtxid if the fee rates are equal
+func higherPriority(a, b *TxNode) bool {
+    if c := compareFeeRate(a.ModifiedFee, a.Weight, b.ModifiedFee, b.Weight); c != 0 {
+        return c > 0
+    }
+    return a.Wtxid < b.Wtxid
+}
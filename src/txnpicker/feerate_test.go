@@ -0,0 +1,59 @@
+package txnpicker
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareFeeRate(t *testing.T) {
+    tests := []struct {
+        fee1, weight1, fee2, weight2 int
+        want int
+    }{
+        {100, 400, 200, 800, 0},
+        {101, 400, 200, 800, 1},
+        {100, 401, 200, 800, -1},
+        // would be equal after rounding to float32
+        {16777217, 4, 16777216, 4, 1},
+        {0, 400, 0, 800, 0},
+        // the products overflow 64 bits
+        {1 << 62, 400, 100, 400, 1},
+        {100, 400, 1 << 62, 400, -1},
+        {1 << 62, 400, 1<<62 + 1, 400, -1},
+        {math.MaxInt64, math.MaxInt64, math.MaxInt64 - 1, math.MaxInt64, 1},
+        // negative fee deltas
+        {-(1 << 62), 400, 100, 400, -1},
+        {-(1 << 62), 400, -(1 << 62), 401, -1},
+        {math.MinInt64, 400, -100, 400, -1},
+        {-100, 400, 0, 400, -1},
+        {0, 400, -100, 400, 1},
+    }
+    for _, test := range tests {
+        if got := compareFeeRate(test.fee1, test.weight1, test.fee2, test.weight2); got != test.want {
+            t.Errorf("compareFeeRate(%d, %d, %d, %d) = %d, want %d", test.fee1, test.weight1, test.fee2, test.weight2, got, test.want)
+        }
+        if got := higherFeeRate(test.fee1, test.weight1, test.fee2, test.weight2); got != (test.want > 0) {
+            t.Errorf("higherFeeRate(%d, %d, %d, %d) = %v", test.fee1, test.weight1, test.fee2, test.weight2, got)
+        }
+    }
+}
+
+func TestHigherPriority(t *testing.T) {
+    a := &TxNode{Wtxid: "aa", Fee: 100, ModifiedFee: 100, Weight: 400}
+    b := &TxNode{Wtxid: "bb", Fee: 100, ModifiedFee: 100, Weight: 400}
+    prioritised := &TxNode{Wtxid: "cc", Fee: 50, ModifiedFee: 150, Weight: 400}
+    if !higherPriority(a, b) || higherPriority(b, a) {
+        t.Error("equal fee rates are not ordered by wtxid")
+    }
+    if !higherPriority(prioritised, a) {
+        t.Error("the fee delta is not counted")
+    }
+    if higherPriority(a, a) {
+        t.Error("a transaction is of higher priority than itself")
+    }
+    // a fee delta large enough to overflow the cross multiplication on 64 bits
+    huge := &TxNode{Wtxid: "dd", Fee: 100, ModifiedFee: 1 << 60, Weight: 4000}
+    if !higherPriority(huge, a) || higherPriority(a, huge) {
+        t.Error("a huge fee delta does not put the transaction first")
+    }
+}
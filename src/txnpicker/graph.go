@@ -0,0 +1,141 @@
+package txnpicker
+
+import (
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// TxNode is a mempool transaction along with its in-mempool parents (the transactions it spends outputs of) and children (the transactions spending its outputs).
+type TxNode struct {
+    // Tx is nil for the transactions loaded by streaming, the picker reads them again from the source (see TransactionsPicker.Streaming)
+    Tx *txn.Transaction
+    Txid string
+    Wtxid string
+    // Index is the position of the transaction in the Nodes of the graph
+    Index int
+    // Fee is the fee paid by the transaction
+    Fee int
+    // ModifiedFee is Fee plus the fee delta of the transaction, if any (see PrioritiseTransaction). It only changes the order the transactions are picked in.
+    ModifiedFee int
+    Weight int
+    SigOpCost int
+    // Time is when the transaction entered the mempool (unix time), zero if the source does not tell
+    Time int64
+    Parents []*TxNode
+    Children []*TxNode
+    summary *txSummary
+}
+
+// DependencyGraph is the parent/child graph of the mempool transactions. Nodes keeps the order the transactions were given in.
+type DependencyGraph struct {
+    Nodes []*TxNode
+    byTxid map[string]*TxNode
+}
+
+// PrioritiseTransaction adds delta to the fee the transaction is picked by, like the prioritisetransaction RPC of bitcoin core. The fee paid by the transaction does not change. Nothing is done if the transaction is not in the graph.
+func (g *DependencyGraph) PrioritiseTransaction(txid string, delta int) {
+    if n, ok := g.byTxid[txid]; ok {
+        n.ModifiedFee += delta
+    }
+}
+
+// NewDependencyGraph builds the graph of the given transactions. An input creates an edge only if the transaction it spends is one of txns, the other inputs are considered to be confirmed.
+func NewDependencyGraph(txns []*txn.Transaction) *DependencyGraph {
+    return newGraph(summarizeAll(txns))
+}
+
+// newGraph builds the graph of the summarized transactions, see NewDependencyGraph
+func newGraph(summaries []*txSummary) *DependencyGraph {
+    g := &DependencyGraph{Nodes: make([]*TxNode, 0, len(summaries)), byTxid: make(map[string]*TxNode, len(summaries))}
+    for _, s := range summaries {
+        n := &TxNode{Tx: s.tx, Txid: s.Txid, Wtxid: s.wtxid, Index: len(g.Nodes), Fee: s.fee, Weight: s.weight, SigOpCost: s.sigOpCost, Time: s.time, summary: s}
+        n.ModifiedFee = n.Fee
+        g.Nodes = append(g.Nodes, n)
+        g.byTxid[n.Txid] = n
+    }
+    for _, n := range g.Nodes {
+        seen := make(map[string]bool)
+        for _, op := range n.summary.Inputs {
+            parent, ok := g.byTxid[op.Txid]
+            if !ok || seen[op.Txid] {
+                continue
+            }
+            seen[op.Txid] = true
+            n.Parents = append(n.Parents, parent)
+            parent.Children = append(parent.Children, n)
+        }
+    }
+    return g
+}
+
+// Node returns the node of the transaction with the given txid
+func (g *DependencyGraph) Node(txid string) (*TxNode, bool) {
+    n, ok := g.byTxid[txid]
+    return n, ok
+}
+
+// Ancestors returns all the in-mempool ancestors of the node, not including the node itself
+func (g *DependencyGraph) Ancestors(n *TxNode) []*TxNode {
+    return walk(n, func(n *TxNode) []*TxNode { return n.Parents })
+}
+
+// Descendants returns all the in-mempool descendants of the node, not including the node itself
+func (g *DependencyGraph) Descendants(n *TxNode) []*TxNode {
+    return walk(n, func(n *TxNode) []*TxNode { return n.Children })
+}
+
+func walk(start *TxNode, next func(*TxNode) []*TxNode) []*TxNode {
+    visited := map[*TxNode]bool{start: true}
+    result := make([]*TxNode, 0)
+    stack := append([]*TxNode{}, next(start)...)
+    for len(stack) > 0 {
+        n := stack[len(stack)-1]
+        stack = stack[:len(stack)-1]
+        if visited[n] {
+            continue
+        }
+        visited[n] = true
+        result = append(result, n)
+        stack = append(stack, next(n)...)
+    }
+    return result
+}
+
+// IsTopologicallySorted reports whether every transaction in txns comes after all of its in-mempool parents that are in txns.
+func IsTopologicallySorted(txns []*txn.Transaction) bool {
+    position := make(map[string]int, len(txns))
+    for i, t := range txns {
+        position[t.Txid()] = i
+    }
+    for i, t := range txns {
+        for _, in := range t.Vin {
+            if p, ok := position[in.Txid]; ok && p >= i {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+// excludeOrphans drops the transactions spending outputs of the excluded transactions, and then the ones spending their outputs and so on. The kept transactions are returned in order.
+func excludeOrphans(txns []*txSummary, excluded map[string]bool) []*txSummary {
+    g := newGraph(txns)
+    dropped := make(map[*TxNode]bool)
+    for _, n := range g.Nodes {
+        for _, op := range n.summary.Inputs {
+            if excluded[op.Txid] {
+                dropped[n] = true
+                for _, d := range g.Descendants(n) {
+                    dropped[d] = true
+                }
+                break
+            }
+        }
+    }
+    kept := make([]*txSummary, 0, len(txns))
+    for _, n := range g.Nodes {
+        if !dropped[n] {
+            kept = append(kept, n.summary)
+        }
+    }
+    return kept
+}
@@ -0,0 +1,180 @@
+package txnpicker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/humblenginr/btc-miner/internal/txtest"
+	"github.com/humblenginr/btc-miner/mining"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// fakeTx returns an unsigned transaction paying fee, spending the first output of each parent, or a confirmed output of 100000 sats if it has none. The output script is padded with pad bytes to make the transaction heavier.
+func fakeTx(fee int, pad int, parents ...*txn.Transaction) *txn.Transaction {
+    id := txtest.NextID()
+    tx := &txn.Transaction{Version: 2, Locktime: uint32(id)}
+    value := 0
+    if len(parents) == 0 {
+        prevOut := txn.Vout{ScriptPubKey: "51", Value: 100000}
+        tx.Vin = append(tx.Vin, txn.Vin{Txid: fmt.Sprintf("%064x", id), PrevOut: prevOut, Sequence: 0xffffffff})
+        value = prevOut.Value
+    }
+    for _, p := range parents {
+        tx.Vin = append(tx.Vin, txn.Vin{Txid: p.Txid(), Vout: 0, PrevOut: p.Vout[0], Sequence: 0xffffffff})
+        value += p.Vout[0].Value
+    }
+    tx.Vout = []txn.Vout{{ScriptPubKey: "51" + strings.Repeat("00", pad), Value: value - fee}}
+    return tx
+}
+
+// graphPicker returns a picker using the given transactions as the valid mempool
+func graphPicker(c Constraints, txns ...*txn.Transaction) *TransactionsPicker {
+    tp := NewTransactionPicker("", c)
+    tp.graph = NewDependencyGraph(txns)
+    return &tp
+}
+
+func txids(txns []*txn.Transaction) []string {
+    ids := make([]string, 0, len(txns))
+    for _, t := range txns {
+        ids = append(ids, t.Txid())
+    }
+    return ids
+}
+
+// transactionsOf returns the transactions of the summaries
+func transactionsOf(summaries []*txSummary) []*txn.Transaction {
+    txns := make([]*txn.Transaction, 0, len(summaries))
+    for _, s := range summaries {
+        txns = append(txns, s.tx)
+    }
+    return txns
+}
+
+func TestDependencyGraph(t *testing.T) {
+    parent := fakeTx(100, 0)
+    other := fakeTx(100, 0)
+    child := fakeTx(100, 0, parent, other)
+    grandchild := fakeTx(100, 0, child)
+    // spends the parent twice, the edge is only added once
+    twice := fakeTx(100, 0, parent)
+    twice.Vin = append(twice.Vin, txn.Vin{Txid: parent.Txid(), Vout: 1, PrevOut: txn.Vout{ScriptPubKey: "51"}})
+
+    g := NewDependencyGraph([]*txn.Transaction{grandchild, child, parent, other, twice})
+    tests := []struct {
+        tx *txn.Transaction
+        parents int
+        children int
+        ancestors int
+        descendants int
+    }{
+        {parent, 0, 2, 0, 3},
+        {other, 0, 1, 0, 2},
+        {child, 2, 1, 2, 1},
+        {grandchild, 1, 0, 3, 0},
+        {twice, 1, 0, 1, 0},
+    }
+    for i, test := range tests {
+        n, ok := g.Node(test.tx.Txid())
+        if !ok {
+            t.Fatalf("%d: node not found", i)
+        }
+        if len(n.Parents) != test.parents || len(n.Children) != test.children {
+            t.Errorf("%d: %d parents and %d children, want %d and %d", i, len(n.Parents), len(n.Children), test.parents, test.children)
+        }
+        if a, d := len(g.Ancestors(n)), len(g.Descendants(n)); a != test.ancestors || d != test.descendants {
+            t.Errorf("%d: %d ancestors and %d descendants, want %d and %d", i, a, d, test.ancestors, test.descendants)
+        }
+    }
+    if g.Nodes[0].Tx != grandchild || g.Nodes[0].Index != 0 {
+        t.Error("nodes are not in the given order")
+    }
+}
+
+func TestIsTopologicallySorted(t *testing.T) {
+    parent := fakeTx(100, 0)
+    child := fakeTx(100, 0, parent)
+    other := fakeTx(100, 0)
+    tests := []struct {
+        txns []*txn.Transaction
+        want bool
+    }{
+        {[]*txn.Transaction{parent, child, other}, true},
+        {[]*txn.Transaction{other, parent, child}, true},
+        {[]*txn.Transaction{child, parent}, false},
+        // the parent is not in the list, it is considered confirmed
+        {[]*txn.Transaction{child, other}, true},
+    }
+    for i, test := range tests {
+        if got := IsTopologicallySorted(test.txns); got != test.want {
+            t.Errorf("%d: IsTopologicallySorted() = %v, want %v", i, got, test.want)
+        }
+    }
+}
+
+func TestPickInDependencyOrder(t *testing.T) {
+    // the child pays a much higher fee rate than its parent, but cannot come before it
+    parent := fakeTx(100, 0)
+    child := fakeTx(50000, 0, parent)
+    middle := fakeTx(5000, 0)
+    tp := graphPicker(Constraints{}, child, middle, parent)
+    got := tp.PickUsingPQ()
+    if !IsTopologicallySorted(got) || len(got) != 3 {
+        t.Fatalf("PickUsingPQ() = %v", txids(got))
+    }
+    if got[0] != middle {
+        t.Errorf("first picked %s, want the transaction with the highest fee rate without parents", got[0].Txid())
+    }
+}
+
+func TestPickDropsChildrenOfExcluded(t *testing.T) {
+    // the parent is too heavy to fit, so neither its child nor its grandchild can be picked
+    parent := fakeTx(100000-1000, 2000)
+    child := fakeTx(100, 0, parent)
+    grandchild := fakeTx(100, 0, child)
+    small := fakeTx(100, 0)
+    c := Constraints{MaxWeight: parent.GetWeight() - 1}
+    tp := graphPicker(c, parent, child, grandchild, small)
+    got := tp.PickUsingPQ()
+    if len(got) != 1 || got[0] != small {
+        t.Errorf("PickUsingPQ() = %v, want only %s", txids(got), small.Txid())
+    }
+}
+
+func TestExcludeOrphans(t *testing.T) {
+    parent := fakeTx(100, 0)
+    child := fakeTx(100, 0, parent)
+    grandchild := fakeTx(100, 0, child)
+    other := fakeTx(100, 0)
+    // the excluded transaction is not in the list, its descendants are dropped
+    kept := transactionsOf(excludeOrphans(summarizeAll([]*txn.Transaction{grandchild, child, other}), map[string]bool{parent.Txid(): true}))
+    if len(kept) != 1 || kept[0] != other {
+        t.Errorf("excludeOrphans() = %v", txids(kept))
+    }
+}
+
+func TestPrioritiseTransaction(t *testing.T) {
+    low := fakeTx(100, 0)
+    high := fakeTx(5000, 0)
+    tp := graphPicker(Constraints{MaxWeight: low.GetWeight() + mining.TxCountWeight(2)}, low, high)
+    g := tp.Graph()
+    g.PrioritiseTransaction(low.Txid(), 10000)
+    g.PrioritiseTransaction(fakeTx(100, 0).Txid(), 10000)
+    n, _ := g.Node(low.Txid())
+    if n.Fee != 100 || n.ModifiedFee != 10100 {
+        t.Fatalf("fee %d, modified fee %d, want 100 and 10100", n.Fee, n.ModifiedFee)
+    }
+
+    // the delta changes the order the transactions are picked in, but not the fee collected
+    for _, s := range []SelectionStrategy{GreedyStrategy{}, AncestorScoreStrategy{}, ClusterStrategy{}} {
+        r := tp.Select(s)
+        if len(r.Txns) != 1 || r.Txns[0] != low || r.Fee != 100 {
+            t.Errorf("%s picked %v for %d sats, want the prioritised transaction for 100 sats", s.Name(), txids(r.Txns), r.Fee)
+        }
+        if total := tp.templateTotals(r.Txns); total.fee != 100 {
+            t.Errorf("%s: template totals count %d sats", s.Name(), total.fee)
+        }
+    }
+}
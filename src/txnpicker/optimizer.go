@@ -0,0 +1,186 @@
+package txnpicker
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// OptimizeResult is the best template found by Optimize along with how it compares to the greedy baseline (PickUsingPQ)
+type OptimizeResult struct {
+    Txns []*txn.Transaction
+    Fee int
+    Weight int
+    BaselineFee int
+    // UpperBound is the highest fee any template could collect, as given by the fractional knapsack relaxation at the root of the search
+    UpperBound int
+    // Complete is true if the whole search tree was explored, in which case the template is optimal
+    Complete bool
+    NodesExplored int
+}
+
+// Improvement is the extra fee collected compared to the greedy baseline
+func (r OptimizeResult) Improvement() int {
+    return r.Fee - r.BaselineFee
+}
+
+// Gap is how far the template can be from the optimum
+func (r OptimizeResult) Gap() int {
+    if r.Complete {
+        return 0
+    }
+    return r.UpperBound - r.Fee
+}
+
+func (r OptimizeResult) String() string {
+    return fmt.Sprintf("optimizer: %d txns, %d sats, weight %d\ngreedy baseline: %d sats\nimprovement: %+d sats\ngap to upper bound: %d sats (complete: %v, nodes explored: %d)",
+        len(r.Txns), r.Fee, r.Weight, r.BaselineFee, r.Improvement(), r.Gap(), r.Complete, r.NodesExplored)
+}
+
+type optimizer struct {
+    tp *TransactionsPicker
+    // the transactions in the order the decisions are taken, parents always come before their children
+    items []*TxNode
+    position map[*TxNode]int
+    // indices of items sorted by fee/weight ratio, used to calculate the bounds
+    byFeeRate []int
+
+    included []bool
+    total totals
+
+    best []bool
+    bestFee int
+
+    deadline time.Time
+    nodes int
+    timedOut bool
+}
+
+// Optimize searches for the template collecting the highest fee using branch and bound, starting from the greedy solution. Every transaction is either included (only if all of its in-mempool parents are) or excluded, and a branch is pruned when even the fractional knapsack relaxation of the remaining transactions cannot beat the best template found so far.
+// The search stops when budget runs out, and the best template found by then is returned. Fee deltas are not counted: the template is the one collecting the most fee actually paid.
+func (tp *TransactionsPicker) Optimize(budget time.Duration) OptimizeResult {
+    baseline := tp.pickNodesInDependencyOrder(higherPriority)
+    o := &optimizer{tp: tp, deadline: time.Now().Add(budget)}
+
+    // deciding the transactions in the order of the chunks of the cluster linearizations finds good templates early
+    o.items = mergeByChunkFeeRate(tp.Graph().Clusters())
+    o.position = make(map[*TxNode]int, len(o.items))
+    o.byFeeRate = make([]int, len(o.items))
+    for i, n := range o.items {
+        o.position[n] = i
+        o.byFeeRate[i] = i
+    }
+    sort.SliceStable(o.byFeeRate, func(i, j int) bool {
+        a, b := o.items[o.byFeeRate[i]], o.items[o.byFeeRate[j]]
+        return higherFeeRate(a.Fee, a.Weight, b.Fee, b.Weight)
+    })
+    o.included = make([]bool, len(o.items))
+
+    // the greedy baseline is the first best solution
+    o.best = make([]bool, len(o.items))
+    for _, n := range baseline {
+        o.best[o.position[n]] = true
+        o.bestFee += n.Fee
+    }
+    baselineFee := o.bestFee
+    upperBound := o.bound(0)
+
+    o.search(0)
+
+    result := OptimizeResult{BaselineFee: baselineFee, UpperBound: upperBound, Complete: !o.timedOut, NodesExplored: o.nodes}
+    selected := make([]*TxNode, 0)
+    for i, n := range o.items {
+        if o.best[i] {
+            selected = append(selected, n)
+            result.Fee += n.Fee
+            result.Weight += n.Weight
+        }
+    }
+    if len(selected) > 0 {
+        result.Txns = tp.transactions(selected)
+    }
+    return result
+}
+
+// mergeByChunkFeeRate returns the transactions of all the clusters, ordered by the fee rate of their chunks
+func mergeByChunkFeeRate(clusters []*Cluster) []*TxNode {
+    chunks := make([]Chunk, 0)
+    for _, c := range clusters {
+        chunks = append(chunks, ChunkLinearization(c.Linearize())...)
+    }
+    // the chunks of a cluster have non-increasing fee rates, so a stable sort keeps them in order
+    sort.SliceStable(chunks, func(i, j int) bool {
+        return higherFeeRate(chunks[i].Fee, chunks[i].Weight, chunks[j].Fee, chunks[j].Weight)
+    })
+    nodes := make([]*TxNode, 0)
+    for _, c := range chunks {
+        nodes = append(nodes, c.Nodes...)
+    }
+    return nodes
+}
+
+// bound returns the fee of the current partial template plus the fractional knapsack relaxation of the undecided transactions (the ones from depth onwards)
+func (o *optimizer) bound(depth int) int {
+    c := o.tp.Constraints
+    fee := o.total.fee
+    capacity := c.WeightLimit() - o.total.weight
+    for _, i := range o.byFeeRate {
+        if c.MaxWeight > 0 && capacity <= 0 {
+            break
+        }
+        if i < depth {
+            continue
+        }
+        n := o.items[i]
+        if c.MaxWeight == 0 || n.Weight <= capacity {
+            fee += n.Fee
+            capacity -= n.Weight
+        } else {
+            fee += n.Fee * capacity / n.Weight
+            capacity = 0
+        }
+    }
+    if c.MaxFee > 0 && fee > c.MaxFee {
+        fee = c.MaxFee
+    }
+    return fee
+}
+
+func (o *optimizer) canInclude(n *TxNode) bool {
+    for _, p := range n.Parents {
+        if !o.included[o.position[p]] {
+            return false
+        }
+    }
+    return o.tp.Constraints.fits(o.total, nodeTotals(n))
+}
+
+func (o *optimizer) search(depth int) {
+    if o.timedOut {
+        return
+    }
+    o.nodes++
+    if o.nodes%1024 == 0 && time.Now().After(o.deadline) {
+        o.timedOut = true
+        return
+    }
+    if o.total.fee > o.bestFee {
+        o.bestFee = o.total.fee
+        copy(o.best, o.included)
+    }
+    if depth == len(o.items) || o.bound(depth) <= o.bestFee {
+        return
+    }
+
+    n := o.items[depth]
+    if o.canInclude(n) {
+        o.included[depth] = true
+        o.total.add(nodeTotals(n))
+        o.search(depth + 1)
+        o.included[depth] = false
+        o.total.sub(nodeTotals(n))
+    }
+    o.search(depth + 1)
+}
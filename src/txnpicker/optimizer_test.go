@@ -0,0 +1,61 @@
+package txnpicker
+
+import (
+	"testing"
+
+	"github.com/humblenginr/btc-miner/mining"
+	"time"
+)
+
+func TestOptimize(t *testing.T) {
+    // the small transaction pays the most per weight, but the big one alone collects more
+    small := fakeTx(3000, 0)
+    big := fakeTx(4000, small.GetWeight()/8)
+    if big.GetWeight() >= 2*small.GetWeight() {
+        t.Fatal("the big transaction is too heavy")
+    }
+    c := Constraints{MaxWeight: big.GetWeight() + mining.TxCountWeight(2)}
+    tp := graphPicker(c, small, big)
+
+    r := tp.Optimize(time.Minute)
+    if !r.Complete || r.Gap() != 0 {
+        t.Errorf("search not complete: %s", r)
+    }
+    if r.BaselineFee != 3000 || r.Fee != 4000 || r.Improvement() != 1000 {
+        t.Errorf("baseline %d and fee %d, want 3000 and 4000", r.BaselineFee, r.Fee)
+    }
+    if len(r.Txns) != 1 || r.Txns[0] != big || r.Weight != big.GetWeight() {
+        t.Errorf("Optimize() picked %v", txids(r.Txns))
+    }
+    if r.UpperBound < r.Fee {
+        t.Errorf("upper bound %d below the optimum %d", r.UpperBound, r.Fee)
+    }
+}
+
+func TestOptimizeKeepsParents(t *testing.T) {
+    // the child pays the most, but only fits along with its parent if the other transaction is left out
+    parent := fakeTx(100, 0)
+    child := fakeTx(9000, 0, parent)
+    other := fakeTx(5000, 0)
+    weight := parent.GetWeight()
+    tp := graphPicker(Constraints{MaxWeight: 2*weight + mining.TxCountWeight(3)}, parent, child, other)
+
+    r := tp.Optimize(time.Minute)
+    if r.Fee != 9100 || len(r.Txns) != 2 || !IsTopologicallySorted(r.Txns) {
+        t.Errorf("Optimize() picked %v for %d sats, want the parent and the child", txids(r.Txns), r.Fee)
+    }
+}
+
+func TestOptimizeWithoutBudget(t *testing.T) {
+    // the search stops right away, the greedy template is returned
+    parent := fakeTx(100, 0)
+    child := fakeTx(9000, 0, parent)
+    other := fakeTx(5000, 0)
+    weight := parent.GetWeight()
+    tp := graphPicker(Constraints{MaxWeight: 2*weight + mining.TxCountWeight(3)}, parent, child, other)
+
+    r := tp.Optimize(0)
+    if r.Fee < r.BaselineFee || !IsTopologicallySorted(r.Txns) {
+        t.Errorf("Optimize(0) = %s", r)
+    }
+}
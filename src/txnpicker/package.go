@@ -0,0 +1,232 @@
+package txnpicker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utxo"
+	"github.com/humblenginr/btc-miner/validation"
+)
+
+// Implemented using the package validation of bitcoin core (ProcessNewPackage and the testmempoolaccept RPC) as the reference
+
+var (
+    // MaxPackageCount is the maximum number of transactions in a package
+    MaxPackageCount = 25
+    // MaxPackageWeight is the maximum total weight of the transactions of a package
+    MaxPackageWeight = 404000
+)
+
+var (
+    ErrPackageEmpty = errors.New("package has no transactions")
+    ErrPackageTooManyTxns = errors.New("package has too many transactions")
+    ErrPackageTooLarge = errors.New("package is too large")
+    ErrPackageDuplicate = errors.New("package contains the same transaction twice")
+    ErrPackageNotSorted = errors.New("package is not sorted, a transaction comes before one of its parents")
+    ErrPackageConflict = errors.New("package transactions spend the same output")
+    ErrPackageFeeTooLow = errors.New("package fee rate is below the minimum fee rate")
+    // ErrPackageTxFailed is returned when one of the transactions of the package is invalid
+    ErrPackageTxFailed = errors.New("package transaction failed validation")
+)
+
+// PackageTxResult is the outcome of the validation of a transaction of a package
+type PackageTxResult struct {
+    Txid string
+    Wtxid string
+    Fee int
+    VSize int
+    Accepted bool
+    // EffectiveFeeRate is the fee rate (sat/vB) the transaction was accepted at: its own, or the one of the transactions of the package it was accepted along with
+    EffectiveFeeRate float64
+    Err error
+}
+
+func (r PackageTxResult) String() string {
+    if r.Err != nil {
+        return fmt.Sprintf("%s: rejected, %v", r.Txid, r.Err)
+    }
+    if !r.Accepted {
+        return fmt.Sprintf("%s: not accepted, fee %d, vsize %d", r.Txid, r.Fee, r.VSize)
+    }
+    return fmt.Sprintf("%s: accepted, fee %d, vsize %d, effective fee rate %.2f sat/vB", r.Txid, r.Fee, r.VSize, r.EffectiveFeeRate)
+}
+
+// PackageResult is the outcome of the validation of a package. The package is accepted only if all of its transactions are.
+type PackageResult struct {
+    Txns []PackageTxResult
+    Fee int
+    VSize int
+    // FeeRate is the fee rate (sat/vB) of the whole package
+    FeeRate float64
+    Accepted bool
+    Err error
+}
+
+func (r PackageResult) String() string {
+    lines := make([]string, 0, len(r.Txns)+1)
+    if r.Accepted {
+        lines = append(lines, fmt.Sprintf("package accepted: %d txns, fee %d, vsize %d, %.2f sat/vB", len(r.Txns), r.Fee, r.VSize, r.FeeRate))
+    } else {
+        lines = append(lines, fmt.Sprintf("package rejected: %v", r.Err))
+    }
+    for _, t := range r.Txns {
+        lines = append(lines, "  "+t.String())
+    }
+    return strings.Join(lines, "\n")
+}
+
+// TestPackageAccept validates the transactions together, as a package of related transactions, without adding them anywhere.
+// The transactions have to be sorted, parents before their children. The prevouts of the inputs spending outputs of the package are taken from the package, the other ones are resolved in view (the prevouts given along with the transactions are trusted if view is nil). The prevouts are resolved on copies, txns are not modified.
+// Every transaction must be valid, and is accepted if its fee rate is at least minFeeRate (sat/vB), or if the fee rate of the transaction along with its package ancestors not yet accepted is, so a child can pay for its parents.
+func TestPackageAccept(txns []*txn.Transaction, view utxo.UTXOView, minFeeRate float64) PackageResult {
+    r := PackageResult{}
+    if err := checkPackage(txns); err != nil {
+        r.Err = err
+        return r
+    }
+    copies := make([]*txn.Transaction, len(txns))
+    for i, t := range txns {
+        c := t.ShallowCopy()
+        copies[i] = &c
+    }
+    g := NewDependencyGraph(copies)
+    for _, n := range g.Nodes {
+        for _, p := range n.Parents {
+            if p.Index > n.Index {
+                r.Err = fmt.Errorf("%w: %s spends %s", ErrPackageNotSorted, n.Txid, p.Txid)
+                return r
+            }
+        }
+    }
+
+    r.Txns = make([]PackageTxResult, len(g.Nodes))
+    failed := false
+    for i, n := range g.Nodes {
+        res := &r.Txns[i]
+        res.Txid, res.Wtxid = n.Txid, n.Wtxid
+        res.VSize = policy.GetVSize(n.Weight)
+        r.VSize += res.VSize
+        if n.Tx.IsCoinbase() {
+            res.Err = policy.ErrCoinbase
+        } else if err := resolvePackagePrevOuts(g, n, view); err != nil {
+            res.Err = err
+        } else {
+            // the fee can only be known once the prevouts are resolved
+            n.Fee = n.Tx.GetFees()
+            n.ModifiedFee = n.Fee
+            res.Fee = n.Fee
+            if err := validation.ValidateTransaction(*n.Tx); err != nil {
+                res.Err = err
+            }
+        }
+        failed = failed || res.Err != nil
+    }
+    if failed {
+        r.Err = ErrPackageTxFailed
+        return r
+    }
+    for _, t := range r.Txns {
+        r.Fee += t.Fee
+    }
+    r.FeeRate = feeRate(r.Fee, r.VSize)
+
+    accepted := make(map[*TxNode]bool, len(g.Nodes))
+    for _, n := range g.Nodes {
+        group := []*TxNode{n}
+        for _, a := range g.Ancestors(n) {
+            if !accepted[a] {
+                group = append(group, a)
+            }
+        }
+        fee, vsize := 0, 0
+        for _, m := range group {
+            fee += m.Fee
+            vsize += policy.GetVSize(m.Weight)
+        }
+        if float64(fee) < minFeeRate*float64(vsize) {
+            continue
+        }
+        for _, m := range group {
+            accepted[m] = true
+            r.Txns[m.Index].Accepted = true
+            r.Txns[m.Index].EffectiveFeeRate = feeRate(fee, vsize)
+        }
+    }
+    r.Accepted = len(accepted) == len(g.Nodes)
+    if !r.Accepted {
+        // report the first transaction left out, with its own fee rate as no descendant paid for it
+        var t PackageTxResult
+        for _, n := range g.Nodes {
+            if !accepted[n] {
+                t = r.Txns[n.Index]
+                break
+            }
+        }
+        r.Err = fmt.Errorf("%w: %s pays %.2f < %.2f sat/vB", ErrPackageFeeTooLow, t.Txid, feeRate(t.Fee, t.VSize), minFeeRate)
+    }
+    return r
+}
+
+// checkPackage checks the structure of the transactions (see validation.CheckTransaction), the size of the package, and that its transactions are distinct and do not spend the same outputs
+func checkPackage(txns []*txn.Transaction) error {
+    if len(txns) == 0 {
+        return ErrPackageEmpty
+    }
+    if len(txns) > MaxPackageCount {
+        return fmt.Errorf("%w: %d > %d", ErrPackageTooManyTxns, len(txns), MaxPackageCount)
+    }
+    weight := 0
+    txids := make(map[string]bool, len(txns))
+    spent := make(map[txn.OutPoint]bool)
+    for i, t := range txns {
+        // the structure is checked before the transaction is serialized, which panics on malformed hex fields
+        if err := validation.CheckTransaction(*t); err != nil {
+            return fmt.Errorf("%w: transaction %d: %v", ErrPackageTxFailed, i, err)
+        }
+        weight += t.GetWeight()
+        txid := t.Txid()
+        if txids[txid] {
+            return fmt.Errorf("%w: %s", ErrPackageDuplicate, txid)
+        }
+        txids[txid] = true
+        for _, in := range t.Vin {
+            if spent[in.OutPoint()] {
+                return fmt.Errorf("%w: %s", ErrPackageConflict, in.OutPoint())
+            }
+            spent[in.OutPoint()] = true
+        }
+    }
+    if weight > MaxPackageWeight {
+        return fmt.Errorf("%w: weight %d > %d", ErrPackageTooLarge, weight, MaxPackageWeight)
+    }
+    return nil
+}
+
+// resolvePackagePrevOuts sets the prevouts of the inputs of the node spending outputs of its parents in the package, and resolves the other ones in view
+func resolvePackagePrevOuts(g *DependencyGraph, n *TxNode, view utxo.UTXOView) error {
+    for i := range n.Tx.Vin {
+        in := &n.Tx.Vin[i]
+        if in.IsCoinbase {
+            continue
+        }
+        if parent, ok := g.Node(in.Txid); ok {
+            if in.Vout < 0 || in.Vout >= len(parent.Tx.Vout) {
+                return fmt.Errorf("input %d (%s): %w", i, in.OutPoint(), utxo.ErrNotFound)
+            }
+            in.PrevOut = parent.Tx.Vout[in.Vout]
+            continue
+        }
+        if view == nil {
+            continue
+        }
+        out, err := view.LookupUTXO(in.OutPoint())
+        if err != nil {
+            return fmt.Errorf("input %d (%s): %w", i, in.OutPoint(), err)
+        }
+        in.PrevOut = out
+    }
+    return nil
+}
@@ -0,0 +1,146 @@
+package txnpicker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/humblenginr/btc-miner/mining"
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utxo"
+)
+
+// cpfpPackage returns the cpfp chain of testdata/mempool, parents first. Alone, the parent pays 9.81 sat/vB, the middle transaction 15 and the child 16; along with the parent the middle transaction pays 12.89 sat/vB, and the three of them 13.93.
+func cpfpPackage(t *testing.T) []*txn.Transaction {
+    return []*txn.Transaction{readMempoolFile(t, cpfpParent), readMempoolFile(t, cpfpMiddle), readMempoolFile(t, cpfpChild)}
+}
+
+func TestPackageAccepted(t *testing.T) {
+    tests := []struct {
+        name string
+        minFeeRate float64
+        // effective fee rates of the transactions, rounded to the cent
+        want []float64
+    }{
+        {"each pays for itself", 5, []float64{9.81, 15, 16}},
+        {"middle pays for parent", 12, []float64{12.89, 12.89, 16}},
+        {"child pays for parents", 13.5, []float64{13.93, 13.93, 13.93}},
+    }
+    for _, view := range []utxo.UTXOView{nil, prevOutView(t)} {
+        for _, test := range tests {
+            r := TestPackageAccept(cpfpPackage(t), view, test.minFeeRate)
+            if !r.Accepted || r.Err != nil {
+                t.Fatalf("%s: package rejected: %v", test.name, r.Err)
+            }
+            if r.Fee != 7273 || r.VSize != 522 {
+                t.Errorf("%s: got fee %d vsize %d, want 7273 522", test.name, r.Fee, r.VSize)
+            }
+            for i, res := range r.Txns {
+                if !res.Accepted || fmt.Sprintf("%.2f", res.EffectiveFeeRate) != fmt.Sprintf("%.2f", test.want[i]) {
+                    t.Errorf("%s: tx %d accepted %v at %.2f sat/vB, want %.2f", test.name, i, res.Accepted, res.EffectiveFeeRate, test.want[i])
+                }
+            }
+        }
+    }
+}
+
+func TestPackageFeeTooLow(t *testing.T) {
+    txns := cpfpPackage(t)
+    r := TestPackageAccept(txns, nil, 14)
+    if r.Accepted || !errors.Is(r.Err, ErrPackageFeeTooLow) {
+        t.Fatalf("got accepted %v, err %v, want %v", r.Accepted, r.Err, ErrPackageFeeTooLow)
+    }
+    // the parent is reported with its own fee rate, not the one of the package
+    if !strings.Contains(r.Err.Error(), txns[0].Txid()+" pays 9.81") {
+        t.Errorf("got %v, want the fee rate of the parent", r.Err)
+    }
+    for i, res := range r.Txns {
+        if res.Accepted {
+            t.Errorf("tx %d accepted", i)
+        }
+    }
+}
+
+func TestPackageRejected(t *testing.T) {
+    cb := mining.NewCoinbaseTransaction(0, nil)
+    coinbase := &cb
+    tests := []struct {
+        name string
+        txns func([]*txn.Transaction) []*txn.Transaction
+        view utxo.UTXOView
+        want error
+        // index of the transaction failing validation, if any
+        failed int
+        txErr error
+    }{
+        {"empty", func(p []*txn.Transaction) []*txn.Transaction { return nil }, nil, ErrPackageEmpty, -1, nil},
+        {"duplicate", func(p []*txn.Transaction) []*txn.Transaction { return append(p, p[0]) }, nil, ErrPackageDuplicate, -1, nil},
+        {"not sorted", func(p []*txn.Transaction) []*txn.Transaction { return []*txn.Transaction{p[1], p[0], p[2]} }, nil, ErrPackageNotSorted, -1, nil},
+        {"conflict", func(p []*txn.Transaction) []*txn.Transaction {
+            c := p[1].ShallowCopy()
+            c.Locktime++
+            return append(p, &c)
+        }, nil, ErrPackageConflict, -1, nil},
+        {"coinbase", func(p []*txn.Transaction) []*txn.Transaction { return append(p, coinbase) }, nil, ErrPackageTxFailed, 3, policy.ErrCoinbase},
+        {"missing prevout", func(p []*txn.Transaction) []*txn.Transaction { return p }, utxo.NewMemoryView(nil), ErrPackageTxFailed, 0, utxo.ErrNotFound},
+        // hashing the transaction would panic
+        {"malformed scriptsig", func(p []*txn.Transaction) []*txn.Transaction {
+            p[1].Vin[0].ScriptSig = "zz"
+            return p
+        }, nil, ErrPackageTxFailed, -1, nil},
+        {"malformed scriptpubkey", func(p []*txn.Transaction) []*txn.Transaction {
+            p[2].Vout[0].ScriptPubKey = "zz"
+            return p
+        }, nil, ErrPackageTxFailed, -1, nil},
+        {"malformed witness", func(p []*txn.Transaction) []*txn.Transaction {
+            p[0].Vin[0].Witness = []string{"zz"}
+            return p
+        }, nil, ErrPackageTxFailed, -1, nil},
+        {"single malformed transaction", func(p []*txn.Transaction) []*txn.Transaction {
+            p[0].Vin[0].ScriptSig = "zz"
+            return p[:1]
+        }, nil, ErrPackageTxFailed, -1, nil},
+    }
+    for _, test := range tests {
+        r := TestPackageAccept(test.txns(cpfpPackage(t)), test.view, 1)
+        if r.Accepted || !errors.Is(r.Err, test.want) {
+            t.Errorf("%s: got accepted %v, err %v, want %v", test.name, r.Accepted, r.Err, test.want)
+            continue
+        }
+        if test.failed < 0 {
+            continue
+        }
+        res := r.Txns[test.failed]
+        if !errors.Is(res.Err, test.txErr) {
+            t.Errorf("%s: got tx err %v, want %v", test.name, res.Err, test.txErr)
+        }
+        // the fee of a transaction whose prevouts are not resolved is unknown
+        if res.Fee != 0 || r.Fee != 0 {
+            t.Errorf("%s: got fee %d, package fee %d, want 0", test.name, res.Fee, r.Fee)
+        }
+    }
+}
+
+func TestPackageMalformedTransaction(t *testing.T) {
+    txns := cpfpPackage(t)
+    txns[1].Vout[0].ScriptPubKey = "zz"
+    r := TestPackageAccept(txns, nil, 1)
+    if !errors.Is(r.Err, ErrPackageTxFailed) || !strings.Contains(r.Err.Error(), "transaction 1:") || len(r.Txns) != 0 {
+        t.Errorf("got err %v and %d transaction results, want transaction 1 to fail", r.Err, len(r.Txns))
+    }
+}
+
+func TestPackageKeepsTransactions(t *testing.T) {
+    txns := cpfpPackage(t)
+    // the prevout of the middle transaction is taken from the parent in the package
+    txns[1].Vin[0].PrevOut = txn.Vout{}
+    r := TestPackageAccept(txns, nil, 1)
+    if !r.Accepted {
+        t.Fatalf("package rejected: %v", r.Err)
+    }
+    if txns[1].Vin[0].PrevOut != (txn.Vout{}) {
+        t.Errorf("prevout of the caller's transaction set to %v", txns[1].Vin[0].PrevOut)
+    }
+}
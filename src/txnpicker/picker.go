@@ -1,42 +1,183 @@
 package txnpicker
 
 import (
+	"fmt"
+
+	"github.com/humblenginr/btc-miner/source"
 	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/txpool"
+	"github.com/humblenginr/btc-miner/utxo"
 )
 
+// CoinbaseReservedSigOpCost is the sigop cost left for the coinbase transaction, the same amount bitcoin core reserves
+var CoinbaseReservedSigOpCost = 400
+
 type TransactionsPicker struct {
+    // MempoolDirPath is the folder of JSON files read when Source is not set
     MempoolDirPath string
-    MaxTxWeight int
-    MaxTotalWeight int
-    MaxFees int
+    // Source, if set, provides the mempool transactions instead of the mempool folder
+    Source source.MempoolSource
+    // Constraints are the limits the picked transactions respect
+    Constraints Constraints
+    // UTXOSet, if set, is used to resolve the prevouts of the transactions instead of trusting the prevouts given in the mempool files
+    UTXOSet utxo.UTXOView
+    // ConflictRule decides which transactions are kept when mempool transactions spend the same outputs
+    ConflictRule ConflictRule
+    // Pool, if set, is used as the mempool instead of the mempool folder. The picker follows the changes made to it: the dependency graph is built again from all the pool transactions whenever the pool changed since the last pick, it is not updated incrementally.
+    Pool *txpool.Mempool
+    // Streaming loads the mempool one transaction at a time, keeping only a summary of each transaction in memory and rejecting the ones that cannot be read as RejectMalformed. The source has to be a source.StreamSource (the mempool folder is), the picker panics otherwise.
+    // The picked transactions are read again from the source, so it must not change while picking.
+    Streaming bool
+
+    // valid transactions of the mempool, loaded on first use
+    graph *DependencyGraph
+    conflicts []ConflictSet
+    entries []LoadedEntry
+    locations map[string]source.Location
+    outputs *utxo.MemoryView
+    poolVersion int
 }
 
-func NewTransactionPicker(mempoolDirPath string, maxTxWeight int, maxTotalWeight int, maxFees int) TransactionsPicker {
-    return TransactionsPicker{MempoolDirPath: mempoolDirPath, MaxTxWeight: maxTxWeight, MaxTotalWeight: maxTotalWeight, MaxFees: maxFees}
+func NewTransactionPicker(mempoolDirPath string, constraints Constraints) TransactionsPicker {
+    return TransactionsPicker{MempoolDirPath: mempoolDirPath, Constraints: constraints}
 }
 
 
 
-// PickTransactionsUsingPQ picks valid transactions from the mempool using priority queue. Transaction with higher fee/weight ratio is considered to be high priority. 
-func (tp *TransactionsPicker) PickUsingPQ() []*txn.Transaction {
-    q := getTxnsQ(tp.MempoolDirPath)
-    txns := make([]*txn.Transaction, 0)
-    totalWeight := 0
-    totalFee := 0
-
-    item := q.Pop(); 
-    for item != nil {
-        tx := txn.Transaction(item.(Item))
-        weight := tx.GetWeight()
-        fee := tx.GetFees()
-        if((weight+ totalWeight < tp.MaxTotalWeight) && (fee + totalFee < tp.MaxFees)) {
-            txns = append(txns, &tx)
-            totalWeight += weight
-            totalFee += fee
-
-        }
-        item = q.Pop()
+// Graph returns the dependency graph of the valid mempool transactions, loading them if they are not loaded yet
+func (tp *TransactionsPicker) Graph() *DependencyGraph {
+    if tp.Pool != nil {
+        // transactions in the pool are already validated and do not conflict
+        if tp.graph == nil || tp.poolVersion != tp.Pool.Version() {
+            summaries := summarizeAll(tp.Pool.Transactions())
+            for _, s := range summaries {
+                e, _ := tp.Pool.Lookup(s.Txid)
+                s.time = e.Time.Unix()
+            }
+            tp.graph = newGraph(summaries)
+            tp.conflicts = nil
+            tp.entries = nil
+            tp.poolVersion = tp.Pool.Version()
+        }
+        return tp.graph
+    }
+    if tp.graph == nil {
+        src := tp.mempoolSource()
+        var loaded *loadedMempool
+        if tp.Streaming {
+            stream, ok := src.(source.StreamSource)
+            if !ok {
+                panic(fmt.Errorf("%T cannot be streamed", src))
+            }
+            loaded = streamValidTransactions(stream, tp.UTXOSet, tp.ConflictRule)
+        } else {
+            loaded = loadValidTransactions(src, tp.UTXOSet, tp.ConflictRule)
+        }
+        tp.graph = newGraph(loaded.txns)
+        for txid, delta := range loaded.feeDeltas {
+            tp.graph.PrioritiseTransaction(txid, delta)
+        }
+        tp.conflicts = loaded.conflicts
+        tp.entries = loaded.entries
+        tp.locations = loaded.locations
+        tp.outputs = loaded.outputs
+    }
+    return tp.graph
+}
+
+// transactions returns the transactions of the nodes, in order. The transactions loaded by streaming are read again from the source, their prevouts resolved again in the outputs kept by the loader if UTXOSet is set.
+// It panics if a transaction cannot be read again or changed since the mempool was loaded, as the block would not hold the picked transactions.
+func (tp *TransactionsPicker) transactions(nodes []*TxNode) []*txn.Transaction {
+    txns := make([]*txn.Transaction, 0, len(nodes))
+    for _, n := range nodes {
+        if n.Tx != nil {
+            txns = append(txns, n.Tx)
+            continue
+        }
+        stream := tp.mempoolSource().(source.StreamSource)
+        loc := tp.locations[n.Txid]
+        t, err := stream.Reload(loc)
+        if err != nil {
+            panic(fmt.Errorf("%s: %w", loc.Name, err))
+        }
+        if t.Txid() != n.Txid {
+            panic(fmt.Errorf("%s: %s changed since the mempool was loaded", loc.Name, n.Txid))
+        }
+        if tp.UTXOSet != nil {
+            if err := utxo.ResolvePrevOuts(t, tp.outputs); err != nil {
+                panic(fmt.Errorf("%s: %w", loc.Name, err))
+            }
+        }
+        txns = append(txns, t)
     }
     return txns
 }
 
+// mempoolSource returns Source, or the mempool folder if it is not set
+func (tp *TransactionsPicker) mempoolSource() source.MempoolSource {
+    if tp.Source == nil {
+        return source.DirSource{Path: tp.MempoolDirPath}
+    }
+    return tp.Source
+}
+
+// Entries returns every transaction of the mempool source along with why it was left out, if it was. With a Pool, the transactions of the pool are returned, all of them valid.
+func (tp *TransactionsPicker) Entries() []LoadedEntry {
+    g := tp.Graph()
+    if tp.entries == nil {
+        entries := make([]LoadedEntry, 0, len(g.Nodes))
+        for _, n := range g.Nodes {
+            entries = append(entries, LoadedEntry{Name: n.Txid, Txid: n.Txid, Fee: n.Fee, Weight: n.Weight, Inputs: n.summary.Inputs})
+        }
+        return entries
+    }
+    return tp.entries
+}
+
+// Conflicts returns the sets of mempool transactions spending the same outputs, and how each of them was resolved
+func (tp *TransactionsPicker) Conflicts() []ConflictSet {
+    tp.Graph()
+    return tp.conflicts
+}
+
+// PickTransactionsUsingPQ picks valid transactions from the mempool using priority queue. Transaction with higher fee/weight ratio is considered to be high priority, the fee including the fee delta of the transaction. Transactions with the same ratio are picked in the order of their wtxids, so the same mempool always gives the same block.
+// A transaction is picked only if it respects the constraints along with the transactions picked before it.
+// A transaction is added to the queue only after all of its in-mempool parents are picked, so the transactions are returned in topological order, and the descendants of a transaction that was not picked are never picked.
+func (tp *TransactionsPicker) PickUsingPQ() []*txn.Transaction {
+    return tp.pickInDependencyOrder(higherPriority)
+}
+
+// pickInDependencyOrder picks transactions in the order given by higher, among the transactions whose in-mempool parents are all picked
+func (tp *TransactionsPicker) pickInDependencyOrder(higher func(a, b *TxNode) bool) []*txn.Transaction {
+    return tp.transactions(tp.pickNodesInDependencyOrder(higher))
+}
+
+// pickNodesInDependencyOrder is pickInDependencyOrder returning the picked nodes
+func (tp *TransactionsPicker) pickNodesInDependencyOrder(higher func(a, b *TxNode) bool) []*TxNode {
+    g := tp.Graph()
+    q := newPriorityQueue(higher)
+    // number of parents of each transaction that are yet to be picked
+    pendingParents := make(map[*TxNode]int, len(g.Nodes))
+    for _, n := range g.Nodes {
+        pendingParents[n] = len(n.Parents)
+        if len(n.Parents) == 0 {
+            q.push(n)
+        }
+    }
+    picked := make([]*TxNode, 0)
+    total := totals{}
+
+    for n, ok := q.pop(); ok; n, ok = q.pop() {
+        if tp.Constraints.fits(total, nodeTotals(n)) {
+            picked = append(picked, n)
+            total.add(nodeTotals(n))
+            for _, child := range n.Children {
+                pendingParents[child]--
+                if pendingParents[child] == 0 {
+                    q.push(child)
+                }
+            }
+        }
+    }
+    return picked
+}
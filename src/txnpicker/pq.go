@@ -1,54 +1,257 @@
 package txnpicker
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
+	"errors"
+	"log"
+	"strings"
 
+	"github.com/humblenginr/btc-miner/source"
 	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utxo"
 	"github.com/humblenginr/btc-miner/validation"
-	"github.com/x1m3/priorityQueue"
 )
 
-var (
-    MempoolDirPath = "../mempool"
+// reasons a mempool transaction is rejected when loading the mempool
+const (
+    RejectInvalid = "invalid"
+    RejectCoinbase = "coinbase"
+    RejectMissingInput = "missing input"
+    RejectConflict = "conflict"
+    RejectOrphan = "orphan"
+    // the transaction breaks the TRUC (version 3) rules
+    RejectTRUC = "truc"
+    RejectEphemeralDust = "ephemeral dust"
+    // the transaction could not be read from the source, or has hex fields that cannot be decoded. Only used by the streaming loader.
+    RejectMalformed = "malformed"
 )
 
-type Item txn.Transaction
+// LoadedEntry is a transaction read from the mempool source, along with why it was left out of the mempool if it was
+type LoadedEntry struct {
+    Name string
+    // Txid is not set for the transactions failing CheckTransaction, as it cannot be computed for all of them, nor for RejectMalformed entries. Fee, Weight and Inputs are only set along with it.
+    Txid string
+    // Fee is computed from the prevouts of the transaction, resolved or not
+    Fee int
+    Weight int
+    // Inputs are the outputs spent by the transaction, in the order of its inputs
+    Inputs []txn.OutPoint
+    // Rejection is one of the Reject reasons, empty if the transaction is valid
+    Rejection string
+    // Detail tells more about the rejection, the validation error for instance
+    Detail string
+}
+
+// loadedMempool is what loadValidTransactions found in the mempool source
+type loadedMempool struct {
+    txns []*txSummary
+    conflicts []ConflictSet
+    // fee deltas given by the source, by txid
+    feeDeltas map[string]int
+    // every transaction of the source, in order
+    entries []LoadedEntry
+    // where the valid transactions were found, by txid, and the outputs of the mempool transactions spent by other ones on top of the utxo set. Only set by streamValidTransactions.
+    locations map[string]source.Location
+    outputs *utxo.MemoryView
+
+    // transactions passing the checks of checkEntry, and txids of the ones failing them
+    valid []*txSummary
+    invalid map[string]bool
+}
 
-func (i Item) HigherPriorityThan(other priorityQueue.Interface) bool {
-	return i.Priority > other.(Item).Priority
+func newLoadedMempool() *loadedMempool {
+    return &loadedMempool{feeDeltas: make(map[string]int), entries: make([]LoadedEntry, 0), invalid: make(map[string]bool)}
 }
 
-func validateHelper(tx txn.Transaction) bool {
-    for inputIdx := range tx.Vin {
-        if(!validation.Validate(tx, inputIdx)){
-            return false
+// loadValidTransactions returns the valid transactions of the mempool, in the order they are given by src.
+// If utxoSet is not nil, the prevouts of the transactions are resolved using it (and the outputs of the other mempool transactions), and transactions spending outputs it does not have are left out. Mempool transactions spending the same output are not rejected as spending a spent output, they are conflicts.
+// Transactions spending the same outputs are resolved using conflictRule, the resolved conflict sets are returned along with the transactions.
+// Transactions breaking the TRUC or ephemeral dust policy are left out (see applyTRUCPolicy), and so are transactions spending outputs of invalid or evicted mempool transactions.
+// Every transaction of the source is returned as an entry, with the reason it was left out if it was.
+func loadValidTransactions(src source.MempoolSource, utxoSet utxo.UTXOView, conflictRule ConflictRule) *loadedMempool {
+    sourceEntries, err := src.Load()
+    if err != nil {
+        panic(err)
+    }
+    loaded := newLoadedMempool()
+    txns := make([]txn.Transaction, 0, len(sourceEntries))
+    for _, e := range sourceEntries {
+        txns = append(txns, *e.Tx)
+    }
+
+    var view *utxo.MemoryView
+    if utxoSet != nil {
+        // outputs of the mempool transactions can be spent by their children
+        view = utxo.NewMemoryView(utxoSet)
+        for i := range txns {
+            // the txid of a malformed transaction cannot be computed, checkEntry rejects it
+            if validation.CheckTransaction(txns[i]) == nil {
+                view.AddTxOutputs(&txns[i])
+            }
+        }
+    }
+
+    for i := range txns {
+        entry, summary := checkEntry(sourceEntries[i].Name, &txns[i], sourceEntries[i].PrevOutErr, view)
+        if summary != nil {
+            summary.time = sourceEntries[i].Time
         }
-    } 
-    return true
+        loaded.add(entry, summary, sourceEntries[i].FeeDelta)
+    }
+    loaded.resolve(conflictRule)
+    return loaded
 }
 
-// GetTxnsQ returns a priority queue of valid transactions. It uses the mempoolDirPath as the folder to look for transactions. 
-func getTxnsQ(mempoolDirPath string) *priorityQueue.Queue {
-    pq := priorityQueue.New()
-    files, err := os.ReadDir(MempoolDirPath)
+// streamValidTransactions does what loadValidTransactions does, going through the transactions of src one at a time. Only the summaries of the transactions are kept, along with where the valid ones were found so that they can be read again once picked.
+// The transactions that cannot be read or have malformed hex fields are logged and kept as RejectMalformed entries. With a utxo set, the source is read three times, as children can come before their parents: see spentMempoolOutputs.
+func streamValidTransactions(src source.StreamSource, utxoSet utxo.UTXOView, conflictRule ConflictRule) *loadedMempool {
+    loaded := newLoadedMempool()
+    loaded.locations = make(map[string]source.Location)
+    if utxoSet != nil {
+        loaded.outputs = spentMempoolOutputs(src, utxoSet)
+    }
+
+    skip := func(name string, err error) {
+        log.Printf("skipping %s: %v", name, err)
+        loaded.entries = append(loaded.entries, LoadedEntry{Name: name, Rejection: RejectMalformed, Detail: err.Error()})
+    }
+    err := src.Stream(func(e source.Entry, loc source.Location) {
+        // the txid of a transaction with malformed hex fields cannot be computed
+        if err := validation.CheckTransaction(*e.Tx); isMalformed(err) {
+            skip(e.Name, err)
+            return
+        }
+        entry, summary := checkEntry(e.Name, e.Tx, e.PrevOutErr, loaded.outputs)
+        if summary != nil {
+            summary.tx = nil
+            summary.time = e.Time
+            if entry.Rejection == "" {
+                loaded.locations[entry.Txid] = loc
+            }
+        }
+        loaded.add(entry, summary, e.FeeDelta)
+    }, skip)
     if err != nil {
         panic(err)
     }
-    for _, f := range files {
-        var transaction txn.Transaction
-        txnPath := fmt.Sprintf("%s/%s", MempoolDirPath, f.Name())
-        byteResult, _ := os.ReadFile(txnPath)
-        err = json.Unmarshal(byteResult, &transaction)
+    loaded.resolve(conflictRule)
+    return loaded
+}
+
+// spentMempoolOutputs returns a view of utxoSet along with the outputs of the mempool transactions of src that other mempool transactions spend. The outputs nobody spends in the mempool are left out, so that the view does not hold every output of the mempool: src is read once to collect the outputs spent by the mempool transactions, and once more to find the mempool outputs among them.
+// The outputs are kept without their asm and address, the type is kept as validating an input depends on it.
+func spentMempoolOutputs(src source.StreamSource, utxoSet utxo.UTXOView) *utxo.MemoryView {
+    stream := func(fn func(tx *txn.Transaction)) {
+        err := src.Stream(func(e source.Entry, loc source.Location) {
+            // the malformed transactions are skipped when validating the transactions
+            if validation.CheckTransaction(*e.Tx) == nil {
+                fn(e.Tx)
+            }
+        }, func(name string, err error) {})
         if err != nil {
             panic(err)
         }
-        isValid := validateHelper(transaction)
-        if(isValid && !transaction.Vin[0].IsCoinbase){
-            transaction.UpdatePriority()
-            pq.Push(Item(transaction))
+    }
+    spent := make(map[txn.OutPoint]bool)
+    stream(func(tx *txn.Transaction) {
+        for _, in := range tx.Vin {
+            spent[in.OutPoint()] = true
+        }
+    })
+    view := utxo.NewMemoryView(utxoSet)
+    stream(func(tx *txn.Transaction) {
+        txid := tx.Txid()
+        for i, out := range tx.Vout {
+            if op := txn.NewOutPoint(txid, i); spent[op] {
+                view.AddUTXO(op, txn.Vout{ScriptPubKey: out.ScriptPubKey, ScriptPubKeyType: out.ScriptPubKeyType, Value: out.Value})
+            }
+        }
+    })
+    return view
+}
+
+// isMalformed reports whether err is the error of CheckTransaction for a transaction with hex fields that cannot be decoded
+func isMalformed(err error) bool {
+    var ruleErr validation.RuleError
+    return errors.As(err, &ruleErr) && ruleErr.ErrorCode == validation.ErrMalformedTx
+}
+
+// checkEntry resolves the prevouts of the transaction in view, if not nil, and validates it. The structure of the transaction is checked first, the Txid of the entry is not set and no summary is returned if that fails.
+func checkEntry(name string, transaction *txn.Transaction, prevOutErr error, view *utxo.MemoryView) (LoadedEntry, *txSummary) {
+    entry := LoadedEntry{Name: name}
+    // hashing a transaction with malformed hex fields would panic
+    if err := validation.CheckTransaction(*transaction); err != nil {
+        entry.Rejection, entry.Detail = RejectInvalid, err.Error()
+        return entry, nil
+    }
+    if view != nil {
+        if err := utxo.ResolvePrevOuts(transaction, view); err != nil {
+            entry.Rejection, entry.Detail = RejectMissingInput, err.Error()
+        }
+    } else if prevOutErr != nil {
+        // the source could not resolve the prevouts it does not provide
+        entry.Rejection, entry.Detail = RejectMissingInput, prevOutErr.Error()
+    }
+    if entry.Rejection == "" {
+        if err := validation.ValidateTransaction(*transaction); err != nil {
+            entry.Rejection, entry.Detail = RejectInvalid, err.Error()
+        } else if transaction.Vin[0].IsCoinbase {
+            entry.Rejection = RejectCoinbase
+        }
+    }
+    summary := summarize(transaction)
+    entry.Txid, entry.Fee, entry.Weight, entry.Inputs = summary.Txid, summary.fee, summary.weight, summary.Inputs
+    return entry, summary
+}
+
+// add records the entry checked by checkEntry along with the summary of its transaction. Entries without a summary (malformed transactions) are only kept by name, in the entries.
+func (loaded *loadedMempool) add(entry LoadedEntry, summary *txSummary, feeDelta int) {
+    loaded.entries = append(loaded.entries, entry)
+    if summary == nil {
+        return
+    }
+    if feeDelta != 0 {
+        loaded.feeDeltas[entry.Txid] += feeDelta
+    }
+    if entry.Rejection == "" {
+        loaded.valid = append(loaded.valid, summary)
+    } else {
+        loaded.invalid[entry.Txid] = true
+    }
+}
+
+// resolve leaves out the valid transactions conflicting with each other, breaking the TRUC policy or spending outputs of the transactions left out, and gives the reason to their entries
+func (loaded *loadedMempool) resolve(conflictRule ConflictRule) {
+    invalid := loaded.invalid
+    valid, conflicts := resolveConflicts(loaded.valid, conflictRule)
+    loaded.conflicts = conflicts
+    evictedBy := make(map[string]string)
+    for _, c := range loaded.conflicts {
+        for _, txid := range c.Evicted {
+            invalid[txid] = true
+            evictedBy[txid] = strings.Join(c.Kept, ", ")
+        }
+    }
+    valid, policyRejected := applyTRUCPolicy(excludeOrphans(valid, invalid))
+    for txid := range policyRejected {
+        invalid[txid] = true
+    }
+    loaded.txns = excludeOrphans(valid, invalid)
+
+    kept := make(map[string]bool, len(loaded.txns))
+    for _, t := range loaded.txns {
+        kept[t.Txid] = true
+    }
+    for i := range loaded.entries {
+        e := &loaded.entries[i]
+        if e.Rejection != "" || kept[e.Txid] {
+            continue
+        }
+        if by, ok := evictedBy[e.Txid]; ok {
+            e.Rejection, e.Detail = RejectConflict, "conflicts with "+by
+        } else if r, ok := policyRejected[e.Txid]; ok {
+            e.Rejection, e.Detail = r.reason, r.detail
+        } else {
+            e.Rejection, e.Detail = RejectOrphan, "spends an output of a rejected transaction"
         }
     }
-    return pq
 }
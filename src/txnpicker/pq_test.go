@@ -0,0 +1,301 @@
+package txnpicker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/humblenginr/btc-miner/source"
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utxo"
+)
+
+// files of testdata/mempool: a chain of three transactions where the last child pays for its parents, another chain of three, and three transactions without mempool parents
+const (
+    cpfpParent = "91a58688c0cf2a504866a07e463bf3034a811a4b7201c7d309a26ad8ac382889"
+    cpfpMiddle = "0404b9545838693dac96a8e16916cb7fc5bbbc44b9c8fa42ee34a41648b735a3"
+    cpfpChild = "d7361a396244b0e4706a349edc687b57e2cb3d731a375cc733c07f14f016b3c3"
+    chainParent = "863ee792c7701947d750e9872ee43a56e04b0c0cb7ea5f5be2dd473dd74bcf36"
+    chainMiddle = "08ab640471b2f5c2e6be72ab8c6792136ec150ebc711dcb3c00ff07462d86c8f"
+    chainChild = "19a3d2d6aa503a78831a96d7d0158b69d44743f22ca1a9d22baf470cb8adaae1"
+    testdataMempoolSize = 9
+)
+
+func readMempoolFile(t *testing.T, name string) *txn.Transaction {
+    b, err := os.ReadFile(filepath.Join("testdata", "mempool", name+".json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var tx txn.Transaction
+    if err := json.Unmarshal(b, &tx); err != nil {
+        t.Fatal(err)
+    }
+    return &tx
+}
+
+// copyMempool copies testdata/mempool to a temporary folder, with the given files added or replaced
+func copyMempool(t *testing.T, files map[string]string) string {
+    dir := t.TempDir()
+    entries, err := os.ReadDir(filepath.Join("testdata", "mempool"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, e := range entries {
+        b, err := os.ReadFile(filepath.Join("testdata", "mempool", e.Name()))
+        if err != nil {
+            t.Fatal(err)
+        }
+        os.WriteFile(filepath.Join(dir, e.Name()), b, 0644)
+    }
+    for name, content := range files {
+        os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+    }
+    return dir
+}
+
+func marshalTx(t *testing.T, tx *txn.Transaction) string {
+    b, err := json.Marshal(tx)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(b)
+}
+
+// prevOutView returns a utxo set with the prevouts embedded in the testdata transactions that are not spending mempool outputs
+func prevOutView(t *testing.T) *utxo.MemoryView {
+    entries, err := source.DirSource{Path: filepath.Join("testdata", "mempool")}.Load()
+    if err != nil {
+        t.Fatal(err)
+    }
+    mempool := make(map[string]bool)
+    for _, e := range entries {
+        mempool[e.Tx.Txid()] = true
+    }
+    view := utxo.NewMemoryView(nil)
+    for _, e := range entries {
+        for _, in := range e.Tx.Vin {
+            if !mempool[in.Txid] {
+                view.AddUTXO(in.OutPoint(), in.PrevOut)
+            }
+        }
+    }
+    return view
+}
+
+func entryByName(entries []LoadedEntry, name string) (LoadedEntry, bool) {
+    for _, e := range entries {
+        if e.Name == name {
+            return e, true
+        }
+    }
+    return LoadedEntry{}, false
+}
+
+func TestLoadValidTransactions(t *testing.T) {
+    for _, view := range []utxo.UTXOView{nil, prevOutView(t)} {
+        loaded := loadValidTransactions(source.DirSource{Path: filepath.Join("testdata", "mempool")}, view, ConflictRuleFeeRate)
+        if len(loaded.txns) != testdataMempoolSize || len(loaded.entries) != testdataMempoolSize {
+            t.Fatalf("loaded %d valid transactions out of %d", len(loaded.txns), len(loaded.entries))
+        }
+        for _, e := range loaded.entries {
+            if e.Rejection != "" {
+                t.Errorf("%s rejected: %s %s", e.Name, e.Rejection, e.Detail)
+            }
+        }
+    }
+}
+
+func TestLoadMalformedTransaction(t *testing.T) {
+    // valid JSON, but a scriptpubkey that is not hex: the txid cannot be computed
+    bad := readMempoolFile(t, chainChild)
+    bad.Vout[0].ScriptPubKey = "zz"
+    badSig := readMempoolFile(t, cpfpChild)
+    badSig.Vin[0].ScriptSig = "0"
+    dir := copyMempool(t, map[string]string{"bad.json": marshalTx(t, bad), "badsig.json": marshalTx(t, badSig)})
+
+    for _, view := range []utxo.UTXOView{nil, prevOutView(t)} {
+        loaded := loadValidTransactions(source.DirSource{Path: dir}, view, ConflictRuleFeeRate)
+        if len(loaded.txns) != testdataMempoolSize {
+            t.Errorf("loaded %d valid transactions, want %d", len(loaded.txns), testdataMempoolSize)
+        }
+        for _, name := range []string{"bad.json", "badsig.json"} {
+            e, ok := entryByName(loaded.entries, name)
+            if !ok || e.Rejection != RejectInvalid || e.Txid != "" {
+                t.Errorf("%s: entry %+v, want rejected as invalid without a txid", name, e)
+            }
+        }
+    }
+}
+
+func TestLoadRejectsChildrenOfInvalid(t *testing.T) {
+    parent := readMempoolFile(t, chainParent)
+    // the prevout is not part of the txid, so the children still spend it
+    parent.Vin[0].PrevOut.Value = 0
+    dir := copyMempool(t, map[string]string{chainParent + ".json": marshalTx(t, parent)})
+    loaded := loadValidTransactions(source.DirSource{Path: dir}, nil, ConflictRuleFeeRate)
+    want := map[string]string{chainParent + ".json": RejectInvalid, chainMiddle + ".json": RejectOrphan, chainChild + ".json": RejectOrphan}
+    for _, e := range loaded.entries {
+        if e.Rejection != want[e.Name] {
+            t.Errorf("%s: rejection %q, want %q", e.Name, e.Rejection, want[e.Name])
+        }
+    }
+}
+
+func TestPickerPicksInDependencyOrder(t *testing.T) {
+    tp := NewTransactionPicker(filepath.Join("testdata", "mempool"), Constraints{})
+    txns := tp.PickUsingPQ()
+    if len(txns) != testdataMempoolSize || !IsTopologicallySorted(txns) {
+        t.Errorf("PickUsingPQ() = %v", txids(txns))
+    }
+    g := tp.Graph()
+    edges := 0
+    for _, n := range g.Nodes {
+        edges += len(n.Parents)
+    }
+    if edges != 4 {
+        t.Errorf("graph has %d edges, want 4", edges)
+    }
+}
+
+func TestLoadHexWithoutUTXOSet(t *testing.T) {
+    // the prevouts of the parent cannot be resolved without a utxo set, its descendants spend its outputs
+    var lines strings.Builder
+    for _, name := range []string{cpfpParent, cpfpMiddle, cpfpChild} {
+        fmt.Fprintf(&lines, "%x\n", readMempoolFile(t, name).RawHex())
+    }
+    path := filepath.Join(t.TempDir(), "mempool.hex")
+    if err := os.WriteFile(path, []byte(lines.String()), 0644); err != nil {
+        t.Fatal(err)
+    }
+    parent := readMempoolFile(t, cpfpParent)
+    loaded := loadValidTransactions(source.HexSource{Path: path}, nil, ConflictRuleFeeRate)
+    want := []string{RejectMissingInput, RejectOrphan, RejectOrphan}
+    if len(loaded.entries) != len(want) || len(loaded.txns) != 0 {
+        t.Fatalf("loaded %d entries, %d valid", len(loaded.entries), len(loaded.txns))
+    }
+    for i, e := range loaded.entries {
+        if e.Rejection != want[i] {
+            t.Errorf("%s: rejection %q, want %q", e.Name, e.Rejection, want[i])
+        }
+    }
+    if !strings.Contains(loaded.entries[0].Detail, parent.Vin[0].OutPoint().String()) {
+        t.Errorf("detail %q does not name the missing outpoint", loaded.entries[0].Detail)
+    }
+
+    // with a utxo set, all of them are valid
+    loaded = loadValidTransactions(source.HexSource{Path: path, UTXOSet: prevOutView(t)}, prevOutView(t), ConflictRuleFeeRate)
+    if len(loaded.txns) != 3 {
+        t.Errorf("loaded %d valid transactions with a utxo set, want 3", len(loaded.txns))
+    }
+}
+
+func TestStreamValidTransactions(t *testing.T) {
+    bad := readMempoolFile(t, chainChild)
+    bad.Vout[0].ScriptPubKey = "zz"
+    dir := copyMempool(t, map[string]string{"bad.json": marshalTx(t, bad), "unreadable.json": "{"})
+    want := NewTransactionPicker(filepath.Join("testdata", "mempool"), Constraints{})
+    wantTxns := want.PickUsingPQ()
+
+    for _, view := range []utxo.UTXOView{nil, prevOutView(t)} {
+        tp := NewTransactionPicker(dir, Constraints{})
+        tp.UTXOSet = view
+        tp.Streaming = true
+        txns := tp.PickUsingPQ()
+        if len(txns) != len(wantTxns) {
+            t.Fatalf("streaming picked %v, want %v", txids(txns), txids(wantTxns))
+        }
+        for i := range txns {
+            if txns[i].Txid() != wantTxns[i].Txid() {
+                t.Errorf("streaming picked %v, want %v", txids(txns), txids(wantTxns))
+                break
+            }
+            for j, in := range txns[i].Vin {
+                if in.PrevOut.Value != wantTxns[i].Vin[j].PrevOut.Value || in.PrevOut.ScriptPubKey != wantTxns[i].Vin[j].PrevOut.ScriptPubKey {
+                    t.Errorf("%s: input %d spends %+v, want %+v", txns[i].Txid(), j, in.PrevOut, wantTxns[i].Vin[j].PrevOut)
+                }
+            }
+        }
+        for _, n := range tp.Graph().Nodes {
+            if n.Tx != nil {
+                t.Errorf("%s: transaction kept in memory", n.Txid)
+            }
+        }
+        for _, name := range []string{"bad.json", "unreadable.json"} {
+            e, ok := entryByName(tp.Entries(), name)
+            if !ok || e.Rejection != RejectMalformed || e.Txid != "" {
+                t.Errorf("%s: entry %+v, want rejected as malformed", name, e)
+            }
+        }
+    }
+}
+
+func TestSpentMempoolOutputs(t *testing.T) {
+    src := source.DirSource{Path: filepath.Join("testdata", "mempool")}
+    entries, err := src.Load()
+    if err != nil {
+        t.Fatal(err)
+    }
+    spent := make(map[txn.OutPoint]bool)
+    for _, e := range entries {
+        for _, in := range e.Tx.Vin {
+            spent[in.OutPoint()] = true
+        }
+    }
+    view := spentMempoolOutputs(src, utxo.NewMemoryView(nil))
+    kept := 0
+    for _, e := range entries {
+        for i, out := range e.Tx.Vout {
+            op := txn.NewOutPoint(e.Tx.Txid(), i)
+            got, err := view.LookupUTXO(op)
+            if err != nil {
+                if spent[op] {
+                    t.Errorf("%s: spent output left out", op)
+                }
+                continue
+            }
+            kept++
+            if !spent[op] {
+                t.Errorf("%s: unspent output kept", op)
+            }
+            if got.ScriptPubKey != out.ScriptPubKey || got.Value != out.Value || got.ScriptPubKeyType != out.ScriptPubKeyType || got.ScriptPubKeyAsm != "" {
+                t.Errorf("%s: kept %+v", op, got)
+            }
+        }
+    }
+    // the two chains of three transactions
+    if kept != 4 {
+        t.Errorf("kept %d outputs, want 4", kept)
+    }
+}
+
+func TestStreamReloadChanged(t *testing.T) {
+    dir := copyMempool(t, nil)
+    tp := NewTransactionPicker(dir, Constraints{})
+    tp.Streaming = true
+    tp.Graph()
+    // the file now holds another transaction
+    other := readMempoolFile(t, chainParent)
+    other.Locktime++
+    os.WriteFile(filepath.Join(dir, chainParent+".json"), []byte(marshalTx(t, other)), 0644)
+    defer func() {
+        if recover() == nil {
+            t.Errorf("picking a transaction that changed since loading did not panic")
+        }
+    }()
+    tp.PickUsingPQ()
+}
+
+func TestStreamUnsupportedSource(t *testing.T) {
+    tp := NewTransactionPicker("", Constraints{})
+    tp.Source = source.HexSource{Path: filepath.Join(t.TempDir(), "mempool.hex")}
+    tp.Streaming = true
+    defer func() {
+        if recover() == nil {
+            t.Errorf("streaming a hex source did not panic")
+        }
+    }()
+    tp.Graph()
+}
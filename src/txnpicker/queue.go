@@ -0,0 +1,47 @@
+package txnpicker
+
+import "container/heap"
+
+// priorityQueue pops the item of the highest priority first, as told by higher
+type priorityQueue[T any] struct {
+    h queueHeap[T]
+}
+
+func newPriorityQueue[T any](higher func(a, b T) bool) *priorityQueue[T] {
+    return &priorityQueue[T]{h: queueHeap[T]{higher: higher}}
+}
+
+func (q *priorityQueue[T]) push(item T) {
+    heap.Push(&q.h, item)
+}
+
+// pop removes and returns the item of the highest priority, false is returned if the queue is empty
+func (q *priorityQueue[T]) pop() (T, bool) {
+    if len(q.h.items) == 0 {
+        var zero T
+        return zero, false
+    }
+    return heap.Pop(&q.h).(T), true
+}
+
+// queueHeap implements heap.Interface, the item of the highest priority being the root
+type queueHeap[T any] struct {
+    items []T
+    higher func(a, b T) bool
+}
+
+func (h queueHeap[T]) Len() int { return len(h.items) }
+
+func (h queueHeap[T]) Less(i, j int) bool { return h.higher(h.items[i], h.items[j]) }
+
+func (h queueHeap[T]) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *queueHeap[T]) Push(x any) {
+    h.items = append(h.items, x.(T))
+}
+
+func (h *queueHeap[T]) Pop() any {
+    last := h.items[len(h.items)-1]
+    h.items = h.items[:len(h.items)-1]
+    return last
+}
@@ -0,0 +1,40 @@
+package txnpicker
+
+import "testing"
+
+func TestPriorityQueue(t *testing.T) {
+    q := newPriorityQueue(func(a, b int) bool { return a > b })
+    if _, ok := q.pop(); ok {
+        t.Fatal("pop() of an empty queue succeeded")
+    }
+    for _, v := range []int{3, 9, 1, 7, 5, 9} {
+        q.push(v)
+    }
+    want := []int{9, 9, 7}
+    for _, w := range want {
+        if v, ok := q.pop(); !ok || v != w {
+            t.Fatalf("pop() = %d, %v, want %d", v, ok, w)
+        }
+    }
+    // items pushed while popping are ordered with the remaining ones
+    q.push(6)
+    for _, w := range []int{6, 5, 3, 1} {
+        if v, ok := q.pop(); !ok || v != w {
+            t.Fatalf("pop() = %d, %v, want %d", v, ok, w)
+        }
+    }
+    if _, ok := q.pop(); ok {
+        t.Error("queue not empty")
+    }
+}
+
+func TestDefaultStrategy(t *testing.T) {
+    s, err := StrategyByName(DefaultStrategyName)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tp := NewTransactionPicker("testdata/mempool", Constraints{})
+    if got, want := tp.Select(s).Txns, tp.PickUsingPQ(); len(got) != len(want) {
+        t.Errorf("default strategy picked %d transactions, want %d", len(got), len(want))
+    }
+}
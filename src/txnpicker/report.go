@@ -0,0 +1,149 @@
+package txnpicker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/humblenginr/btc-miner/policy"
+)
+
+// decisions taken for the mempool transactions
+const (
+    DecisionSelected = "selected"
+    DecisionSkipped = "skipped"
+    DecisionRejected = "rejected"
+)
+
+// reasons a valid transaction is not selected, besides the name of the constraint it would break
+const (
+    SkipParent = "parent not selected"
+    // the transaction fits in the block, but the strategy preferred other transactions
+    SkipNotChosen = "not chosen"
+)
+
+// ReportRow is the decision taken for a transaction of the mempool source
+type ReportRow struct {
+    Name string `json:"name"`
+    Txid string `json:"txid"`
+    Fee int `json:"fee"`
+    Weight int `json:"weight"`
+    // FeeRate is in sat/vB
+    FeeRate float64 `json:"feerate"`
+    Decision string `json:"decision"`
+    Reason string `json:"reason,omitempty"`
+}
+
+// ReportSummary gives the totals of the block template and counts the decisions
+type ReportSummary struct {
+    Strategy string `json:"strategy"`
+    Transactions int `json:"transactions"`
+    Selected int `json:"selected"`
+    Skipped int `json:"skipped"`
+    Rejected int `json:"rejected"`
+    // Reasons counts the transactions that were not selected by reason
+    Reasons map[string]int `json:"reasons"`
+    BlockFee int `json:"block_fee"`
+    BlockWeight int `json:"block_weight"`
+    // MaxWeight is the weight available to the transactions of the block
+    MaxWeight int `json:"max_weight"`
+    // WeightUtilisation is the percentage of MaxWeight used by the block, zero if the weight is not limited
+    WeightUtilisation float64 `json:"weight_utilisation"`
+    BlockSigOpCost int `json:"block_sigop_cost"`
+    MaxSigOpCost int `json:"max_sigop_cost"`
+}
+
+// Report lists the decision taken for every transaction of the mempool source, in the order of the source
+type Report struct {
+    Summary ReportSummary `json:"summary"`
+    Transactions []ReportRow `json:"transactions"`
+}
+
+// Report explains the template picked by a strategy: every transaction of the mempool source is either selected, skipped (valid, but not selected) or rejected (left out when loading the mempool).
+// The reason a transaction was skipped is found by checking it against the final template, so it is the reason it could not be added to the block as picked.
+func (tp *TransactionsPicker) Report(result SelectionResult) Report {
+    g := tp.Graph()
+    selected := make(map[string]bool, len(result.Txns))
+    for _, t := range result.Txns {
+        selected[t.Txid()] = true
+    }
+    r := Report{
+        Summary: ReportSummary{
+            Strategy: result.Strategy,
+            Reasons: make(map[string]int),
+            BlockFee: result.Fee,
+            BlockWeight: result.Weight,
+            MaxWeight: tp.Constraints.WeightLimit(),
+            BlockSigOpCost: result.SigOpCost,
+            MaxSigOpCost: tp.Constraints.MaxSigOpCost,
+        },
+    }
+    if r.Summary.MaxWeight > 0 {
+        r.Summary.WeightUtilisation = 100 * float64(result.Weight) / float64(r.Summary.MaxWeight)
+    }
+    total := tp.templateTotals(result.Txns)
+    for _, e := range tp.Entries() {
+        row := ReportRow{Name: e.Name, Txid: e.Txid}
+        // the size of the transactions failing CheckTransaction cannot always be computed
+        if e.Txid != "" {
+            row.Fee, row.Weight = e.Fee, e.Weight
+            row.FeeRate = feeRate(row.Fee, policy.GetVSize(row.Weight))
+        }
+        switch {
+        case e.Rejection != "":
+            row.Decision = DecisionRejected
+            row.Reason = e.Rejection
+            if e.Detail != "" {
+                row.Reason += ": " + e.Detail
+            }
+            r.Summary.Rejected++
+            r.Summary.Reasons[e.Rejection]++
+        case selected[e.Txid]:
+            row.Decision = DecisionSelected
+            r.Summary.Selected++
+        default:
+            row.Decision = DecisionSkipped
+            n, _ := g.Node(e.Txid)
+            row.Reason = skipReason(tp.Constraints, n, selected, total)
+            r.Summary.Skipped++
+            r.Summary.Reasons[row.Reason]++
+        }
+        r.Transactions = append(r.Transactions, row)
+    }
+    r.Summary.Transactions = len(r.Transactions)
+    return r
+}
+
+func skipReason(c Constraints, n *TxNode, selected map[string]bool, total totals) string {
+    if !parentsSelected(n, selected) {
+        return SkipParent
+    }
+    if constraint := c.violation(total, nodeTotals(n)); constraint != "" {
+        return constraint
+    }
+    return SkipNotChosen
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    return enc.Encode(r)
+}
+
+// WriteCSV writes a row per transaction, followed by a "total" row with the totals of the block
+func (r Report) WriteCSV(w io.Writer) error {
+    cw := csv.NewWriter(w)
+    cw.Write([]string{"name", "txid", "fee", "weight", "feerate", "decision", "reason"})
+    for _, row := range r.Transactions {
+        cw.Write([]string{row.Name, row.Txid, strconv.Itoa(row.Fee), strconv.Itoa(row.Weight), strconv.FormatFloat(row.FeeRate, 'f', 3, 64), row.Decision, row.Reason})
+    }
+    s := r.Summary
+    blockFeeRate := 0.0
+    if s.BlockWeight > 0 {
+        blockFeeRate = feeRate(s.BlockFee, policy.GetVSize(s.BlockWeight))
+    }
+    cw.Write([]string{"total", "", strconv.Itoa(s.BlockFee), strconv.Itoa(s.BlockWeight), strconv.FormatFloat(blockFeeRate, 'f', 3, 64), DecisionSelected, strconv.FormatFloat(s.WeightUtilisation, 'f', 3, 64) + "% of the block weight"})
+    cw.Flush()
+    return cw.Error()
+}
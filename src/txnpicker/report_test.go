@@ -0,0 +1,98 @@
+package txnpicker
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/humblenginr/btc-miner/source"
+)
+
+// reportPicker returns a picker of the testdata mempool where one chain has an invalid parent and one file is malformed
+func reportPicker(t *testing.T, c Constraints) *TransactionsPicker {
+    parent := readMempoolFile(t, chainParent)
+    parent.Vin[0].PrevOut.Value = 0
+    bad := readMempoolFile(t, cpfpChild)
+    bad.Vout[0].ScriptPubKey = "zz"
+    dir := copyMempool(t, map[string]string{chainParent + ".json": marshalTx(t, parent), "bad.json": marshalTx(t, bad)})
+    tp := NewTransactionPicker(dir, c)
+    tp.Source = source.DirSource{Path: dir}
+    return &tp
+}
+
+func TestReport(t *testing.T) {
+    tp := reportPicker(t, Constraints{MaxTxCount: 1})
+    result := tp.Select(GreedyStrategy{})
+    r := tp.Report(result)
+
+    s := r.Summary
+    if s.Transactions != testdataMempoolSize+1 || s.Selected != 1 || s.Skipped != 5 || s.Rejected != 4 {
+        t.Errorf("summary %+v", s)
+    }
+    if s.Reasons[RejectInvalid] != 2 || s.Reasons[RejectOrphan] != 2 || s.Reasons[ConstraintTxCount]+s.Reasons[SkipParent] != 5 {
+        t.Errorf("reasons %v", s.Reasons)
+    }
+    if s.BlockFee != result.Fee || s.BlockWeight != result.Weight || s.Strategy != "greedy" {
+        t.Errorf("summary does not match the template: %+v", s)
+    }
+    for _, row := range r.Transactions {
+        switch row.Decision {
+        case DecisionSelected:
+            if row.Txid != result.Txns[0].Txid() || row.Fee != result.Fee {
+                t.Errorf("selected row %+v", row)
+            }
+        case DecisionRejected:
+            // the fee of the malformed transaction cannot be computed
+            if row.Name == "bad.json" && (row.Txid != "" || row.Fee != 0) {
+                t.Errorf("malformed row %+v", row)
+            }
+        case DecisionSkipped:
+            if row.Fee == 0 || row.Weight == 0 || row.FeeRate == 0 {
+                t.Errorf("skipped row %+v", row)
+            }
+        }
+    }
+
+    // without constraints, all the valid transactions are selected
+    all := reportPicker(t, Constraints{})
+    if s := all.Report(all.Select(GreedyStrategy{})).Summary; s.Selected != 6 || s.Skipped != 0 || s.WeightUtilisation != 0 {
+        t.Errorf("summary without constraints %+v", s)
+    }
+}
+
+func TestReportOutput(t *testing.T) {
+    tp := reportPicker(t, Constraints{MaxWeight: 4000000, MaxTxCount: 3})
+    r := tp.Report(tp.Select(AncestorScoreStrategy{}))
+
+    var j bytes.Buffer
+    if err := r.WriteJSON(&j); err != nil {
+        t.Fatal(err)
+    }
+    var decoded Report
+    if err := json.Unmarshal(j.Bytes(), &decoded); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(decoded, r) {
+        t.Error("JSON report does not decode to the report")
+    }
+
+    var c bytes.Buffer
+    if err := r.WriteCSV(&c); err != nil {
+        t.Fatal(err)
+    }
+    records, err := csv.NewReader(&c).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    // header, transactions and totals
+    if len(records) != len(r.Transactions)+2 || records[0][0] != "name" || records[len(records)-1][0] != "total" {
+        t.Fatalf("%d CSV records", len(records))
+    }
+    for i, row := range r.Transactions {
+        if rec := records[i+1]; rec[0] != row.Name || rec[1] != row.Txid || rec[5] != row.Decision || rec[6] != row.Reason {
+            t.Errorf("CSV record %v, want %+v", rec, row)
+        }
+    }
+}
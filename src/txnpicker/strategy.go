@@ -0,0 +1,178 @@
+package txnpicker
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// SelectionStrategy decides which mempool transactions go into the block template. The transactions returned are in an order they can be placed in the block, parents before their children.
+type SelectionStrategy interface {
+    Name() string
+    Select(tp *TransactionsPicker) []*txn.Transaction
+}
+
+// SelectionResult is the template picked by a strategy along with its totals
+type SelectionResult struct {
+    Strategy string
+    Txns []*txn.Transaction
+    Fee int
+    Weight int
+    SigOpCost int
+    Count int
+}
+
+func (r SelectionResult) String() string {
+    return fmt.Sprintf("%s: %d txns, %d sats, weight %d, sigop cost %d", r.Strategy, r.Count, r.Fee, r.Weight, r.SigOpCost)
+}
+
+// Select picks transactions using the strategy and calculates the totals of the template
+func (tp *TransactionsPicker) Select(s SelectionStrategy) SelectionResult {
+    txns := s.Select(tp)
+    result := SelectionResult{Strategy: s.Name(), Txns: txns, Count: len(txns)}
+    for _, t := range txns {
+        result.Fee += t.GetFees()
+        result.Weight += t.GetWeight()
+        result.SigOpCost += t.GetSigOpCost()
+    }
+    return result
+}
+
+// CompareStrategies picks transactions using each of the strategies on the same mempool and describes the templates, the best one first
+func (tp *TransactionsPicker) CompareStrategies(strategies ...SelectionStrategy) string {
+    results := make([]SelectionResult, 0, len(strategies))
+    for _, s := range strategies {
+        results = append(results, tp.Select(s))
+    }
+    sort.SliceStable(results, func(i, j int) bool {
+        return results[i].Fee > results[j].Fee
+    })
+    lines := make([]string, 0, len(results))
+    for _, r := range results {
+        lines = append(lines, fmt.Sprintf("%s (%+d sats)", r, r.Fee-results[0].Fee))
+    }
+    return strings.Join(lines, "\n")
+}
+
+// DefaultStrategyName is the strategy the block template is picked with unless another one is chosen
+const DefaultStrategyName = "greedy"
+
+var strategies = map[string]func() SelectionStrategy{
+    "greedy": func() SelectionStrategy { return GreedyStrategy{} },
+    "ancestor": func() SelectionStrategy { return AncestorScoreStrategy{} },
+    "cluster": func() SelectionStrategy { return ClusterStrategy{} },
+    "optimize": func() SelectionStrategy { return &OptimizeStrategy{Budget: DefaultOptimizeBudget} },
+    "oldest": func() SelectionStrategy { return OldestFirstStrategy{} },
+    "maxcount": func() SelectionStrategy { return MaxCountStrategy{} },
+    "random": func() SelectionStrategy { return RandomStrategy{Seed: 1} },
+}
+
+// StrategyByName returns a new strategy with the given name, using the default settings of the strategy
+func StrategyByName(name string) (SelectionStrategy, error) {
+    newStrategy, ok := strategies[name]
+    if !ok {
+        return nil, fmt.Errorf("unknown selection strategy %q (expected one of %s)", name, strings.Join(StrategyNames(), ", "))
+    }
+    return newStrategy(), nil
+}
+
+// StrategyNames returns the names of all the strategies, sorted
+func StrategyNames() []string {
+    names := make([]string, 0, len(strategies))
+    for name := range strategies {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// GreedyStrategy picks the transactions with the highest fee/weight ratio first (PickUsingPQ)
+type GreedyStrategy struct{}
+
+func (GreedyStrategy) Name() string { return "greedy" }
+
+func (GreedyStrategy) Select(tp *TransactionsPicker) []*txn.Transaction {
+    return tp.PickUsingPQ()
+}
+
+// AncestorScoreStrategy picks the packages with the highest ancestor fee/weight ratio first (PickUsingAncestorScore)
+type AncestorScoreStrategy struct{}
+
+func (AncestorScoreStrategy) Name() string { return "ancestor" }
+
+func (AncestorScoreStrategy) Select(tp *TransactionsPicker) []*txn.Transaction {
+    return tp.PickUsingAncestorScore()
+}
+
+// ClusterStrategy merges the chunks of the cluster linearizations (PickUsingClusters)
+type ClusterStrategy struct{}
+
+func (ClusterStrategy) Name() string { return "cluster" }
+
+func (ClusterStrategy) Select(tp *TransactionsPicker) []*txn.Transaction {
+    return tp.PickUsingClusters()
+}
+
+// DefaultOptimizeBudget is the time the optimize strategy searches for unless told otherwise
+var DefaultOptimizeBudget = 10 * time.Second
+
+// OptimizeStrategy searches for the best template using branch and bound (Optimize) for at most Budget
+type OptimizeStrategy struct {
+    Budget time.Duration
+    // Result is the outcome of the last search
+    Result OptimizeResult
+}
+
+func (*OptimizeStrategy) Name() string { return "optimize" }
+
+func (s *OptimizeStrategy) Select(tp *TransactionsPicker) []*txn.Transaction {
+    s.Result = tp.Optimize(s.Budget)
+    return s.Result.Txns
+}
+
+// OldestFirstStrategy picks the transactions in the order they entered the mempool, as given by the source (mempool.dat) or the Pool. Transactions entered at the same time, or whose source does not tell (a folder or JSON lines), are picked in the order of the source.
+type OldestFirstStrategy struct{}
+
+func (OldestFirstStrategy) Name() string { return "oldest" }
+
+func (OldestFirstStrategy) Select(tp *TransactionsPicker) []*txn.Transaction {
+    return tp.pickInDependencyOrder(func(a, b *TxNode) bool {
+        if a.Time != b.Time {
+            return a.Time < b.Time
+        }
+        return a.Index < b.Index
+    })
+}
+
+// MaxCountStrategy picks the lightest transactions first, to fit as many transactions as possible in the block
+type MaxCountStrategy struct{}
+
+func (MaxCountStrategy) Name() string { return "maxcount" }
+
+func (MaxCountStrategy) Select(tp *TransactionsPicker) []*txn.Transaction {
+    return tp.pickInDependencyOrder(func(a, b *TxNode) bool {
+        if a.Weight != b.Weight {
+            return a.Weight < b.Weight
+        }
+        return a.Index < b.Index
+    })
+}
+
+// RandomStrategy picks the transactions in a random order, as a baseline for the other strategies. The same Seed gives the same template.
+type RandomStrategy struct {
+    Seed int64
+}
+
+func (RandomStrategy) Name() string { return "random" }
+
+func (s RandomStrategy) Select(tp *TransactionsPicker) []*txn.Transaction {
+    nodes := tp.Graph().Nodes
+    rank := rand.New(rand.NewSource(s.Seed)).Perm(len(nodes))
+    return tp.pickInDependencyOrder(func(a, b *TxNode) bool {
+        return rank[a.Index] < rank[b.Index]
+    })
+}
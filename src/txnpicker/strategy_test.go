@@ -0,0 +1,46 @@
+package txnpicker
+
+import (
+	"testing"
+
+	"github.com/humblenginr/btc-miner/source"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// entriesSource gives the entries as they are
+type entriesSource []source.Entry
+
+func (s entriesSource) Load() ([]source.Entry, error) {
+    return s, nil
+}
+
+func TestOldestFirstStrategy(t *testing.T) {
+    a, b, c := readMempoolFile(t, single11), readMempoolFile(t, single12), readMempoolFile(t, single16)
+    parent, child := readMempoolFile(t, chainParent), readMempoolFile(t, chainMiddle)
+    tests := []struct {
+        name string
+        entries []source.Entry
+        want []*txn.Transaction
+    }{
+        {"by time", []source.Entry{{Tx: a, Time: 30}, {Tx: b, Time: 10}, {Tx: c, Time: 20}}, []*txn.Transaction{b, c, a}},
+        {"same time in source order", []source.Entry{{Tx: a, Time: 10}, {Tx: b, Time: 10}, {Tx: c, Time: 5}}, []*txn.Transaction{c, a, b}},
+        {"no time in source order", []source.Entry{{Tx: c}, {Tx: a}, {Tx: b}}, []*txn.Transaction{c, a, b}},
+        // the child cannot come before its parent
+        {"child older than its parent", []source.Entry{{Tx: child, Time: 1}, {Tx: a, Time: 2}, {Tx: parent, Time: 3}}, []*txn.Transaction{a, parent, child}},
+    }
+    for _, test := range tests {
+        tp := NewTransactionPicker("", Constraints{})
+        tp.Source = entriesSource(test.entries)
+        got := tp.Select(OldestFirstStrategy{}).Txns
+        if len(got) != len(test.want) {
+            t.Errorf("%s: picked %v", test.name, txids(got))
+            continue
+        }
+        for i := range got {
+            if got[i].Txid() != test.want[i].Txid() {
+                t.Errorf("%s: picked %v, want %v", test.name, txids(got), txids(test.want))
+                break
+            }
+        }
+    }
+}
@@ -0,0 +1,55 @@
+package txnpicker
+
+import (
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// txSummary is what the loaders keep of a mempool transaction to resolve the conflicts and the TRUC policy and to pick it: its identity, size and fee, and the outputs it spends.
+// The streaming loader only keeps the summary, the full transaction is read again from the source once picked.
+type txSummary struct {
+    policy.TxInfo
+    // tx is the full transaction, nil if only the summary is kept
+    tx *txn.Transaction
+    wtxid string
+    weight int
+    // fee is computed from the prevouts of the transaction, so they have to be resolved before it is summarized
+    fee int
+    sigOpCost int
+    // signals is true if the transaction opts in to replacement (see policy.SignalsReplacement)
+    signals bool
+    // time is when the transaction entered the mempool (unix time), zero if the source does not tell
+    time int64
+}
+
+func summarize(tx *txn.Transaction) *txSummary {
+    return &txSummary{
+        TxInfo: policy.NewTxInfo(tx),
+        tx: tx,
+        wtxid: tx.Wtxid(),
+        weight: tx.GetWeight(),
+        fee: tx.GetFees(),
+        sigOpCost: tx.GetSigOpCost(),
+        signals: policy.SignalsReplacement(tx),
+    }
+}
+
+func summarizeAll(txns []*txn.Transaction) []*txSummary {
+    summaries := make([]*txSummary, 0, len(txns))
+    for _, t := range txns {
+        summaries = append(summaries, summarize(t))
+    }
+    return summaries
+}
+
+// spendsSameOutput reports whether the transactions spend an output in common
+func spendsSameOutput(a, b *txSummary) bool {
+    for _, ia := range a.Inputs {
+        for _, ib := range b.Inputs {
+            if ia == ib {
+                return true
+            }
+        }
+    }
+    return false
+}
@@ -0,0 +1,238 @@
+package txnpicker
+
+import (
+	"github.com/humblenginr/btc-miner/mining"
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/txpool"
+)
+
+// TemplateManager keeps a block template up to date as transactions enter and leave a mempool, without picking the transactions and building the block again from scratch every time.
+// A new transaction whose parents are all in the template is appended to it if it fits. Otherwise the transactions are picked again using the strategy, but only once the transactions left out since the last pick could gain more than MinFeeGain sats (see maxGain), so that a full template is not picked again for every transaction arriving. The merkle trees of the block are updated rather than built again (see mining.TemplateBuilder).
+// A new template is only emitted when it gains more than MinFeeGain sats over the current one, or when the current one is no longer valid because some of its transactions left the mempool.
+type TemplateManager struct {
+    Picker *TransactionsPicker
+    Strategy SelectionStrategy
+    // MinFeeGain is the fee (in sats) a new template has to gain over the current one to be emitted
+    MinFeeGain int
+
+    builder mining.TemplateBuilder
+    // selection the next template is built from, its totals and its chunks (see addChunk)
+    selected []*txn.Transaction
+    inSelection map[string]bool
+    total totals
+    chunks []totals
+    // reselect is set when the transactions have to be picked again using the strategy
+    reselect bool
+    // pendingGain is the most the transactions left out of the selection since the last pick can gain
+    pendingGain int
+    template *mining.Block
+    templateTxns []*txn.Transaction
+    templateTotal totals
+    inTemplate map[string]bool
+    // stale is set when the current template has transactions that left the mempool
+    stale bool
+}
+
+// NewTemplateManager returns a manager picking the transactions of pool within the constraints using the strategy, for blocks paying to payoutScript (see mining.NewCoinbaseTransaction). The first template is built by the first call to Update.
+func NewTemplateManager(pool *txpool.Mempool, constraints Constraints, s SelectionStrategy, minFeeGain int, payoutScript []byte, hasWitness bool) *TemplateManager {
+    picker := NewTransactionPicker("", constraints)
+    picker.Pool = pool
+    return &TemplateManager{
+        Picker: &picker,
+        Strategy: s,
+        MinFeeGain: minFeeGain,
+        builder: mining.TemplateBuilder{PayoutScript: payoutScript, HasWitness: hasWitness},
+        inSelection: make(map[string]bool),
+        reselect: true,
+    }
+}
+
+// Template returns the last emitted template, nil if none was emitted yet
+func (m *TemplateManager) Template() *mining.Block {
+    return m.template
+}
+
+// Result returns the transactions of the last emitted template and their totals
+func (m *TemplateManager) Result() SelectionResult {
+    t := m.templateTotal
+    return SelectionResult{Strategy: m.Strategy.Name(), Txns: m.templateTxns, Fee: t.fee, Weight: t.weight, SigOpCost: t.sigOpCost, Count: t.count}
+}
+
+// Add submits the transaction to the mempool and updates the template. The new template is returned if one is emitted, nil otherwise.
+func (m *TemplateManager) Add(tx *txn.Transaction) (*mining.Block, error) {
+    evicted, err := m.Picker.Pool.Submit(tx)
+    m.drop(evicted)
+    if err != nil {
+        return m.Update(), err
+    }
+    if !m.reselect && !m.tryAppend(tx) {
+        m.pendingGain += m.maxGain(tx)
+        m.reselect = m.pendingGain > m.MinFeeGain
+    }
+    return m.Update(), nil
+}
+
+// Remove removes the transaction and its descendants from the mempool, and updates the template. The new template is returned if one is emitted, nil otherwise.
+func (m *TemplateManager) Remove(txid string) *mining.Block {
+    m.drop(m.Picker.Pool.Remove(txid))
+    return m.Update()
+}
+
+// RemoveForBlock removes the transactions confirmed by a block from the mempool (see txpool.Mempool.RemoveForBlock), and updates the template. The new template is returned if one is emitted, nil otherwise.
+func (m *TemplateManager) RemoveForBlock(txns []*txn.Transaction) *mining.Block {
+    m.drop(m.Picker.Pool.RemoveForBlock(txns))
+    return m.Update()
+}
+
+// Update picks the transactions again if needed, and emits a new template if it gains more than MinFeeGain over the current one or if the current one is stale. The new template is returned if one is emitted, nil otherwise.
+func (m *TemplateManager) Update() *mining.Block {
+    if m.reselect {
+        m.reselect = false
+        m.pendingGain = 0
+        txns := m.Strategy.Select(m.Picker)
+        // a template appended to can be better than the one picked by the strategy
+        if m.Picker.templateTotals(txns).fee > m.total.fee {
+            m.setSelection(txns)
+        }
+    }
+    if m.template != nil && !m.stale && m.total.fee-m.templateTotal.fee <= m.MinFeeGain {
+        return nil
+    }
+    block := m.builder.Build(m.selected)
+    m.template = &block
+    m.templateTxns = append([]*txn.Transaction{}, m.selected...)
+    m.templateTotal = m.total
+    m.inTemplate = make(map[string]bool, len(m.selected))
+    for _, tx := range m.selected {
+        m.inTemplate[tx.Txid()] = true
+    }
+    m.stale = false
+    return m.template
+}
+
+func (m *TemplateManager) setSelection(txns []*txn.Transaction) {
+    m.selected = txns
+    m.total = totals{}
+    m.inSelection = make(map[string]bool, len(txns))
+    m.chunks = nil
+    for _, tx := range txns {
+        t := txTotals(tx)
+        m.inSelection[tx.Txid()] = true
+        m.total.add(t)
+        m.addChunk(t)
+    }
+}
+
+func txTotals(tx *txn.Transaction) totals {
+    return totals{weight: tx.GetWeight(), fee: tx.GetFees(), sigOpCost: tx.GetSigOpCost(), count: 1}
+}
+
+// addChunk adds the transaction appended to the selection to its chunks: the transaction is merged into the last chunk as long as it pays a higher fee rate, the way ChunkLinearization does. The chunks are in order of decreasing fee rate, so a parent paid for by its child counts with the fee rate of both.
+func (m *TemplateManager) addChunk(t totals) {
+    for len(m.chunks) > 0 {
+        last := m.chunks[len(m.chunks)-1]
+        if !higherFeeRate(t.fee, t.weight, last.fee, last.weight) {
+            break
+        }
+        t.add(last)
+        m.chunks = m.chunks[:len(m.chunks)-1]
+    }
+    m.chunks = append(m.chunks, t)
+}
+
+// minFeeRate returns the fee rate of the last chunk of the selection, the lowest one, zero if nothing is selected
+func (m *TemplateManager) minFeeRate() float64 {
+    if len(m.chunks) == 0 {
+        return 0
+    }
+    last := m.chunks[len(m.chunks)-1]
+    return feeRate(last.fee, policy.GetVSize(last.weight))
+}
+
+// tryAppend adds the transaction to the end of the selection if all its mempool parents are selected and it fits within the constraints
+func (m *TemplateManager) tryAppend(tx *txn.Transaction) bool {
+    pool := m.Picker.Pool
+    txid := tx.Txid()
+    e, ok := pool.Lookup(txid)
+    if !ok {
+        return false
+    }
+    for _, in := range tx.Vin {
+        if _, unconfirmed := pool.Lookup(in.Txid); unconfirmed && !m.inSelection[in.Txid] {
+            return false
+        }
+    }
+    t := totals{weight: e.Weight, fee: e.Fee, sigOpCost: tx.GetSigOpCost(), count: 1}
+    if !m.Picker.Constraints.fits(m.total, t) {
+        return false
+    }
+    m.selected = append(m.selected, tx)
+    m.inSelection[txid] = true
+    m.total.add(t)
+    m.addChunk(t)
+    return true
+}
+
+// drop removes the transactions that left the mempool from the selection, which is picked again to fill the space left. The current template is stale if any of them was in it.
+func (m *TemplateManager) drop(txids []string) {
+    removed := make(map[string]bool, len(txids))
+    for _, txid := range txids {
+        if m.inSelection[txid] {
+            removed[txid] = true
+        }
+        m.stale = m.stale || m.inTemplate[txid]
+    }
+    if len(removed) == 0 {
+        return
+    }
+    kept := make([]*txn.Transaction, 0, len(m.selected))
+    for _, tx := range m.selected {
+        if !removed[tx.Txid()] {
+            kept = append(kept, tx)
+        }
+    }
+    m.setSelection(kept)
+    m.reselect = true
+}
+
+// maxGain returns the most the fee of the selection can grow by adding the transaction along with its mempool ancestors left out of it. Without room for them, they take the place of selected transactions paying at least the lowest chunk fee rate of the selection (see minFeeRate), for the weight missing.
+func (m *TemplateManager) maxGain(tx *txn.Transaction) int {
+    pkg := m.packageTotals(tx)
+    if m.Picker.Constraints.fits(m.total, pkg) {
+        return pkg.fee
+    }
+    missing := pkg.weight
+    if limit := m.Picker.Constraints.WeightLimit(); limit > 0 && limit > m.total.weight {
+        missing -= limit - m.total.weight
+    }
+    if missing <= 0 {
+        return pkg.fee
+    }
+    if gain := pkg.fee - int(m.minFeeRate()*float64(policy.GetVSize(missing))); gain > 0 {
+        return gain
+    }
+    return 0
+}
+
+// packageTotals returns the totals of the transaction along with its mempool ancestors not in the selection
+func (m *TemplateManager) packageTotals(tx *txn.Transaction) totals {
+    pool := m.Picker.Pool
+    t := txTotals(tx)
+    seen := map[string]bool{tx.Txid(): true}
+    queue := []*txn.Transaction{tx}
+    for len(queue) > 0 {
+        cur := queue[0]
+        queue = queue[1:]
+        for _, in := range cur.Vin {
+            e, ok := pool.Lookup(in.Txid)
+            if !ok || seen[in.Txid] || m.inSelection[in.Txid] {
+                continue
+            }
+            seen[in.Txid] = true
+            t.add(totals{weight: e.Weight, fee: e.Fee, sigOpCost: e.Tx.GetSigOpCost(), count: 1})
+            queue = append(queue, e.Tx)
+        }
+    }
+    return t
+}
@@ -0,0 +1,96 @@
+package txnpicker
+
+import (
+	"testing"
+
+	"github.com/humblenginr/btc-miner/mining"
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/txpool"
+)
+
+// files of testdata/mempool holding transactions without mempool parents, paying 1220 sats (11 sat/vB), 2676 sats (12 sat/vB) and 1600 sats (16 sat/vB)
+const (
+    single11 = "000cb561188c762c81f76976f816829424e2af9e0e491c617b7bf41038df3d35"
+    single12 = "00d12b523d8b7ad90e2269767478764c243625539dc59bcd457d14ca1aa4e38c"
+    single16 = "00d9c01fd8722f63cc327c93e59de64395d1e6ca5861ae6b9b149b364d082352"
+)
+
+// countingStrategy picks using the ancestor score and counts how many times it picked
+type countingStrategy struct {
+    picks int
+}
+
+func (s *countingStrategy) Name() string {
+    return "counting"
+}
+
+func (s *countingStrategy) Select(tp *TransactionsPicker) []*txn.Transaction {
+    s.picks++
+    return tp.PickUsingAncestorScore()
+}
+
+func TestTemplateManagerFeeGain(t *testing.T) {
+    m := NewTemplateManager(txpool.New(false), Constraints{}, &countingStrategy{}, 2000, nil, true)
+    tests := []struct {
+        name string
+        // names of the files of the transaction added or removed
+        add string
+        remove string
+        emitted bool
+        txns int
+    }{
+        {"first template", single11, "", true, 1},
+        {"gain below the threshold", single16, "", false, 1},
+        {"gain above the threshold", single12, "", true, 3},
+        {"transaction of the template removed", "", single16, true, 2},
+        {"gain below the threshold again", cpfpParent, "", false, 2},
+    }
+    for _, test := range tests {
+        var block *mining.Block
+        if test.add != "" {
+            var err error
+            if block, err = m.Add(readMempoolFile(t, test.add)); err != nil {
+                t.Fatalf("%s: %v", test.name, err)
+            }
+        } else {
+            block = m.Remove(readMempoolFile(t, test.remove).Txid())
+        }
+        if (block != nil) != test.emitted {
+            t.Errorf("%s: emitted %v, want %v", test.name, block != nil, test.emitted)
+        }
+        if r := m.Result(); r.Count != test.txns || len(m.Template().Transactions) != test.txns+1 {
+            t.Errorf("%s: template has %d transactions, want %d", test.name, r.Count, test.txns)
+        }
+    }
+}
+
+func TestTemplateManagerFullTemplate(t *testing.T) {
+    // the template is full with the CPFP chain of three transactions: the parent pays 9.8 sat/vB on its own and 13.9 sat/vB along with its descendants
+    s := &countingStrategy{}
+    m := NewTemplateManager(txpool.New(false), Constraints{MaxTxCount: 3}, s, 1000, nil, true)
+    tests := []struct {
+        name string
+        file string
+        picks int
+    }{
+        {"first template", cpfpParent, 1},
+        {"parent appended", cpfpMiddle, 1},
+        {"child appended", cpfpChild, 1},
+        // above the fee rate of the parent alone, but below the one of the chain
+        {"low fee rate", single11, 1},
+        {"another low fee rate", single12, 1},
+        {"higher fee rate", chainParent, 2},
+    }
+    for _, test := range tests {
+        if _, err := m.Add(readMempoolFile(t, test.file)); err != nil {
+            t.Fatalf("%s: %v", test.name, err)
+        }
+        if s.picks != test.picks {
+            t.Errorf("%s: picked %d times, want %d", test.name, s.picks, test.picks)
+        }
+    }
+    // the child does not fit along with its ancestors anymore
+    if r := m.Result(); r.Count != 3 || r.Fee != 48550+1383+3090 {
+        t.Errorf("template %v, want the parent of the chain and the CPFP parent and middle transaction", r)
+    }
+}
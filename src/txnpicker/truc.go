@@ -0,0 +1,87 @@
+package txnpicker
+
+import (
+	"fmt"
+
+	"github.com/humblenginr/btc-miner/policy"
+)
+
+// rejection is why a transaction is left out of the mempool, a Reject reason along with its detail
+type rejection struct {
+    reason string
+    detail string
+}
+
+// applyTRUCPolicy leaves out the transactions breaking the TRUC (version 3) or the ephemeral dust rules, and returns the other ones in order along with why each of them was left out.
+// A TRUC transaction with more than one child keeps the child with the highest fee rate, the way a child evicts its sibling in the mempool. A transaction with ephemeral dust is left out if no transaction spends it, as it can only be mined along with a child paying for it.
+// The descendants of the transactions left out are not, they are expected to be excluded as orphans.
+func applyTRUCPolicy(txns []*txSummary) ([]*txSummary, map[string]rejection) {
+    g := newGraph(txns)
+    rejected := make(map[string]rejection)
+    reject := func(n *TxNode, reason string, detail string) {
+        rejected[n.Txid] = rejection{reason, detail}
+    }
+    for _, n := range g.Nodes {
+        info := n.summary.TxInfo
+        parents := make([]policy.TxInfo, 0, len(n.Parents))
+        for _, p := range n.Parents {
+            parents = append(parents, p.summary.TxInfo)
+        }
+        // the fee delta does not count, the dust has to be paid for by a child
+        if err := info.CheckEphemeralDust(n.Fee); err != nil {
+            reject(n, RejectEphemeralDust, err.Error())
+        } else if err := info.CheckEphemeralSpends(parents); err != nil {
+            reject(n, RejectEphemeralDust, err.Error())
+        } else if err := info.CheckTRUC(policy.GetVSize(n.Weight), parents, len(g.Ancestors(n))); err != nil {
+            reject(n, RejectTRUC, err.Error())
+        }
+    }
+
+    for _, n := range g.Nodes {
+        if !n.summary.IsTRUC() {
+            continue
+        }
+        children := make([]*TxNode, 0, len(n.Children))
+        var best *TxNode
+        for _, c := range n.Children {
+            if _, ok := rejected[c.Txid]; ok {
+                continue
+            }
+            children = append(children, c)
+            if best == nil || higherPriority(c, best) {
+                best = c
+            }
+        }
+        if len(children)+1 <= policy.TRUCDescendantLimit {
+            continue
+        }
+        for _, c := range children {
+            if c != best {
+                reject(c, RejectTRUC, fmt.Sprintf("%v: evicted by its sibling %s", policy.ErrTRUCTooManyDescendants, best.Txid))
+            }
+        }
+    }
+
+    for _, n := range g.Nodes {
+        if _, ok := rejected[n.Txid]; ok || len(n.summary.Dust) == 0 {
+            continue
+        }
+        spent := false
+        for _, c := range n.Children {
+            if _, ok := rejected[c.Txid]; !ok {
+                spent = true
+            }
+        }
+        if !spent {
+            reject(n, RejectEphemeralDust, "no mempool transaction spends the ephemeral dust")
+        }
+    }
+
+    kept := make([]*txSummary, 0, len(txns))
+    for _, n := range g.Nodes {
+        if _, ok := rejected[n.Txid]; !ok {
+            kept = append(kept, n.summary)
+        }
+    }
+    return kept, rejected
+}
@@ -0,0 +1,59 @@
+package txnpicker
+
+import (
+	"testing"
+
+	"github.com/humblenginr/btc-miner/internal/txtest"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+func TestApplyTRUCPolicy(t *testing.T) {
+    parent := txtest.AsTRUC(fakeTx(1000, 0))
+    parent.Vout = append(parent.Vout, txn.Vout{ScriptPubKey: "51", Value: 10000})
+    parent.Vout[0].Value -= 10000
+    child := txtest.AsTRUC(fakeTx(1000, 0, parent))
+    // spends the second output of the parent, paying a higher fee rate than child
+    sibling := txtest.AsTRUC(fakeTx(5000, 0))
+    sibling.Vin[0] = txn.Vin{Txid: parent.Txid(), Vout: 1, PrevOut: parent.Vout[1], Sequence: 0xffffffff}
+    sibling.Vout[0].Value = parent.Vout[1].Value - 5000
+    grandchild := txtest.AsTRUC(fakeTx(1000, 0, child))
+    nonTRUCChild := fakeTx(1000, 0, parent)
+
+    dust := txtest.WithDust(fakeTx(0, 0))
+    dustChild := fakeTx(1000, 0, dust)
+    dustChild.Vin = append(dustChild.Vin, txn.Vin{Txid: dust.Txid(), Vout: 1, PrevOut: dust.Vout[1], Sequence: 0xffffffff})
+    unspentDust := txtest.WithDust(fakeTx(0, 0))
+    dustWithFee := txtest.WithDust(fakeTx(1000, 0))
+    trucDust := txtest.AsTRUC(txtest.WithDust(fakeTx(0, 0)))
+    nonTRUCDustChild := fakeTx(1000, 0, trucDust)
+    nonTRUCDustChild.Vin = append(nonTRUCDustChild.Vin, txn.Vin{Txid: trucDust.Txid(), Vout: 1, PrevOut: trucDust.Vout[1], Sequence: 0xffffffff})
+
+    tests := []struct {
+        name string
+        txns []*txn.Transaction
+        // reasons of the transactions left out
+        rejected map[*txn.Transaction]string
+    }{
+        {"parent and child", []*txn.Transaction{parent, child}, nil},
+        {"sibling with the higher fee rate is kept", []*txn.Transaction{parent, child, sibling}, map[*txn.Transaction]string{child: RejectTRUC}},
+        {"too many ancestors", []*txn.Transaction{parent, child, grandchild}, map[*txn.Transaction]string{grandchild: RejectTRUC}},
+        {"non TRUC child", []*txn.Transaction{parent, nonTRUCChild}, map[*txn.Transaction]string{nonTRUCChild: RejectTRUC}},
+        {"ephemeral dust spent", []*txn.Transaction{dust, dustChild}, nil},
+        {"ephemeral dust not spent", []*txn.Transaction{unspentDust}, map[*txn.Transaction]string{unspentDust: RejectEphemeralDust}},
+        {"dust with fee", []*txn.Transaction{dustWithFee}, map[*txn.Transaction]string{dustWithFee: RejectEphemeralDust}},
+        // the child spending the dust is left out, and so is the parent as its dust is no longer spent
+        {"dust spender rejected", []*txn.Transaction{trucDust, nonTRUCDustChild}, map[*txn.Transaction]string{trucDust: RejectEphemeralDust, nonTRUCDustChild: RejectTRUC}},
+    }
+    for _, test := range tests {
+        kept, rejected := applyTRUCPolicy(summarizeAll(test.txns))
+        if len(kept)+len(rejected) != len(test.txns) || len(rejected) != len(test.rejected) {
+            t.Errorf("%s: kept %d, rejected %v", test.name, len(kept), rejected)
+            continue
+        }
+        for tx, reason := range test.rejected {
+            if r, ok := rejected[tx.Txid()]; !ok || r.reason != reason {
+                t.Errorf("%s: %s rejected with %v, want %s", test.name, tx.Txid(), r, reason)
+            }
+        }
+    }
+}
@@ -0,0 +1,147 @@
+package txpool
+
+import (
+	"math"
+	"time"
+
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// Implemented using the mempool limits of bitcoin core (CTxMemPool::TrimToSize, CTxMemPool::Expire and CTxMemPool::GetMinFee) as the reference
+
+var (
+    // DefaultMaxSize is the default total virtual size of the mempool transactions (-maxmempool)
+    DefaultMaxSize = 300000000
+    // DefaultExpiry is the default time a transaction stays in the mempool (-mempoolexpiry)
+    DefaultExpiry = 336 * time.Hour
+    // RollingFeeHalfLife is how long it takes the rolling minimum fee rate to halve, once a block was found since it was last raised
+    RollingFeeHalfLife = 12 * time.Hour
+)
+
+// rollingFee is the minimum fee rate raised every time transactions are evicted because the mempool is full, and decaying once blocks are found
+type rollingFee struct {
+    // rate in sat/vB
+    rate float64
+    lastUpdate time.Time
+    blockSinceBump bool
+}
+
+// MinFeeRate returns the fee rate (sat/vB) a transaction has to pay to be accepted in the mempool now
+func (mp *Mempool) MinFeeRate() float64 {
+    return mp.minFeeRateAt(time.Now())
+}
+
+func (mp *Mempool) minFeeRateAt(now time.Time) float64 {
+    r := &mp.rollingMinFee
+    if r.blockSinceBump && r.rate > 0 {
+        if elapsed := now.Sub(r.lastUpdate); elapsed > 10*time.Second {
+            // the fee rate decays faster when the mempool is far from full
+            halfLife := RollingFeeHalfLife
+            if mp.MaxSize > 0 && mp.size < mp.MaxSize/4 {
+                halfLife /= 4
+            } else if mp.MaxSize > 0 && mp.size < mp.MaxSize/2 {
+                halfLife /= 2
+            }
+            r.rate /= math.Pow(2, elapsed.Seconds()/halfLife.Seconds())
+            r.lastUpdate = now
+            if r.rate < float64(policy.IncrementalRelayFeeRate)/2 {
+                r.rate = 0
+            }
+        }
+    }
+    return math.Max(r.rate, mp.MinRelayFeeRate)
+}
+
+func (mp *Mempool) bumpRollingFee(rate float64, now time.Time) {
+    r := &mp.rollingMinFee
+    if rate > r.rate {
+        r.rate = rate
+        r.blockSinceBump = false
+    }
+    r.lastUpdate = now
+}
+
+// descendantScore returns the fee and virtual size the entry is evicted by: those of the entry with its descendants, or of the entry alone if it has a higher fee rate
+func (mp *Mempool) descendantScore(e *Entry) (int, int) {
+    fee, size := e.descendantFee, e.descendantSize
+    if e.Fee*size > fee*e.VSize() {
+        return e.Fee, e.VSize()
+    }
+    return fee, size
+}
+
+// TrimToSize evicts the transactions with the lowest descendant fee rate, along with their descendants, until the total virtual size of the mempool is at most maxSize. The rolling minimum fee rate is raised above the fee rate of every evicted package.
+// The txids of the evicted transactions are returned.
+func (mp *Mempool) TrimToSize(maxSize int) []string {
+    return mp.trimToSize(maxSize, time.Now())
+}
+
+func (mp *Mempool) trimToSize(maxSize int, now time.Time) []string {
+    removed := make([]string, 0)
+    for mp.size > maxSize && len(mp.order) > 0 {
+        var worst *Entry
+        worstFee, worstSize := 0, 0
+        for _, e := range mp.order {
+            fee, size := mp.descendantScore(e)
+            if worst == nil || fee*worstSize < worstFee*size {
+                worst, worstFee, worstSize = e, fee, size
+            }
+        }
+        // a new transaction has to pay more than the evicted package, by the incremental relay fee rate
+        mp.bumpRollingFee(float64(worstFee)/float64(worstSize)+float64(policy.IncrementalRelayFeeRate), now)
+        removed = append(removed, mp.Remove(worst.Txid)...)
+    }
+    return removed
+}
+
+// Expire removes the transactions that entered the mempool more than Expiry before now, along with their descendants, and returns their txids
+func (mp *Mempool) Expire(now time.Time) []string {
+    if mp.Expiry <= 0 {
+        return nil
+    }
+    cutoff := now.Add(-mp.Expiry)
+    expired := make([]*Entry, 0)
+    for _, e := range mp.order {
+        if e.Time.Before(cutoff) {
+            expired = append(expired, e)
+        }
+    }
+    removed := make([]string, 0)
+    for _, e := range expired {
+        // nothing is returned if it was already removed as a descendant
+        removed = append(removed, mp.Remove(e.Txid)...)
+    }
+    return removed
+}
+
+// RemoveForBlock removes the transactions confirmed by a block, and the mempool transactions spending the same outputs as them along with their descendants. The children of the confirmed transactions stay in the mempool.
+// If the mempool has a utxo set, the outputs spent and created by the block are applied to it. The txids of the removed transactions are returned.
+func (mp *Mempool) RemoveForBlock(txns []*txn.Transaction) []string {
+    removed := make([]string, 0)
+    for _, t := range txns {
+        txid := t.Txid()
+        if e, ok := mp.entries[txid]; ok {
+            mp.removeEntries([]*Entry{e})
+            removed = append(removed, txid)
+        }
+        if !t.IsCoinbase() {
+            for _, in := range t.Vin {
+                if spender, ok := mp.spentBy[in.OutPoint()]; ok {
+                    removed = append(removed, mp.Remove(spender)...)
+                }
+            }
+        }
+        if mp.UTXOSet != nil {
+            mp.view()
+            if !t.IsCoinbase() {
+                for _, in := range t.Vin {
+                    mp.outputs.SpendUTXO(in.OutPoint())
+                }
+            }
+            mp.outputs.AddTxOutputs(t)
+        }
+    }
+    mp.rollingMinFee.blockSinceBump = true
+    return removed
+}
@@ -0,0 +1,160 @@
+package txpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/humblenginr/btc-miner/internal/txtest"
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// checkDescendantTotals compares the descendant totals kept by the mempool with the ones computed from the descendants of every entry
+func checkDescendantTotals(t *testing.T, mp *Mempool) {
+    t.Helper()
+    for _, e := range mp.order {
+        fee, size := e.Fee, e.VSize()
+        for _, d := range mp.descendants(e.Txid) {
+            fee += d.Fee
+            size += d.VSize()
+        }
+        if e.descendantFee != fee || e.descendantSize != size {
+            t.Errorf("%s: descendant totals %d sats, %d vB, want %d and %d", e.Txid, e.descendantFee, e.descendantSize, fee, size)
+        }
+    }
+}
+
+func TestDescendantTotals(t *testing.T) {
+    parent := fakeTx(1000, false)
+    a := fakeTx(1000, false, parent)
+    b := fakeTx(2000, false, parent)
+    // reaches the parent through both a and b
+    joint := fakeTx(3000, false, a, b)
+    other := fakeTx(500, false)
+
+    mp := New(false)
+    addFake(mp, parent, a, b, joint, other)
+    checkDescendantTotals(t, mp)
+    p, _ := mp.Lookup(parent.Txid())
+    if p.descendantFee != 7000 {
+        t.Errorf("parent descendant fee %d, want 7000", p.descendantFee)
+    }
+    mp.Remove(b.Txid())
+    checkDescendantTotals(t, mp)
+    if p.descendantFee != 2000 {
+        t.Errorf("parent descendant fee %d after removing b, want 2000", p.descendantFee)
+    }
+
+    // without a utxo set, children can come first
+    orphans := New(false)
+    addFake(orphans, joint, a, b, parent)
+    checkDescendantTotals(t, orphans)
+    orphans.Remove(a.Txid())
+    checkDescendantTotals(t, orphans)
+
+    // the children of the confirmed transaction stay
+    confirmed := New(false)
+    addFake(confirmed, parent, a, b, joint)
+    confirmed.RemoveForBlock([]*txn.Transaction{parent})
+    checkDescendantTotals(t, confirmed)
+    if confirmed.Len() != 3 {
+        t.Errorf("%d transactions left, want 3", confirmed.Len())
+    }
+}
+
+func TestTrimToSize(t *testing.T) {
+    // the parent pays little, but its child pays for both of them
+    parent := fakeTx(100, false)
+    child := fakeTx(20000, false, parent)
+    mid := fakeTx(5000, false)
+    low := fakeTx(1000, false)
+    lowChild := fakeTx(1000, false, low)
+    size := policy.GetVSize(parent.GetWeight())
+
+    mp := New(false)
+    addFake(mp, parent, child, mid, low, lowChild)
+    before := mp.MinFeeRate()
+    removed := mp.TrimToSize(mp.Size() - 1)
+    // low and its child are evicted together
+    if len(removed) != 2 || removed[0] != low.Txid() || removed[1] != lowChild.Txid() {
+        t.Errorf("TrimToSize() evicted %v, want the low fee transaction and its child", removed)
+    }
+    checkDescendantTotals(t, mp)
+    if mp.MinFeeRate() <= before || mp.MinFeeRate() < float64(1000)/float64(size)+float64(policy.IncrementalRelayFeeRate) {
+        t.Errorf("rolling minimum fee rate %.3f not raised above the evicted package", mp.MinFeeRate())
+    }
+
+    removed = mp.TrimToSize(mp.Size() - 1)
+    if len(removed) != 1 || removed[0] != mid.Txid() {
+        t.Errorf("TrimToSize() evicted %v, want the mid fee transaction before the parent paid for by its child", removed)
+    }
+    if removed := mp.TrimToSize(mp.Size()); len(removed) != 0 {
+        t.Error("TrimToSize() evicted transactions from a mempool within the size")
+    }
+}
+
+func TestRollingFeeDecay(t *testing.T) {
+    mp := New(false)
+    mp.MaxSize = 1000000
+    now := time.Now()
+    mp.bumpRollingFee(20, now)
+    if r := mp.minFeeRateAt(now.Add(24 * time.Hour)); r != 20 {
+        t.Errorf("fee rate decayed to %.3f before a block was found", r)
+    }
+    mp.RemoveForBlock(nil)
+    // the mempool is nearly empty, the half life is divided by 4
+    if r := mp.minFeeRateAt(now.Add(RollingFeeHalfLife / 4)); r < 9.99 || r > 10.01 {
+        t.Errorf("fee rate %.3f after a half life, want 10", r)
+    }
+    if r := mp.minFeeRateAt(now.Add(100 * RollingFeeHalfLife)); r != mp.MinRelayFeeRate {
+        t.Errorf("fee rate %.3f does not go back to the minimum relay fee rate", r)
+    }
+}
+
+func TestExpire(t *testing.T) {
+    now := time.Now()
+    old := fakeTx(1000, false)
+    child := fakeTx(1000, false, old)
+    recent := fakeTx(1000, false)
+
+    mp := New(false)
+    mp.addEntry(newEntry(old, now.Add(-mp.Expiry-time.Second)))
+    mp.addEntry(newEntry(child, now))
+    mp.addEntry(newEntry(recent, now.Add(-mp.Expiry+time.Second)))
+    removed := mp.Expire(now)
+    if len(removed) != 2 || removed[0] != old.Txid() || removed[1] != child.Txid() {
+        t.Errorf("Expire() = %v, want the old transaction and its child", removed)
+    }
+    if mp.Len() != 1 {
+        t.Errorf("%d transactions left, want 1", mp.Len())
+    }
+
+    mp.Expiry = 0
+    mp.addEntry(newEntry(fakeTx(1000, false), now.Add(-1000*time.Hour)))
+    if removed := mp.Expire(now); len(removed) != 0 {
+        t.Errorf("Expire() without expiry removed %v", removed)
+    }
+}
+
+func TestRemoveForBlock(t *testing.T) {
+    confirmed := fakeTx(1000, false)
+    child := fakeTx(1000, false, confirmed)
+    conflict := fakeTx(1000, false)
+    conflictChild := fakeTx(1000, false, conflict)
+    unrelated := fakeTx(1000, false)
+    // spends the same output as the conflict
+    inBlock := txtest.Respend(conflict, 2000)
+
+    mp := New(false)
+    addFake(mp, confirmed, child, conflict, conflictChild, unrelated)
+    removed := mp.RemoveForBlock([]*txn.Transaction{confirmed, inBlock})
+    if len(removed) != 3 {
+        t.Errorf("RemoveForBlock() = %v, want the confirmed transaction and the conflict with its child", removed)
+    }
+    for _, tx := range []*txn.Transaction{child, unrelated} {
+        if _, ok := mp.Lookup(tx.Txid()); !ok {
+            t.Errorf("%s removed", tx.Txid())
+        }
+    }
+    checkDescendantTotals(t, mp)
+}
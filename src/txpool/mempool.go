@@ -0,0 +1,509 @@
+package txpool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+	"github.com/humblenginr/btc-miner/utxo"
+	"github.com/humblenginr/btc-miner/validation"
+)
+
+var (
+    ErrAlreadyInPool = errors.New("transaction is already in the mempool")
+    ErrNewUnconfirmedInput = errors.New("replacement spends an unconfirmed output the replaced transactions did not spend")
+    ErrSpendsConflict = errors.New("replacement spends an output of a transaction it replaces")
+    ErrMempoolMinFee = errors.New("fee rate is below the minimum fee rate of the mempool")
+    ErrMempoolFull = errors.New("mempool is full")
+)
+
+// Entry is a transaction in the mempool
+type Entry struct {
+    Tx *txn.Transaction
+    Txid string
+    Fee int
+    Weight int
+    // Time is when the transaction entered the mempool
+    Time time.Time
+    // fee and virtual size of the transaction along with all of its in-mempool descendants, kept up to date as transactions are added and removed
+    descendantFee int
+    descendantSize int
+}
+
+func (e *Entry) VSize() int {
+    return policy.GetVSize(e.Weight)
+}
+
+// FeeRate returns the fee rate of the transaction in sat/vB
+func (e *Entry) FeeRate() float64 {
+    return float64(e.Fee) / float64(e.VSize())
+}
+
+// Mempool keeps the accepted transactions between submissions. A submitted transaction spending the same outputs as transactions already in the mempool replaces them only if the BIP125 rules allow it.
+type Mempool struct {
+    // FullRBF lets transactions be replaced even if they do not signal replaceability
+    FullRBF bool
+    // UTXOSet, if set, is used to resolve the prevouts of submitted transactions, along with the outputs of the transactions in the mempool
+    UTXOSet utxo.UTXOView
+    // MaxSize is the maximum total virtual size of the mempool transactions, the transactions with the lowest descendant fee rate are evicted beyond it. Zero means no limit.
+    MaxSize int
+    // Expiry is how long a transaction stays in the mempool. Zero means transactions never expire.
+    Expiry time.Duration
+    // MinRelayFeeRate is the lowest fee rate (sat/vB) accepted, the rolling minimum fee rate can only raise it
+    MinRelayFeeRate float64
+
+    entries map[string]*Entry
+    // order the entries were added in
+    order []*Entry
+    // spentBy maps every output spent by a mempool transaction to the txid of that transaction
+    spentBy map[txn.OutPoint]string
+    // children maps a txid to the txids of the mempool transactions spending its outputs
+    children map[string]map[string]bool
+    // outputs of the mempool transactions on top of UTXOSet, created on first use
+    outputs *utxo.MemoryView
+    version int
+    // total virtual size of the entries
+    size int
+    rollingMinFee rollingFee
+}
+
+// New returns an empty mempool with the default limits of bitcoin core
+func New(fullRBF bool) *Mempool {
+    return &Mempool{
+        FullRBF: fullRBF,
+        MaxSize: DefaultMaxSize,
+        Expiry: DefaultExpiry,
+        MinRelayFeeRate: float64(policy.MinRelayFeeRate),
+        entries: make(map[string]*Entry),
+        spentBy: make(map[txn.OutPoint]string),
+        children: make(map[string]map[string]bool),
+    }
+}
+
+// SubmitResult is the outcome of the submission of a transaction
+type SubmitResult struct {
+    Txid string
+    Accepted bool
+    // Evicted are the transactions removed from the mempool because the submitted transaction replaced them (or their ancestors)
+    Evicted []string
+    Err error
+}
+
+// Submit validates the transaction and adds it to the mempool, replacing the transactions it conflicts with (and their descendants) if the replacement rules allow it. The txids of the evicted transactions are returned.
+func (mp *Mempool) Submit(tx *txn.Transaction) ([]string, error) {
+    return mp.submitAt(tx, time.Now())
+}
+
+// SubmitPackage submits the transactions of a package, parents first, as bitcoin core's submitpackage does: a transaction spent by a later one of the package is accepted below the minimum fee rate, as long as the package as a whole pays it. If a transaction is rejected, the ones of the package added before it are removed. The txids of the evicted transactions are returned.
+func (mp *Mempool) SubmitPackage(txns []*txn.Transaction) ([]string, error) {
+    return mp.submitPackageAt(txns, time.Now())
+}
+
+func (mp *Mempool) submitPackageAt(txns []*txn.Transaction, now time.Time) ([]string, error) {
+    spent := make(map[string]bool)
+    for _, t := range txns {
+        for _, in := range t.Vin {
+            spent[in.Txid] = true
+        }
+    }
+    evicted := make([]string, 0)
+    added := make([]string, 0, len(txns))
+    fee, vsize := 0, 0
+    for i, t := range txns {
+        txid := t.Txid()
+        e, err := mp.submit(t, now, spent[txid])
+        evicted = append(evicted, e...)
+        if err != nil {
+            mp.removeAdded(added)
+            return evicted, fmt.Errorf("transaction %d (%s): %w", i, txid, err)
+        }
+        added = append(added, txid)
+        entry := mp.entries[txid]
+        fee += entry.Fee
+        vsize += entry.VSize()
+    }
+    if minFeeRate := mp.minFeeRateAt(now); float64(fee) < minFeeRate*float64(vsize) {
+        mp.removeAdded(added)
+        return evicted, fmt.Errorf("%w: package pays %.3f < %.3f sat/vB", ErrMempoolMinFee, float64(fee)/float64(vsize), minFeeRate)
+    }
+    return evicted, nil
+}
+
+// removeAdded removes the transactions of a package that were added, if they are still in the mempool
+func (mp *Mempool) removeAdded(txids []string) {
+    for _, txid := range txids {
+        mp.Remove(txid)
+    }
+}
+
+func (mp *Mempool) submitAt(tx *txn.Transaction, now time.Time) ([]string, error) {
+    return mp.submit(tx, now, false)
+}
+
+// submit adds the transaction to the mempool, below the minimum fee rate if paidByChild is set
+func (mp *Mempool) submit(tx *txn.Transaction, now time.Time, paidByChild bool) ([]string, error) {
+    if tx.IsCoinbase() {
+        return nil, policy.ErrCoinbase
+    }
+    txid := tx.Txid()
+    if _, ok := mp.entries[txid]; ok {
+        return nil, ErrAlreadyInPool
+    }
+    if mp.UTXOSet != nil {
+        if err := utxo.ResolvePrevOuts(tx, mp.view()); err != nil {
+            return nil, err
+        }
+    }
+    if err := validation.ValidateTransaction(*tx); err != nil {
+        return nil, err
+    }
+    entry := &Entry{Tx: tx, Txid: txid, Fee: tx.GetFees(), Weight: tx.GetWeight(), Time: now}
+    if err := mp.checkPolicy(entry, now, paidByChild); err != nil {
+        return nil, err
+    }
+
+    evicted, err := mp.checkReplacement(entry, mp.trucSibling(entry))
+    if err != nil {
+        return nil, err
+    }
+    mp.removeEntries(evicted)
+    mp.addEntry(entry)
+
+    txids := make([]string, 0, len(evicted))
+    for _, e := range evicted {
+        txids = append(txids, e.Txid)
+    }
+    txids = append(txids, mp.Expire(now)...)
+    if mp.MaxSize > 0 {
+        txids = append(txids, mp.trimToSize(mp.MaxSize, now)...)
+    }
+    // the transaction itself can be the one with the lowest fee rate
+    if _, ok := mp.entries[txid]; !ok {
+        return txids, ErrMempoolFull
+    }
+    return txids, nil
+}
+
+// checkPolicy checks the fee rate of the entry against the minimum fee rate of the mempool, and the ephemeral dust and TRUC rules.
+// With paidByChild, the entry is submitted in a package along with a child paying for it (see SubmitPackage), and the minimum fee rate is not checked: the package checks it as a whole.
+func (mp *Mempool) checkPolicy(entry *Entry, now time.Time, paidByChild bool) error {
+    parents := mp.parents(entry.Tx)
+    if err := policy.CheckEphemeralDust(entry.Tx, entry.Fee); err != nil {
+        return err
+    }
+    if minFeeRate := mp.minFeeRateAt(now); !paidByChild && float64(entry.Fee) < minFeeRate*float64(entry.VSize()) {
+        return fmt.Errorf("%w: %.3f < %.3f sat/vB", ErrMempoolMinFee, entry.FeeRate(), minFeeRate)
+    }
+    if err := policy.CheckEphemeralSpends(entry.Tx, parents); err != nil {
+        return err
+    }
+    return policy.CheckTRUC(entry.Tx, entry.VSize(), parents, len(mp.ancestors(entry.Tx)))
+}
+
+// Replay submits the transactions one after the other, as if they arrived in that order
+func (mp *Mempool) Replay(txns []*txn.Transaction) []SubmitResult {
+    results := make([]SubmitResult, 0, len(txns))
+    for _, t := range txns {
+        evicted, err := mp.Submit(t)
+        results = append(results, SubmitResult{Txid: t.Txid(), Accepted: err == nil, Evicted: evicted, Err: err})
+    }
+    return results
+}
+
+// checkReplacement returns the entries that have to be evicted for the entry to be added: the direct conflicts and all their descendants. sibling, if not nil, is evicted as if it was a direct conflict.
+func (mp *Mempool) checkReplacement(entry *Entry, sibling *Entry) ([]*Entry, error) {
+    directSet := make(map[string]*Entry)
+    for _, in := range entry.Tx.Vin {
+        if spender, ok := mp.spentBy[in.OutPoint()]; ok {
+            directSet[spender] = mp.entries[spender]
+        }
+    }
+    if sibling != nil {
+        directSet[sibling.Txid] = sibling
+    }
+    if len(directSet) == 0 {
+        return nil, nil
+    }
+    direct := make([]*Entry, 0, len(directSet))
+    for _, e := range mp.order {
+        if _, ok := directSet[e.Txid]; ok {
+            direct = append(direct, e)
+        }
+    }
+
+    evictedSet := make(map[string]bool)
+    evicted := make([]*Entry, 0)
+    for _, d := range direct {
+        for _, e := range append([]*Entry{d}, mp.descendants(d.Txid)...) {
+            if !evictedSet[e.Txid] {
+                evictedSet[e.Txid] = true
+                evicted = append(evicted, e)
+            }
+        }
+    }
+
+    // the replacement cannot spend outputs of the transactions it evicts, and (rule 2) cannot spend unconfirmed outputs that none of the direct conflicts spent
+    conflictParents := make(map[string]bool)
+    for _, d := range direct {
+        for _, in := range d.Tx.Vin {
+            conflictParents[in.Txid] = true
+        }
+    }
+    for _, in := range entry.Tx.Vin {
+        if evictedSet[in.Txid] {
+            return nil, ErrSpendsConflict
+        }
+        if _, unconfirmed := mp.entries[in.Txid]; unconfirmed && !conflictParents[in.Txid] {
+            return nil, ErrNewUnconfirmedInput
+        }
+    }
+
+    err := policy.CheckReplacement(entry.Fee, entry.Weight, replacedTxs(direct), replacedTxs(evicted), mp.FullRBF)
+    if err != nil {
+        return nil, fmt.Errorf("cannot replace %d transactions: %w", len(direct), err)
+    }
+    return evicted, nil
+}
+
+func replacedTxs(entries []*Entry) []policy.ReplacedTx {
+    replaced := make([]policy.ReplacedTx, 0, len(entries))
+    for _, e := range entries {
+        replaced = append(replaced, policy.ReplacedTx{Fee: e.Fee, Weight: e.Weight, Signals: policy.SignalsReplacement(e.Tx)})
+    }
+    return replaced
+}
+
+func (mp *Mempool) addEntry(e *Entry) {
+    mp.entries[e.Txid] = e
+    mp.order = append(mp.order, e)
+    mp.size += e.VSize()
+    if mp.outputs != nil {
+        mp.outputs.AddTxOutputs(e.Tx)
+    }
+    for _, in := range e.Tx.Vin {
+        mp.spentBy[in.OutPoint()] = e.Txid
+        if _, ok := mp.children[in.Txid]; !ok {
+            mp.children[in.Txid] = make(map[string]bool)
+        }
+        mp.children[in.Txid][e.Txid] = true
+    }
+    mp.addDescendantTotals(e)
+    mp.version++
+}
+
+// addDescendantTotals counts the entry just added in the descendant totals of its ancestors. Without a utxo set, children can enter the mempool before their parents, in which case those children are counted too, unless an ancestor already had them as descendants through another transaction.
+func (mp *Mempool) addDescendantTotals(e *Entry) {
+    descendants := mp.descendants(e.Txid)
+    e.descendantFee, e.descendantSize = e.Fee, e.VSize()
+    for _, d := range descendants {
+        e.descendantFee += d.Fee
+        e.descendantSize += d.VSize()
+    }
+    for _, a := range mp.ancestors(e.Tx) {
+        if len(descendants) == 0 {
+            a.descendantFee += e.Fee
+            a.descendantSize += e.VSize()
+            continue
+        }
+        counted := make(map[string]bool)
+        for _, d := range mp.descendantsAvoiding(a.Txid, e.Txid) {
+            counted[d.Txid] = true
+        }
+        for _, d := range append([]*Entry{e}, descendants...) {
+            if !counted[d.Txid] {
+                a.descendantFee += d.Fee
+                a.descendantSize += d.VSize()
+            }
+        }
+    }
+}
+
+// removeEntries removes the entries from the mempool, taking them out of the descendant totals of their ancestors that stay. The descendants of an entry are expected to be removed along with it, or to have no other in-mempool ancestor (the transactions of a block come after their parents).
+func (mp *Mempool) removeEntries(entries []*Entry) {
+    removed := make(map[string]bool, len(entries))
+    for _, e := range entries {
+        removed[e.Txid] = true
+    }
+    for _, e := range entries {
+        if _, ok := mp.entries[e.Txid]; !ok {
+            continue
+        }
+        for _, a := range mp.ancestors(e.Tx) {
+            if !removed[a.Txid] {
+                a.descendantFee -= e.Fee
+                a.descendantSize -= e.VSize()
+            }
+        }
+    }
+    for _, e := range entries {
+        mp.removeEntry(e)
+    }
+}
+
+func (mp *Mempool) removeEntry(e *Entry) {
+    if _, ok := mp.entries[e.Txid]; !ok {
+        return
+    }
+    delete(mp.entries, e.Txid)
+    mp.size -= e.VSize()
+    if mp.outputs != nil {
+        for i := range e.Tx.Vout {
+            mp.outputs.RemoveUTXO(txn.NewOutPoint(e.Txid, i))
+        }
+    }
+    for i, o := range mp.order {
+        if o == e {
+            mp.order = append(mp.order[:i], mp.order[i+1:]...)
+            break
+        }
+    }
+    for _, in := range e.Tx.Vin {
+        if mp.spentBy[in.OutPoint()] == e.Txid {
+            delete(mp.spentBy, in.OutPoint())
+        }
+        delete(mp.children[in.Txid], e.Txid)
+        if len(mp.children[in.Txid]) == 0 {
+            delete(mp.children, in.Txid)
+        }
+    }
+    mp.version++
+}
+
+// Remove removes the transaction and all of its descendants from the mempool, and returns the txids of the removed transactions
+func (mp *Mempool) Remove(txid string) []string {
+    e, ok := mp.entries[txid]
+    if !ok {
+        return nil
+    }
+    entries := append([]*Entry{e}, mp.descendants(txid)...)
+    mp.removeEntries(entries)
+    removed := make([]string, 0, len(entries))
+    for _, r := range entries {
+        removed = append(removed, r.Txid)
+    }
+    return removed
+}
+
+// descendants returns the mempool transactions spending outputs of the transaction, the ones spending their outputs and so on
+func (mp *Mempool) descendants(txid string) []*Entry {
+    return mp.descendantsAvoiding(txid, "")
+}
+
+// descendantsAvoiding returns the descendants of the transaction that are not reached only through the transaction avoid
+func (mp *Mempool) descendantsAvoiding(txid string, avoid string) []*Entry {
+    visited := map[string]bool{txid: true, avoid: true}
+    result := make([]*Entry, 0)
+    queue := []string{txid}
+    for len(queue) > 0 {
+        cur := queue[0]
+        queue = queue[1:]
+        for child := range mp.children[cur] {
+            if visited[child] {
+                continue
+            }
+            visited[child] = true
+            result = append(result, mp.entries[child])
+            queue = append(queue, child)
+        }
+    }
+    return result
+}
+
+// parents returns the mempool transactions the transaction spends outputs of
+func (mp *Mempool) parents(tx *txn.Transaction) []*txn.Transaction {
+    seen := make(map[string]bool)
+    parents := make([]*txn.Transaction, 0)
+    for _, in := range tx.Vin {
+        if e, ok := mp.entries[in.Txid]; ok && !seen[in.Txid] {
+            seen[in.Txid] = true
+            parents = append(parents, e.Tx)
+        }
+    }
+    return parents
+}
+
+// ancestors returns the mempool transactions the transaction depends on: its parents, their parents and so on
+func (mp *Mempool) ancestors(tx *txn.Transaction) []*Entry {
+    visited := make(map[string]bool)
+    result := make([]*Entry, 0)
+    queue := []*txn.Transaction{tx}
+    for len(queue) > 0 {
+        cur := queue[0]
+        queue = queue[1:]
+        for _, in := range cur.Vin {
+            e, ok := mp.entries[in.Txid]
+            if !ok || visited[in.Txid] {
+                continue
+            }
+            visited[in.Txid] = true
+            result = append(result, e)
+            queue = append(queue, e.Tx)
+        }
+    }
+    return result
+}
+
+// trucSibling returns the child a TRUC transaction evicts (sibling eviction): the other child of its TRUC parent, which cannot have more than one. nil is returned if there is none, or if the transaction replaces it anyway.
+func (mp *Mempool) trucSibling(entry *Entry) *Entry {
+    parents := mp.parents(entry.Tx)
+    if !policy.IsTRUC(entry.Tx) || len(parents) != 1 {
+        return nil
+    }
+    children := mp.children[parents[0].Txid()]
+    // the parent along with its children and the transaction
+    if len(children)+2 <= policy.TRUCDescendantLimit {
+        return nil
+    }
+    for child := range children {
+        replaced := false
+        for _, in := range entry.Tx.Vin {
+            replaced = replaced || mp.spentBy[in.OutPoint()] == child
+        }
+        if !replaced {
+            return mp.entries[child]
+        }
+    }
+    return nil
+}
+
+// view returns the utxo set on top of which the outputs of the mempool transactions are available
+func (mp *Mempool) view() utxo.UTXOView {
+    if mp.outputs == nil {
+        mp.outputs = utxo.NewMemoryView(mp.UTXOSet)
+        for _, e := range mp.order {
+            mp.outputs.AddTxOutputs(e.Tx)
+        }
+    }
+    return mp.outputs
+}
+
+// Lookup returns the mempool entry of the transaction with the given txid
+func (mp *Mempool) Lookup(txid string) (*Entry, bool) {
+    e, ok := mp.entries[txid]
+    return e, ok
+}
+
+func (mp *Mempool) Len() int {
+    return len(mp.entries)
+}
+
+// Transactions returns the transactions in the mempool in the order they were added
+func (mp *Mempool) Transactions() []*txn.Transaction {
+    txns := make([]*txn.Transaction, 0, len(mp.order))
+    for _, e := range mp.order {
+        txns = append(txns, e.Tx)
+    }
+    return txns
+}
+
+// Size returns the total virtual size of the mempool transactions
+func (mp *Mempool) Size() int {
+    return mp.size
+}
+
+// Version changes every time a transaction is added to or removed from the mempool
+func (mp *Mempool) Version() int {
+    return mp.version
+}
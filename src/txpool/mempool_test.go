@@ -0,0 +1,232 @@
+package txpool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/humblenginr/btc-miner/internal/txtest"
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// a chain of three mainnet transactions where the child pays for its parents
+const (
+    chainParent = "91a58688c0cf2a504866a07e463bf3034a811a4b7201c7d309a26ad8ac382889"
+    chainMiddle = "0404b9545838693dac96a8e16916cb7fc5bbbc44b9c8fa42ee34a41648b735a3"
+    chainChild = "d7361a396244b0e4706a349edc687b57e2cb3d731a375cc733c07f14f016b3c3"
+)
+
+func readTx(t *testing.T, name string) *txn.Transaction {
+    b, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var tx txn.Transaction
+    if err := json.Unmarshal(b, &tx); err != nil {
+        t.Fatal(err)
+    }
+    return &tx
+}
+
+// fakeTx returns an unsigned transaction paying fee, spending the first output of each parent, or a confirmed output of 100000 sats if it has none
+func fakeTx(fee int, signals bool, parents ...*txn.Transaction) *txn.Transaction {
+    id := txtest.NextID()
+    tx := &txn.Transaction{Version: 2, Locktime: uint32(id)}
+    sequence := 0xffffffff
+    if signals {
+        sequence = policy.MaxBIP125RBFSequence
+    }
+    value := 0
+    if len(parents) == 0 {
+        prevOut := txn.Vout{ScriptPubKey: "51", Value: 100000}
+        tx.Vin = append(tx.Vin, txn.Vin{Txid: fmt.Sprintf("%064x", id), PrevOut: prevOut, Sequence: sequence})
+        value = prevOut.Value
+    }
+    for _, p := range parents {
+        tx.Vin = append(tx.Vin, txn.Vin{Txid: p.Txid(), Vout: 0, PrevOut: p.Vout[0], Sequence: sequence})
+        value += p.Vout[0].Value
+    }
+    tx.Vout = []txn.Vout{{ScriptPubKey: "51", Value: value - fee}}
+    return tx
+}
+
+func newEntry(tx *txn.Transaction, now time.Time) *Entry {
+    return &Entry{Tx: tx, Txid: tx.Txid(), Fee: tx.GetFees(), Weight: tx.GetWeight(), Time: now}
+}
+
+// addFake adds the transactions to the mempool without validating them
+func addFake(mp *Mempool, txns ...*txn.Transaction) {
+    for _, t := range txns {
+        mp.addEntry(newEntry(t, time.Now()))
+    }
+}
+
+func TestSubmit(t *testing.T) {
+    parent, middle, child := readTx(t, chainParent), readTx(t, chainMiddle), readTx(t, chainChild)
+    mp := New(false)
+    for _, tx := range []*txn.Transaction{parent, middle, child} {
+        if evicted, err := mp.Submit(tx); err != nil || len(evicted) != 0 {
+            t.Fatalf("Submit(%s) = %v, %v", tx.Txid(), evicted, err)
+        }
+    }
+    if mp.Len() != 3 || mp.Size() != policy.GetVSize(parent.GetWeight())+policy.GetVSize(middle.GetWeight())+policy.GetVSize(child.GetWeight()) {
+        t.Errorf("mempool has %d transactions of size %d", mp.Len(), mp.Size())
+    }
+    if _, err := mp.Submit(middle); !errors.Is(err, ErrAlreadyInPool) {
+        t.Errorf("Submit() of a mempool transaction returned %v", err)
+    }
+    if d := mp.descendants(parent.Txid()); len(d) != 2 {
+        t.Errorf("parent has %d descendants, want 2", len(d))
+    }
+    if a := mp.ancestors(child); len(a) != 2 {
+        t.Errorf("child has %d ancestors, want 2", len(a))
+    }
+
+    version := mp.Version()
+    if removed := mp.Remove(middle.Txid()); len(removed) != 2 {
+        t.Errorf("Remove() = %v, want the middle transaction and the child", removed)
+    }
+    if mp.Len() != 1 || mp.Version() == version || len(mp.children[parent.Txid()]) != 0 || len(mp.spentBy) != len(parent.Vin) {
+        t.Errorf("mempool not cleaned up after Remove(): %d transactions, %d children, %d spent outputs", mp.Len(), len(mp.children[parent.Txid()]), len(mp.spentBy))
+    }
+}
+
+func TestSubmitRejects(t *testing.T) {
+    parent := readTx(t, chainParent)
+    invalid := readTx(t, chainMiddle)
+    invalid.Vout[0].Value++
+    tests := []struct {
+        name string
+        tx *txn.Transaction
+        setup func(mp *Mempool)
+        err error
+    }{
+        {"coinbase", &txn.Transaction{Vin: []txn.Vin{{IsCoinbase: true}}}, nil, policy.ErrCoinbase},
+        {"min fee", parent, func(mp *Mempool) { mp.MinRelayFeeRate = 1000 }, ErrMempoolMinFee},
+        {"full", parent, func(mp *Mempool) { mp.MaxSize = 10 }, ErrMempoolFull},
+    }
+    for _, test := range tests {
+        mp := New(false)
+        if test.setup != nil {
+            test.setup(mp)
+        }
+        if _, err := mp.Submit(test.tx); !errors.Is(err, test.err) {
+            t.Errorf("%s: Submit() = %v, want %v", test.name, err, test.err)
+        }
+        if mp.Len() != 0 {
+            t.Errorf("%s: transaction added to the mempool", test.name)
+        }
+    }
+    if _, err := New(false).Submit(invalid); err == nil {
+        t.Error("invalid transaction accepted")
+    }
+}
+
+func TestSubmitPackage(t *testing.T) {
+    parent, middle, child := readTx(t, chainParent), readTx(t, chainMiddle), readTx(t, chainChild)
+    invalid := readTx(t, chainChild)
+    invalid.Vout[0].Value++
+    // alone, the parent pays 9.81 sat/vB, along with the middle transaction 12.89 and with the child too 13.93
+    mp := New(false)
+    mp.MinRelayFeeRate = 12
+    if _, err := mp.Submit(parent); !errors.Is(err, ErrMempoolMinFee) {
+        t.Errorf("Submit() of the parent alone = %v, want %v", err, ErrMempoolMinFee)
+    }
+
+    tests := []struct {
+        name string
+        txns []*txn.Transaction
+        minFeeRate float64
+        accepted bool
+        err error
+    }{
+        {"middle pays for parent", []*txn.Transaction{parent, middle}, 12, true, nil},
+        {"child pays for parents", []*txn.Transaction{parent, middle, child}, 13.5, true, nil},
+        {"package below the min fee", []*txn.Transaction{parent, middle}, 13.5, false, ErrMempoolMinFee},
+        // the child is not paid for by a later transaction of the package
+        {"child below the min fee", []*txn.Transaction{parent, middle, child}, 16.5, false, ErrMempoolMinFee},
+        {"invalid child", []*txn.Transaction{parent, middle, invalid}, 1, false, nil},
+    }
+    for _, test := range tests {
+        mp := New(false)
+        mp.MinRelayFeeRate = test.minFeeRate
+        _, err := mp.SubmitPackage(test.txns)
+        if (err == nil) != test.accepted || test.err != nil && !errors.Is(err, test.err) {
+            t.Errorf("%s: SubmitPackage() = %v, want accepted %v, %v", test.name, err, test.accepted, test.err)
+        }
+        // a rejected package leaves nothing behind
+        want := 0
+        if test.accepted {
+            want = len(test.txns)
+        }
+        if mp.Len() != want {
+            t.Errorf("%s: mempool has %d transactions, want %d", test.name, mp.Len(), want)
+        }
+    }
+}
+
+func TestCheckReplacement(t *testing.T) {
+    signaling := fakeTx(1000, true)
+    child := fakeTx(1000, false, signaling)
+    final := fakeTx(1000, false)
+    unconfirmed := fakeTx(1000, false)
+
+    spendsNew := txtest.Respend(signaling, 10000)
+    spendsNew.Vin = append(spendsNew.Vin, txn.Vin{Txid: unconfirmed.Txid(), Vout: 0, PrevOut: unconfirmed.Vout[0]})
+    spendsNew.Vout[0].Value += unconfirmed.Vout[0].Value
+    spendsConflict := txtest.Respend(signaling, 10000)
+    spendsConflict.Vin = append(spendsConflict.Vin, txn.Vin{Txid: child.Txid(), Vout: 0, PrevOut: child.Vout[0]})
+    spendsConflict.Vout[0].Value += child.Vout[0].Value
+
+    tests := []struct {
+        name string
+        tx *txn.Transaction
+        fullRBF bool
+        evicted int
+        err error
+    }{
+        {"no conflict", fakeTx(1000, false), false, 0, nil},
+        {"replaces with descendants", txtest.Respend(signaling, 5000), false, 2, nil},
+        {"not signaling", txtest.Respend(final, 5000), false, 0, policy.ErrNotReplaceable},
+        {"full rbf", txtest.Respend(final, 5000), true, 1, nil},
+        // pays more than the conflict but not more than it and its child
+        {"pays less than descendants", txtest.Respend(signaling, 1500), false, 0, policy.ErrInsufficientFee},
+        {"lower fee rate", txtest.Respend(signaling, 900), false, 0, policy.ErrInsufficientFeeRate},
+        // pays more, but not the incremental relay fee for its own size
+        {"relay fee", txtest.Respend(signaling, 2001), false, 0, policy.ErrInsufficientFee},
+        {"new unconfirmed input", spendsNew, false, 0, ErrNewUnconfirmedInput},
+        {"spends conflict", spendsConflict, false, 0, ErrSpendsConflict},
+    }
+    for _, test := range tests {
+        mp := New(test.fullRBF)
+        addFake(mp, signaling, child, final, unconfirmed)
+        evicted, err := mp.checkReplacement(newEntry(test.tx, time.Now()), nil)
+        if !errors.Is(err, test.err) || len(evicted) != test.evicted {
+            t.Errorf("%s: checkReplacement() = %d evicted, %v, want %d, %v", test.name, len(evicted), err, test.evicted, test.err)
+        }
+    }
+}
+
+func TestReplay(t *testing.T) {
+    parent, middle, child := readTx(t, chainParent), readTx(t, chainMiddle), readTx(t, chainChild)
+    mp := New(false)
+    results := mp.Replay([]*txn.Transaction{parent, middle, parent, child})
+    accepted := []bool{true, true, false, true}
+    for i, r := range results {
+        if r.Accepted != accepted[i] {
+            t.Errorf("%d: %s accepted %v (%v)", i, r.Txid, r.Accepted, r.Err)
+        }
+    }
+    if !errors.Is(results[2].Err, ErrAlreadyInPool) {
+        t.Errorf("resubmission returned %v", results[2].Err)
+    }
+    txns := mp.Transactions()
+    if len(txns) != 3 || txns[0] != parent || txns[2] != child {
+        t.Error("transactions are not in the order they were added")
+    }
+}
@@ -0,0 +1,92 @@
+package txpool
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/humblenginr/btc-miner/internal/txtest"
+	"github.com/humblenginr/btc-miner/policy"
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// spendOutput returns a TRUC transaction spending the given output of parent and paying fee
+func spendOutput(parent *txn.Transaction, vout int, fee int) *txn.Transaction {
+    tx := &txn.Transaction{Version: policy.TRUCVersion, Locktime: uint32(txtest.NextID())}
+    tx.Vin = []txn.Vin{{Txid: parent.Txid(), Vout: vout, PrevOut: parent.Vout[vout], Sequence: 0xffffffff}}
+    tx.Vout = []txn.Vout{{ScriptPubKey: "51", Value: parent.Vout[vout].Value - fee}}
+    return tx
+}
+
+func TestCheckPolicy(t *testing.T) {
+    trucParent := txtest.AsTRUC(fakeTx(1000, false))
+    dustParent := txtest.WithDust(fakeTx(0, false))
+    plainParent := fakeTx(1000, false)
+    tests := []struct {
+        name string
+        tx *txn.Transaction
+        // submitted in a package along with a child paying for it
+        paidByChild bool
+        err error
+    }{
+        {"pays the min fee", fakeTx(1000, false), false, nil},
+        {"below the min fee", fakeTx(0, false), false, ErrMempoolMinFee},
+        {"paid by child", fakeTx(0, false), true, nil},
+        {"lone ephemeral dust", txtest.WithDust(fakeTx(0, false)), false, ErrMempoolMinFee},
+        {"ephemeral dust", txtest.WithDust(fakeTx(0, false)), true, nil},
+        {"dust with fee", txtest.WithDust(fakeTx(1000, false)), true, policy.ErrDustWithFee},
+        {"too many dust outputs", txtest.WithDust(txtest.WithDust(fakeTx(0, false))), true, policy.ErrTooManyDust},
+        {"dust not spent", fakeTx(1000, false, dustParent), false, policy.ErrEphemeralDustUnspent},
+        {"lone TRUC parent", txtest.AsTRUC(fakeTx(0, false)), false, ErrMempoolMinFee},
+        {"TRUC parent", txtest.AsTRUC(fakeTx(0, false)), true, nil},
+        {"TRUC child below the min fee", txtest.AsTRUC(fakeTx(0, false, trucParent)), false, ErrMempoolMinFee},
+        {"TRUC child", txtest.AsTRUC(fakeTx(1000, false, trucParent)), false, nil},
+        {"non TRUC spends TRUC", fakeTx(1000, false, trucParent), false, policy.ErrNonTRUCSpendsTRUC},
+        {"TRUC spends non TRUC", txtest.AsTRUC(fakeTx(1000, false, plainParent)), false, policy.ErrTRUCSpendsNonTRUC},
+    }
+    for _, test := range tests {
+        mp := New(false)
+        addFake(mp, trucParent, dustParent, plainParent)
+        if err := mp.checkPolicy(newEntry(test.tx, time.Now()), time.Now(), test.paidByChild); !errors.Is(err, test.err) {
+            t.Errorf("%s: checkPolicy() = %v, want %v", test.name, err, test.err)
+        }
+    }
+}
+
+func TestTRUCSiblingEviction(t *testing.T) {
+    parent := txtest.AsTRUC(fakeTx(1000, false))
+    parent.Vout = append(parent.Vout, txn.Vout{ScriptPubKey: "51", Value: 10000})
+    parent.Vout[0].Value -= 10000
+    child := spendOutput(parent, 0, 1000)
+    nonTRUC := fakeTx(1000, false)
+
+    tests := []struct {
+        name string
+        mempool []*txn.Transaction
+        tx *txn.Transaction
+        sibling *txn.Transaction
+        evicted int
+        err error
+    }{
+        {"only child", []*txn.Transaction{parent}, spendOutput(parent, 1, 1000), nil, 0, nil},
+        {"evicts its sibling", []*txn.Transaction{parent, child}, spendOutput(parent, 1, 5000), child, 1, nil},
+        {"sibling pays more", []*txn.Transaction{parent, child}, spendOutput(parent, 1, 500), child, 0, policy.ErrInsufficientFeeRate},
+        // spending the same output, the child is a direct conflict rather than a sibling
+        {"replaces its sibling", []*txn.Transaction{parent, child}, spendOutput(parent, 0, 5000), nil, 1, nil},
+        {"not TRUC", []*txn.Transaction{parent, child}, fakeTx(5000, false, nonTRUC), nil, 0, nil},
+    }
+    for _, test := range tests {
+        mp := New(false)
+        addFake(mp, test.mempool...)
+        entry := newEntry(test.tx, time.Now())
+        sibling := mp.trucSibling(entry)
+        if test.sibling == nil && sibling != nil || test.sibling != nil && (sibling == nil || sibling.Tx != test.sibling) {
+            t.Errorf("%s: trucSibling() = %v, want %v", test.name, sibling, test.sibling)
+            continue
+        }
+        evicted, err := mp.checkReplacement(entry, sibling)
+        if !errors.Is(err, test.err) || len(evicted) != test.evicted {
+            t.Errorf("%s: checkReplacement() = %d evicted, %v, want %d, %v", test.name, len(evicted), err, test.evicted, test.err)
+        }
+    }
+}
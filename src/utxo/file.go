@@ -0,0 +1,87 @@
+package utxo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// record is a single line of a utxo file. The output fields are the same as the ones used for the outputs in the mempool files, eg:
+// {"txid": "...", "vout": 1, "scriptpubkey": "0014...", "scriptpubkey_type": "v0_p2wpkh", "value": 436600}
+type record struct {
+    Txid string `json:"txid"`
+    Index int `json:"vout"`
+    txn.Vout
+}
+
+type location struct {
+    offset int64
+    length int
+}
+
+// FileView is a utxo set backed by a file with one JSON record per line. Only the position of each record is kept in memory, the output itself is read from the file when it is looked up.
+type FileView struct {
+    f *os.File
+    index map[txn.OutPoint]location
+}
+
+// OpenFileView indexes the utxo file at path. The file is kept open until Close is called.
+func OpenFileView(path string) (*FileView, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    v := &FileView{f: f, index: make(map[txn.OutPoint]location)}
+    r := bufio.NewReader(f)
+    var offset int64
+    for lineNo := 1; ; lineNo++ {
+        line, err := r.ReadBytes('\n')
+        // blank lines, such as a trailing one, are skipped
+        if len(bytes.TrimSpace(line)) > 0 {
+            var rec record
+            if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil {
+                f.Close()
+                return nil, fmt.Errorf("%s:%d: %w", path, lineNo, jsonErr)
+            }
+            v.index[txn.NewOutPoint(rec.Txid, rec.Index)] = location{offset, len(line)}
+        }
+        offset += int64(len(line))
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            f.Close()
+            return nil, err
+        }
+    }
+    return v, nil
+}
+
+func (v *FileView) LookupUTXO(op txn.OutPoint) (txn.Vout, error) {
+    loc, ok := v.index[op]
+    if !ok {
+        return txn.Vout{}, ErrNotFound
+    }
+    buf := make([]byte, loc.length)
+    if _, err := v.f.ReadAt(buf, loc.offset); err != nil {
+        return txn.Vout{}, err
+    }
+    var rec record
+    if err := json.Unmarshal(buf, &rec); err != nil {
+        return txn.Vout{}, err
+    }
+    return rec.Vout, nil
+}
+
+func (v *FileView) Len() int {
+    return len(v.index)
+}
+
+func (v *FileView) Close() error {
+    return v.f.Close()
+}
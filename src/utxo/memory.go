@@ -0,0 +1,91 @@
+package utxo
+
+import (
+	"encoding/json"
+	"io"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+// MemoryView is an in-memory utxo set. It can be layered on top of another view, in which case outputs not found in memory are looked up in the base view, and outputs spent in memory are hidden from it.
+type MemoryView struct {
+    base UTXOView
+    entries map[txn.OutPoint]txn.Vout
+    spent map[txn.OutPoint]bool
+}
+
+// NewMemoryView returns an empty view on top of base. base can be nil.
+func NewMemoryView(base UTXOView) *MemoryView {
+    return &MemoryView{base: base, entries: make(map[txn.OutPoint]txn.Vout), spent: make(map[txn.OutPoint]bool)}
+}
+
+func (v *MemoryView) LookupUTXO(op txn.OutPoint) (txn.Vout, error) {
+    if v.spent[op] {
+        return txn.Vout{}, ErrSpent
+    }
+    if out, ok := v.entries[op]; ok {
+        return out, nil
+    }
+    if v.base != nil {
+        return v.base.LookupUTXO(op)
+    }
+    return txn.Vout{}, ErrNotFound
+}
+
+func (v *MemoryView) AddUTXO(op txn.OutPoint, out txn.Vout) {
+    delete(v.spent, op)
+    v.entries[op] = out
+}
+
+// RemoveUTXO forgets an output added to the view, without marking it as spent
+func (v *MemoryView) RemoveUTXO(op txn.OutPoint) {
+    delete(v.entries, op)
+}
+
+// SpendUTXO marks the output as spent. An error is returned if the output cannot be found or is already spent.
+func (v *MemoryView) SpendUTXO(op txn.OutPoint) error {
+    if _, err := v.LookupUTXO(op); err != nil {
+        return err
+    }
+    delete(v.entries, op)
+    v.spent[op] = true
+    return nil
+}
+
+// AddTxOutputs adds all the outputs of the transaction to the view. This is used to make outputs of unconfirmed (mempool) transactions available to their children.
+func (v *MemoryView) AddTxOutputs(tx *txn.Transaction) {
+    txid := tx.Txid()
+    for i, out := range tx.Vout {
+        v.AddUTXO(txn.NewOutPoint(txid, i), out)
+    }
+}
+
+// ApplyTx spends the inputs of the transaction and adds its outputs to the view. Nothing is changed if any of the inputs is missing or spent.
+func (v *MemoryView) ApplyTx(tx *txn.Transaction) error {
+    for _, in := range tx.Vin {
+        if in.IsCoinbase {
+            continue
+        }
+        if _, err := v.LookupUTXO(in.OutPoint()); err != nil {
+            return err
+        }
+    }
+    for _, in := range tx.Vin {
+        if !in.IsCoinbase {
+            v.SpendUTXO(in.OutPoint())
+        }
+    }
+    v.AddTxOutputs(tx)
+    return nil
+}
+
+// WriteJSONLines writes the outputs held in memory in the format read by FileView
+func (v *MemoryView) WriteJSONLines(w io.Writer) error {
+    enc := json.NewEncoder(w)
+    for op, out := range v.entries {
+        if err := enc.Encode(record{Txid: op.Txid, Index: op.Vout, Vout: out}); err != nil {
+            return err
+        }
+    }
+    return nil
+}
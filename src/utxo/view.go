@@ -0,0 +1,37 @@
+package utxo
+
+import (
+	"errors"
+	"fmt"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+var (
+    ErrNotFound = errors.New("output not found in the utxo set")
+    ErrSpent = errors.New("output is already spent")
+)
+
+// UTXOView resolves outpoints to the unspent outputs they refer to.
+type UTXOView interface {
+    // LookupUTXO returns the unspent output at op. ErrNotFound is returned if the output does not exist and ErrSpent if it was spent.
+    LookupUTXO(op txn.OutPoint) (txn.Vout, error)
+}
+
+// ResolvePrevOuts replaces the prevout of every input of the transaction with the output found in the view, so that the transaction no longer depends on the prevouts given along with it. Coinbase inputs are left untouched.
+// An error is returned for the first input the view cannot resolve. A view only reports ErrSpent for outputs spent through it (MemoryView.SpendUTXO), a FileView holds unspent outputs only and reports an output spent on chain as ErrNotFound.
+// Nothing is spent here: two transactions spending the same output both resolve, telling them apart is left to the caller (the mempool loader treats them as conflicts).
+func ResolvePrevOuts(tx *txn.Transaction, view UTXOView) error {
+    for i := range tx.Vin {
+        in := &tx.Vin[i]
+        if in.IsCoinbase {
+            continue
+        }
+        out, err := view.LookupUTXO(in.OutPoint())
+        if err != nil {
+            return fmt.Errorf("input %d (%s): %w", i, in.OutPoint(), err)
+        }
+        in.PrevOut = out
+    }
+    return nil
+}
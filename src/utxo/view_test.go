@@ -0,0 +1,162 @@
+package utxo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	txn "github.com/humblenginr/btc-miner/transaction"
+)
+
+var (
+    confirmedTxid = "aa00000000000000000000000000000000000000000000000000000000000000"
+    confirmedOut = txn.Vout{ScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6", ScriptPubKeyType: txn.P2WPKH, Value: 5000}
+)
+
+func baseView() *MemoryView {
+    v := NewMemoryView(nil)
+    v.AddUTXO(txn.NewOutPoint(confirmedTxid, 0), confirmedOut)
+    return v
+}
+
+func spendingTx(ops ...txn.OutPoint) *txn.Transaction {
+    tx := &txn.Transaction{Version: 2}
+    for _, op := range ops {
+        tx.Vin = append(tx.Vin, txn.Vin{Txid: op.Txid, Vout: op.Vout, Sequence: 0xffffffff})
+    }
+    tx.Vout = []txn.Vout{{ScriptPubKey: "51", Value: 1000}}
+    return tx
+}
+
+func TestMemoryViewLayers(t *testing.T) {
+    base := baseView()
+    v := NewMemoryView(base)
+    op := txn.NewOutPoint(confirmedTxid, 0)
+    if out, err := v.LookupUTXO(op); err != nil || out.Value != 5000 {
+        t.Fatalf("LookupUTXO through the base = %v, %v", out, err)
+    }
+    if err := v.SpendUTXO(op); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := v.LookupUTXO(op); !errors.Is(err, ErrSpent) {
+        t.Errorf("LookupUTXO after SpendUTXO error = %v, want ErrSpent", err)
+    }
+    // the base view is not changed
+    if _, err := base.LookupUTXO(op); err != nil {
+        t.Errorf("base LookupUTXO = %v", err)
+    }
+    if err := v.SpendUTXO(op); !errors.Is(err, ErrSpent) {
+        t.Errorf("double SpendUTXO error = %v, want ErrSpent", err)
+    }
+    if _, err := v.LookupUTXO(txn.NewOutPoint(confirmedTxid, 1)); !errors.Is(err, ErrNotFound) {
+        t.Errorf("LookupUTXO of a missing output error = %v, want ErrNotFound", err)
+    }
+}
+
+func TestApplyTx(t *testing.T) {
+    v := baseView()
+    missing := txn.NewOutPoint(confirmedTxid, 7)
+    tx := spendingTx(txn.NewOutPoint(confirmedTxid, 0), missing)
+    if err := v.ApplyTx(tx); !errors.Is(err, ErrNotFound) {
+        t.Fatalf("ApplyTx with a missing input error = %v", err)
+    }
+    // nothing is spent when an input is missing
+    if _, err := v.LookupUTXO(txn.NewOutPoint(confirmedTxid, 0)); err != nil {
+        t.Errorf("input spent by a failed ApplyTx: %v", err)
+    }
+
+    tx = spendingTx(txn.NewOutPoint(confirmedTxid, 0))
+    if err := v.ApplyTx(tx); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := v.LookupUTXO(txn.NewOutPoint(tx.Txid(), 0)); err != nil {
+        t.Errorf("output of the applied transaction: %v", err)
+    }
+    if err := v.ApplyTx(spendingTx(txn.NewOutPoint(confirmedTxid, 0))); !errors.Is(err, ErrSpent) {
+        t.Errorf("ApplyTx of a double spend error = %v, want ErrSpent", err)
+    }
+}
+
+func TestResolvePrevOuts(t *testing.T) {
+    tests := []struct {
+        name string
+        tx *txn.Transaction
+        wantErr error
+    }{
+        {"confirmed input", spendingTx(txn.NewOutPoint(confirmedTxid, 0)), nil},
+        {"missing input", spendingTx(txn.NewOutPoint(confirmedTxid, 0), txn.NewOutPoint(confirmedTxid, 3)), ErrNotFound},
+    }
+    for _, test := range tests {
+        err := ResolvePrevOuts(test.tx, baseView())
+        if !errors.Is(err, test.wantErr) {
+            t.Errorf("%s: ResolvePrevOuts() = %v, want %v", test.name, err, test.wantErr)
+        }
+        if err == nil && test.tx.Vin[0].PrevOut != confirmedOut {
+            t.Errorf("%s: prevout = %v", test.name, test.tx.Vin[0].PrevOut)
+        }
+    }
+
+    // two transactions spending the same output both resolve
+    view := baseView()
+    for i := 0; i < 2; i++ {
+        if err := ResolvePrevOuts(spendingTx(txn.NewOutPoint(confirmedTxid, 0)), view); err != nil {
+            t.Errorf("ResolvePrevOuts of spender %d: %v", i, err)
+        }
+    }
+}
+
+func TestFileView(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "utxo.jsonl")
+    f, err := os.Create(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    v := baseView()
+    v.AddUTXO(txn.NewOutPoint(confirmedTxid, 2), txn.Vout{ScriptPubKey: "51", Value: 1})
+    if err := v.WriteJSONLines(f); err != nil {
+        t.Fatal(err)
+    }
+    f.Close()
+
+    fv, err := OpenFileView(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer fv.Close()
+    if fv.Len() != 2 {
+        t.Errorf("Len() = %d, want 2", fv.Len())
+    }
+    if out, err := fv.LookupUTXO(txn.NewOutPoint(confirmedTxid, 0)); err != nil || out != confirmedOut {
+        t.Errorf("LookupUTXO = %v, %v", out, err)
+    }
+    if _, err := fv.LookupUTXO(txn.NewOutPoint(confirmedTxid, 1)); !errors.Is(err, ErrNotFound) {
+        t.Errorf("LookupUTXO of a missing output error = %v", err)
+    }
+}
+
+func TestOpenFileViewInvalid(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "utxo.jsonl")
+    os.WriteFile(path, []byte("{\"txid\": \"aa\", \"vout\": 0}\nnot json\n"), 0644)
+    if _, err := OpenFileView(path); err == nil {
+        t.Error("OpenFileView succeeded on an invalid line")
+    }
+}
+
+func TestOpenFileViewBlankLines(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "utxo.jsonl")
+    lines := "\n{\"txid\": \"aa\", \"vout\": 0, \"value\": 1}\n  \n{\"txid\": \"bb\", \"vout\": 1, \"value\": 2}\n\n"
+    os.WriteFile(path, []byte(lines), 0644)
+    fv, err := OpenFileView(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer fv.Close()
+    if fv.Len() != 2 {
+        t.Errorf("Len() = %d, want 2", fv.Len())
+    }
+    // the outputs after a blank line are still read from the right offset
+    if out, err := fv.LookupUTXO(txn.NewOutPoint("bb", 1)); err != nil || out.Value != 2 {
+        t.Errorf("LookupUTXO = %v, %v", out, err)
+    }
+}
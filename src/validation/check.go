@@ -0,0 +1,153 @@
+package validation
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/humblenginr/btc-miner/transaction"
+)
+
+const (
+    // SatoshiPerBitcoin is the number of satoshis in one bitcoin
+    SatoshiPerBitcoin = 100000000
+    // MaxMoney is the maximum value an output (or the sum of the outputs) can have
+    MaxMoney = 21000000 * SatoshiPerBitcoin
+    // MaxBlockWeight is the maximum weight of a block, a transaction without its witness data cannot be bigger than this either
+    MaxBlockWeight = 4000000
+    // MaxPrevOutIndex is the output index used by the null outpoint of coinbase inputs
+    MaxPrevOutIndex = 0xffffffff
+)
+
+// ErrorCode identifies the rule a transaction violated
+type ErrorCode int
+
+const (
+    ErrNoTxInputs ErrorCode = iota
+    ErrNoTxOutputs
+    ErrTxTooBig
+    ErrBadTxOutValue
+    ErrDuplicateTxInputs
+    ErrBadCoinbaseScriptLen
+    ErrBadTxInput
+    ErrMalformedTx
+)
+
+var errorCodeStrings = map[ErrorCode]string{
+    ErrNoTxInputs: "ErrNoTxInputs",
+    ErrNoTxOutputs: "ErrNoTxOutputs",
+    ErrTxTooBig: "ErrTxTooBig",
+    ErrBadTxOutValue: "ErrBadTxOutValue",
+    ErrDuplicateTxInputs: "ErrDuplicateTxInputs",
+    ErrBadCoinbaseScriptLen: "ErrBadCoinbaseScriptLen",
+    ErrBadTxInput: "ErrBadTxInput",
+    ErrMalformedTx: "ErrMalformedTx",
+}
+
+func (e ErrorCode) String() string {
+    if s, ok := errorCodeStrings[e]; ok {
+        return s
+    }
+    return fmt.Sprintf("Unknown ErrorCode (%d)", int(e))
+}
+
+// RuleError is returned when a transaction violates a rule, ErrorCode tells which one.
+type RuleError struct {
+    ErrorCode ErrorCode
+    Description string
+}
+
+func (e RuleError) Error() string {
+    return e.Description
+}
+
+func ruleError(c ErrorCode, desc string) RuleError {
+    return RuleError{ErrorCode: c, Description: desc}
+}
+
+// CheckTransaction performs the checks on the structure of the transaction that do not depend on any context (the prevouts or the chain). It is cheap compared to signature validation and is meant to be run before it.
+// Implemented using CheckTransaction from bitcoin core (src/consensus/tx_check.cpp) as the reference
+func CheckTransaction(tx transaction.Transaction) error {
+    if len(tx.Vin) == 0 {
+        return ruleError(ErrNoTxInputs, "transaction has no inputs")
+    }
+    if len(tx.Vout) == 0 {
+        return ruleError(ErrNoTxOutputs, "transaction has no outputs")
+    }
+    // hex fields that cannot be decoded would make the size calculations below panic
+    if err := checkHexFields(tx); err != nil {
+        return err
+    }
+
+    if size := tx.SerializeSize(false); size*transaction.WitnessScaleFactor > MaxBlockWeight {
+        str := fmt.Sprintf("serialized transaction is too big - got %d, max %d", size, MaxBlockWeight/transaction.WitnessScaleFactor)
+        return ruleError(ErrTxTooBig, str)
+    }
+
+    totalOut := 0
+    for i, out := range tx.Vout {
+        if out.Value < 0 {
+            str := fmt.Sprintf("output %d has negative value %d", i, out.Value)
+            return ruleError(ErrBadTxOutValue, str)
+        }
+        if out.Value > MaxMoney {
+            str := fmt.Sprintf("output %d value of %d is higher than max allowed value of %d", i, out.Value, MaxMoney)
+            return ruleError(ErrBadTxOutValue, str)
+        }
+        totalOut += out.Value
+        if totalOut > MaxMoney {
+            str := fmt.Sprintf("total value of all outputs exceeds max allowed value of %d", MaxMoney)
+            return ruleError(ErrBadTxOutValue, str)
+        }
+    }
+
+    seen := make(map[transaction.OutPoint]struct{}, len(tx.Vin))
+    for _, in := range tx.Vin {
+        op := in.OutPoint()
+        if _, ok := seen[op]; ok {
+            return ruleError(ErrDuplicateTxInputs, "transaction contains duplicate inputs: "+op.String())
+        }
+        seen[op] = struct{}{}
+    }
+
+    if tx.IsCoinbase() {
+        if l := len(tx.Vin[0].ScriptSig) / 2; l < 2 || l > 100 {
+            str := fmt.Sprintf("coinbase transaction script length of %d is out of range (min: 2, max: 100)", l)
+            return ruleError(ErrBadCoinbaseScriptLen, str)
+        }
+        return nil
+    }
+    for i, in := range tx.Vin {
+        if in.IsCoinbase || isNullOutPoint(in.OutPoint()) {
+            str := fmt.Sprintf("input %d of a non-coinbase transaction refers to a null outpoint", i)
+            return ruleError(ErrBadTxInput, str)
+        }
+    }
+    return nil
+}
+
+func isNullOutPoint(op transaction.OutPoint) bool {
+    return op.Vout == MaxPrevOutIndex && strings.Trim(op.Txid, "0") == ""
+}
+
+func checkHexFields(tx transaction.Transaction) error {
+    for i, in := range tx.Vin {
+        if b, err := hex.DecodeString(in.Txid); err != nil || len(b) != 32 {
+            return ruleError(ErrMalformedTx, fmt.Sprintf("input %d has a malformed txid", i))
+        }
+        if _, err := hex.DecodeString(in.ScriptSig); err != nil {
+            return ruleError(ErrMalformedTx, fmt.Sprintf("input %d has a malformed scriptsig", i))
+        }
+        for _, w := range in.Witness {
+            if _, err := hex.DecodeString(w); err != nil {
+                return ruleError(ErrMalformedTx, fmt.Sprintf("input %d has a malformed witness", i))
+            }
+        }
+    }
+    for i, out := range tx.Vout {
+        if _, err := hex.DecodeString(out.ScriptPubKey); err != nil {
+            return ruleError(ErrMalformedTx, fmt.Sprintf("output %d has a malformed scriptpubkey", i))
+        }
+    }
+    return nil
+}
@@ -0,0 +1,90 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/humblenginr/btc-miner/transaction"
+)
+
+var (
+    txidA = strings.Repeat("aa", 32)
+    txidB = strings.Repeat("bb", 32)
+    nullTxid = strings.Repeat("00", 32)
+)
+
+func checkTx(modify func(tx *transaction.Transaction)) transaction.Transaction {
+    tx := transaction.Transaction{
+        Version: 2,
+        Vin: []transaction.Vin{{Txid: txidA, Vout: 0, Sequence: 0xffffffff}, {Txid: txidB, Vout: 1, Sequence: 0xffffffff}},
+        Vout: []transaction.Vout{{ScriptPubKey: "51", Value: 1000}},
+    }
+    if modify != nil {
+        modify(&tx)
+    }
+    return tx
+}
+
+func TestCheckTransaction(t *testing.T) {
+    tests := []struct {
+        name string
+        tx transaction.Transaction
+        // -1 if the transaction is valid
+        code ErrorCode
+    }{
+        {"valid", checkTx(nil), -1},
+        {"no inputs", checkTx(func(tx *transaction.Transaction) { tx.Vin = nil }), ErrNoTxInputs},
+        {"no outputs", checkTx(func(tx *transaction.Transaction) { tx.Vout = nil }), ErrNoTxOutputs},
+        {"malformed txid", checkTx(func(tx *transaction.Transaction) { tx.Vin[0].Txid = "zz" }), ErrMalformedTx},
+        {"short txid", checkTx(func(tx *transaction.Transaction) { tx.Vin[0].Txid = "aa" }), ErrMalformedTx},
+        {"malformed scriptsig", checkTx(func(tx *transaction.Transaction) { tx.Vin[0].ScriptSig = "0" }), ErrMalformedTx},
+        {"malformed witness", checkTx(func(tx *transaction.Transaction) { tx.Vin[1].Witness = []string{"xy"} }), ErrMalformedTx},
+        {"malformed scriptpubkey", checkTx(func(tx *transaction.Transaction) { tx.Vout[0].ScriptPubKey = "5" }), ErrMalformedTx},
+        {"too big", checkTx(func(tx *transaction.Transaction) { tx.Vout[0].ScriptPubKey = strings.Repeat("00", MaxBlockWeight/4) }), ErrTxTooBig},
+        {"negative output", checkTx(func(tx *transaction.Transaction) { tx.Vout[0].Value = -1 }), ErrBadTxOutValue},
+        {"output above max money", checkTx(func(tx *transaction.Transaction) { tx.Vout[0].Value = MaxMoney + 1 }), ErrBadTxOutValue},
+        {"output at max money", checkTx(func(tx *transaction.Transaction) { tx.Vout[0].Value = MaxMoney }), -1},
+        {"total above max money", checkTx(func(tx *transaction.Transaction) {
+            tx.Vout = []transaction.Vout{{ScriptPubKey: "51", Value: MaxMoney}, {ScriptPubKey: "51", Value: 1}}
+        }), ErrBadTxOutValue},
+        {"duplicate inputs", checkTx(func(tx *transaction.Transaction) { tx.Vin[1] = tx.Vin[0] }), ErrDuplicateTxInputs},
+        {"null outpoint", checkTx(func(tx *transaction.Transaction) { tx.Vin[1].Txid, tx.Vin[1].Vout = nullTxid, MaxPrevOutIndex }), ErrBadTxInput},
+        {"coinbase input in a non-coinbase transaction", checkTx(func(tx *transaction.Transaction) { tx.Vin[1].IsCoinbase = true }), ErrBadTxInput},
+        {"coinbase", checkTx(func(tx *transaction.Transaction) {
+            tx.Vin = []transaction.Vin{{Txid: nullTxid, Vout: MaxPrevOutIndex, IsCoinbase: true, ScriptSig: "03951a06"}}
+        }), -1},
+        {"coinbase script too short", checkTx(func(tx *transaction.Transaction) {
+            tx.Vin = []transaction.Vin{{Txid: nullTxid, Vout: MaxPrevOutIndex, IsCoinbase: true, ScriptSig: "51"}}
+        }), ErrBadCoinbaseScriptLen},
+        {"coinbase script too long", checkTx(func(tx *transaction.Transaction) {
+            tx.Vin = []transaction.Vin{{Txid: nullTxid, Vout: MaxPrevOutIndex, IsCoinbase: true, ScriptSig: strings.Repeat("51", 101)}}
+        }), ErrBadCoinbaseScriptLen},
+    }
+    for _, test := range tests {
+        err := CheckTransaction(test.tx)
+        if test.code == -1 {
+            if err != nil {
+                t.Errorf("%s: CheckTransaction() = %v", test.name, err)
+            }
+            continue
+        }
+        var ruleErr RuleError
+        if !errors.As(err, &ruleErr) {
+            t.Errorf("%s: CheckTransaction() = %v, want %v", test.name, err, test.code)
+            continue
+        }
+        if ruleErr.ErrorCode != test.code {
+            t.Errorf("%s: error code = %v, want %v", test.name, ruleErr.ErrorCode, test.code)
+        }
+    }
+}
+
+func TestErrorCodeString(t *testing.T) {
+    if s := ErrDuplicateTxInputs.String(); s != "ErrDuplicateTxInputs" {
+        t.Errorf("String() = %s", s)
+    }
+    if s := ErrorCode(1000).String(); s != "Unknown ErrorCode (1000)" {
+        t.Errorf("String() = %s", s)
+    }
+}
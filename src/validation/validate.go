@@ -1,8 +1,12 @@
 package validation
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"strings"
 
+	"github.com/humblenginr/btc-miner/address"
 	"github.com/humblenginr/btc-miner/utils"
 	"github.com/humblenginr/btc-miner/transaction"
 	"github.com/humblenginr/btc-miner/validation/ecdsa"
@@ -10,6 +14,46 @@ import (
 	"github.com/humblenginr/btc-miner/validation/schnorr"
 )
 
+// ValidateTransaction runs CheckTransaction and CheckPrevOutAddresses, and then validates the signatures of all the inputs
+func ValidateTransaction(tx transaction.Transaction) error {
+    // cheap structural checks before the signatures are verified
+    if err := CheckTransaction(tx); err != nil {
+        return err
+    }
+    if err := CheckPrevOutAddresses(tx, Network); err != nil {
+        return err
+    }
+    for inputIdx := range tx.Vin {
+        if(!Validate(tx, inputIdx)){
+            return fmt.Errorf("input %d: script validation failed", inputIdx)
+        }
+    }
+    return nil
+}
+
+// Network is used to check the addresses given in the prevouts of the transactions
+var Network = &address.MainNetParams
+
+var ErrAddressMismatch = errors.New("prevout address does not match its scriptpubkey")
+
+// CheckPrevOutAddresses cross-checks the addresses given in the prevouts of the transaction against their scriptPubKeys. Only the addresses that decode for net are checked, the other ones (unknown witness versions, other networks) are left alone.
+func CheckPrevOutAddresses(tx transaction.Transaction, net *address.Params) error {
+    for i, in := range tx.Vin {
+        if in.IsCoinbase || in.PrevOut.ScriptPubKeyAddr == "" {
+            continue
+        }
+        a, err := address.Decode(in.PrevOut.ScriptPubKeyAddr, net)
+        if err != nil {
+            continue
+        }
+        script, err := hex.DecodeString(in.PrevOut.ScriptPubKey)
+        if err != nil || !bytes.Equal(a.ScriptPubKey(), script) {
+            return fmt.Errorf("input %d: %w: %s", i, ErrAddressMismatch, in.PrevOut.ScriptPubKeyAddr)
+        }
+    }
+    return nil
+}
+
 func Validate( tx transaction.Transaction , trIdx int) bool {
     // Get transaction type
     i := tx.Vin[trIdx]
@@ -28,6 +72,9 @@ func Validate( tx transaction.Transaction , trIdx int) bool {
        return validateP2WPKH(tx, trIdx) 
     case transaction.P2TR:
        return validateP2TR(tx, trIdx) 
+    case transaction.P2A:
+       // anyone can spend an anchor, but only with an empty scriptSig and witness
+       return i.ScriptSig == "" && len(i.Witness) == 0
     default:
         return false
     }
@@ -35,12 +82,15 @@ func Validate( tx transaction.Transaction , trIdx int) bool {
 
 func validateP2PKH(tx transaction.Transaction, trIdx int) bool {
     scriptSigInstrs := strings.Split(tx.Vin[trIdx].ScriptSigAsm, " ")
+    if len(scriptSigInstrs) < 2 {
+        return false
+    }
     pubkey, _ := hex.DecodeString(scriptSigInstrs[len(scriptSigInstrs)-1])
     sigBytes, _ := hex.DecodeString(scriptSigInstrs[1])
     // 1. Parse public key and signature
     pk, sig,hashtype, err :=  ecdsa.ParseSigAndPubkey(pubkey, sigBytes)
     if err != nil {
-        panic("Cannot parse signatuer and pub key: "+ err.Error())
+        return false
     }
     subscript := tx.Vin[trIdx].PrevOut.ScriptPubKey
     subscriptBytes, _ := hex.DecodeString(subscript)
@@ -78,6 +128,10 @@ If there are more than one elements in the witness array, then we have to do the
 func validateP2TR( tx transaction.Transaction, trIdx int ) bool {
     txIn := tx.Vin[trIdx]
     witness := txIn.Witness
+    // OP_1 OP_DATA_32 <32-byte-key>
+    if len(txIn.PrevOut.ScriptPubKey) != 68 || len(witness) == 0 {
+        return false
+    }
     sighashes := sighash.TaprootSigHashes{
         HashPrevoutsV1: [32]byte(tx.CalcHashPrevOuts()[:]),
         HashSequenceV1: [32]byte(tx.CalcHashSequence()[:]),
@@ -114,7 +168,7 @@ func validateP2TR( tx transaction.Transaction, trIdx int ) bool {
             return false
         }
         s,_ := hex.DecodeString(witness[len(witness)-2])
-        if(s[33] != 0xac) {
+        if(len(s) < 34 || s[33] != 0xac) {
             return false
         }
         // remove annex from the witness array, if found
@@ -122,6 +176,9 @@ func validateP2TR( tx transaction.Transaction, trIdx int ) bool {
         // parse the control block
         cb_bytes,_ := hex.DecodeString(witness[len(witness)-1])
         c, err := ParseControlBlock(cb_bytes)
+        if err != nil {
+            return false
+        }
         // verify taproot leaf commitment
         q, _ := hex.DecodeString(txIn.PrevOut.ScriptPubKey[4:])
         err = VerifyTaprootLeafCommitment(c,q,s)
@@ -154,12 +211,15 @@ func validateP2TR( tx transaction.Transaction, trIdx int ) bool {
 
 func validateP2WPKH( tx transaction.Transaction, trIdx int ) bool {
     txIn := tx.Vin[trIdx]
+    if len(txIn.Witness) != 2 {
+        return false
+    }
     pubkey, _ := hex.DecodeString(txIn.Witness[1])
     sigBytes, _ := hex.DecodeString(txIn.Witness[0])
     // 1. Parse public key and signature
     pk, sig, hashtype, err :=  ecdsa.ParseSigAndPubkey(pubkey, sigBytes)
     if err != nil {
-        panic("Cannot parse signature and pub key: "+ err.Error())
+        return false
         }
     subscript := txIn.PrevOut.ScriptPubKey
     subscriptBytes, _ := hex.DecodeString(subscript)
@@ -168,7 +228,7 @@ func validateP2WPKH( tx transaction.Transaction, trIdx int ) bool {
     cache := sighash.SegwitSigHashes{HashPrevouts: [32]byte(utils.Hash(tx.CalcHashPrevOuts()[:])), HashSequence: [32]byte(utils.Hash(tx.CalcHashSequence()[:])), HashOutputs: [32]byte(utils.Hash(tx.CalcHashOutputs()[:]))}
     sighash, err := sighash.CalcWitnessSignatureHash(subscriptBytes, &cache, hashtype,&tx, trIdx)
     if err != nil {
-        panic("Cannot calculate signature hash : "+ err.Error())
+        return false
     }
     // 3. Verify signature
     return ecdsa.Verify(sig, sighash, pk)
@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/humblenginr/btc-miner/address"
+	"github.com/humblenginr/btc-miner/transaction"
+)
+
+func spending(prevOut transaction.Vout) transaction.Transaction {
+    return transaction.Transaction{Vin: []transaction.Vin{{PrevOut: prevOut}}}
+}
+
+func TestCheckPrevOutAddresses(t *testing.T) {
+    p2wpkh := "0014751e76e8199196d454941c45d1b3a323f1433bd6"
+    tests := []struct {
+        name string
+        prevOut transaction.Vout
+        net *address.Params
+        wantErr bool
+    }{
+        {"matching address", transaction.Vout{ScriptPubKey: p2wpkh, ScriptPubKeyAddr: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"}, &address.MainNetParams, false},
+        {"no address", transaction.Vout{ScriptPubKey: p2wpkh}, &address.MainNetParams, false},
+        {"other script", transaction.Vout{ScriptPubKey: "0014000000000000000000000000000000000000000000", ScriptPubKeyAddr: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"}, &address.MainNetParams, true},
+        {"p2pkh of the same hash", transaction.Vout{ScriptPubKey: p2wpkh, ScriptPubKeyAddr: "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"}, &address.MainNetParams, true},
+        // addresses that cannot be decoded are not checked
+        {"witness v2", transaction.Vout{ScriptPubKey: p2wpkh, ScriptPubKeyAddr: "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs"}, &address.MainNetParams, false},
+        {"other network", transaction.Vout{ScriptPubKey: p2wpkh, ScriptPubKeyAddr: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"}, &address.TestNetParams, false},
+    }
+    for _, test := range tests {
+        err := CheckPrevOutAddresses(spending(test.prevOut), test.net)
+        if (err != nil) != test.wantErr {
+            t.Errorf("%s: CheckPrevOutAddresses() = %v, want error: %v", test.name, err, test.wantErr)
+        }
+        if err != nil && !errors.Is(err, ErrAddressMismatch) {
+            t.Errorf("%s: error %v is not ErrAddressMismatch", test.name, err)
+        }
+    }
+}
+
+func loadTestTx(t *testing.T, name string) transaction.Transaction {
+    b, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var tx transaction.Transaction
+    if err := json.Unmarshal(b, &tx); err != nil {
+        t.Fatal(err)
+    }
+    return tx
+}
+
+// mainnet transactions, one per input type
+var validTxns = map[string]string{
+    "p2wpkh": "000cb561188c762c81f76976f816829424e2af9e0e491c617b7bf41038df3d35",
+    "p2pkh": "00d12b523d8b7ad90e2269767478764c243625539dc59bcd457d14ca1aa4e38c",
+    "p2tr": "00d9c01fd8722f63cc327c93e59de64395d1e6ca5861ae6b9b149b364d082352",
+}
+
+func TestValidateTransaction(t *testing.T) {
+    for name, file := range validTxns {
+        tx := loadTestTx(t, file)
+        if err := ValidateTransaction(tx); err != nil {
+            t.Errorf("%s: ValidateTransaction() = %v", name, err)
+        }
+        // the signature no longer commits to the outputs
+        tx.Vout[0].Value--
+        if err := ValidateTransaction(tx); err == nil {
+            t.Errorf("%s: ValidateTransaction() succeeded with a modified output", name)
+        }
+    }
+}
+
+func TestValidateMalformedInputs(t *testing.T) {
+    tests := []struct {
+        name string
+        in transaction.Vin
+    }{
+        {"p2pkh without signature", transaction.Vin{ScriptSigAsm: "OP_0", PrevOut: transaction.Vout{ScriptPubKey: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", ScriptPubKeyType: transaction.P2PKH}}},
+        {"p2pkh with garbage signature", transaction.Vin{ScriptSigAsm: "OP_PUSHBYTES_2 0102 OP_PUSHBYTES_1 03", PrevOut: transaction.Vout{ScriptPubKey: "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", ScriptPubKeyType: transaction.P2PKH}}},
+        {"p2wpkh with one witness item", transaction.Vin{Witness: []string{"3001"}, PrevOut: transaction.Vout{ScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6", ScriptPubKeyType: transaction.P2WPKH}}},
+        {"p2wpkh with garbage signature", transaction.Vin{Witness: []string{"3001", "02"}, PrevOut: transaction.Vout{ScriptPubKey: "0014751e76e8199196d454941c45d1b3a323f1433bd6", ScriptPubKeyType: transaction.P2WPKH}}},
+        {"p2tr without witness", transaction.Vin{PrevOut: transaction.Vout{ScriptPubKey: "5120" + strings.Repeat("44", 32), ScriptPubKeyType: transaction.P2TR}}},
+        {"p2tr with short script", transaction.Vin{Witness: []string{"01", "ac", "c0"}, PrevOut: transaction.Vout{ScriptPubKey: "5120" + strings.Repeat("44", 32), ScriptPubKeyType: transaction.P2TR}}},
+        {"p2tr with short control block", transaction.Vin{Witness: []string{"01", "20" + strings.Repeat("44", 32) + "ac", "c0"}, PrevOut: transaction.Vout{ScriptPubKey: "5120" + strings.Repeat("44", 32), ScriptPubKeyType: transaction.P2TR}}},
+    }
+    for _, test := range tests {
+        test.in.Txid = txidA
+        tx := transaction.Transaction{Version: 2, Vin: []transaction.Vin{test.in}, Vout: []transaction.Vout{{ScriptPubKey: "51"}}}
+        if err := ValidateTransaction(tx); err == nil {
+            t.Errorf("%s: ValidateTransaction() succeeded", test.name)
+        }
+    }
+}